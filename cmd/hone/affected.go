@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/justinbarrick/hone/pkg/affected"
+	"github.com/justinbarrick/hone/pkg/config"
+	"github.com/justinbarrick/hone/pkg/config/types"
+	"github.com/justinbarrick/hone/pkg/git"
+	"github.com/justinbarrick/hone/pkg/graph"
+	"github.com/justinbarrick/hone/pkg/logger"
+)
+
+// Return true if any of the changed files (relative to dir) were loaded as part
+// of the configuration.
+func configChanged(parser config.Parser, dir string, changed []string) bool {
+	loaded := map[string]bool{}
+	for filename := range parser.Files() {
+		if abs, err := filepath.Abs(filename); err == nil {
+			loaded[abs] = true
+		}
+	}
+
+	for _, path := range changed {
+		if abs, err := filepath.Abs(filepath.Join(dir, path)); err == nil && loaded[abs] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return the jobs in names that the targets depend on, or names if no targets
+// were given.
+func selectTargets(cfg types.Config, names []string, targets []string, excludes []string) ([]string, error) {
+	if len(targets) == 0 {
+		return names, nil
+	}
+
+	ids := map[string]int64{}
+	for _, j := range cfg.Jobs {
+		ids[j.GetName()] = j.ID()
+	}
+
+	g := graph.NewGraph(cfg.GetNodes())
+
+	selected, err := g.Select(targets, excludes)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := []string{}
+	for _, name := range names {
+		if selected[ids[name]] {
+			filtered = append(filtered, name)
+		}
+	}
+
+	return filtered, nil
+}
+
+// Print, or run with -run, the jobs affected by the files changed between two
+// revisions: jobs with a changed input and every job that depends on them. If
+// targets are given, only jobs that they depend on are included. Returns the
+// exit code.
+func affectedTargets(args []string, vars map[string]string, varFiles []string, excludes []string) int {
+	flags := flag.NewFlagSet("affected", flag.ExitOnError)
+	base := flags.String("base", "origin/master", "The revision to compare against.")
+	head := flags.String("head", "HEAD", "The revision with the changes.")
+	runJobs := flags.Bool("run", false, "Run the affected jobs instead of printing them.")
+	file := fileFlag(flags)
+	flags.Parse(args)
+
+	honePath, targets := parseArgs(*file, flags.Args())
+
+	parser := config.NewParser()
+	parser.Vars = vars
+	parser.VarFiles = varFiles
+	parser.SkipSecrets = !*runJobs
+
+	if err := parser.ParseFile(honePath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	cfg, err := parser.DecodeConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	repo, err := git.NewRepository()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	root, err := repo.Root()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	changed, err := repo.ChangedFiles(*base, *head)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	dir := filepath.Dir(parser.Path())
+
+	changed, err = affected.RelativeTo(root, dir, changed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	names := []string{}
+	if configChanged(parser, dir, changed) {
+		for _, j := range cfg.Jobs {
+			names = append(names, j.GetName())
+		}
+		sort.Strings(names)
+	} else if names, err = affected.Jobs(cfg.Jobs, changed); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if names, err = selectTargets(cfg, names, targets, excludes); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if !*runJobs {
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return 0
+	}
+
+	if len(names) == 0 {
+		logger.Printf("No jobs are affected by the changes between %s and %s.", *base, *head)
+		return 0
+	}
+
+	run(&cfg, names, excludes)
+	return 0
+}
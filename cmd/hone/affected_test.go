@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/justinbarrick/hone/pkg/config/types"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectTargets(t *testing.T) {
+	cfg := types.Config{
+		Jobs: []*job.Job{
+			{Name: "build"},
+			{Name: "test", Deps: &job.StringSet{"build"}},
+			{Name: "lint"},
+		},
+	}
+
+	names := []string{"build", "lint", "test"}
+
+	selected, err := selectTargets(cfg, names, []string{}, []string{})
+	assert.Nil(t, err)
+	assert.Equal(t, names, selected)
+
+	selected, err = selectTargets(cfg, names, []string{"test"}, []string{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"build", "test"}, selected)
+
+	_, err = selectTargets(cfg, names, []string{"deploy"}, []string{})
+	assert.NotNil(t, err)
+}
+
+func TestAffectedHonefileArg(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hone")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer os.Chdir(cwd)
+	assert.Nil(t, os.Chdir(dir))
+
+	assert.Nil(t, os.Mkdir("subdir", 0755))
+	assert.Nil(t, ioutil.WriteFile("subdir/Honefile", []byte(""), 0644))
+
+	path, targets := parseArgs("", []string{"./subdir"})
+	assert.Equal(t, "./subdir", path)
+
+	selected, err := selectTargets(types.Config{}, []string{"build"}, targets, []string{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"build"}, selected)
+}
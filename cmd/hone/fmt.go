@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/justinbarrick/hone/pkg/config"
+)
+
+// Return the HCL files to format for a path: the file itself, or the Honefile
+// and .hcl files in a directory.
+func formatPaths(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+
+	paths := []string{}
+
+	honefile := filepath.Join(path, "Honefile")
+	if fi, err := os.Stat(honefile); err == nil && !fi.IsDir() {
+		paths = append(paths, honefile)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(path, "*.hcl"))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(paths, matches...), nil
+}
+
+// Format Honefiles in place and print the files that changed, returns the exit code.
+// With -check files are not rewritten and the exit code is non-zero if any file
+// is not formatted.
+func format(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "Only check if files are formatted, exit non-zero if not.")
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) == 0 {
+		honefile, err := config.FindHonefile(".")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		args = []string{honefile}
+	}
+
+	status := 0
+
+	for _, arg := range args {
+		paths, err := formatPaths(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		for _, path := range paths {
+			if filepath.Ext(path) == ".json" {
+				fmt.Fprintf(os.Stderr, "Skipping %s, only HCL files can be formatted.\n", path)
+				continue
+			}
+
+			changed, err := config.FormatFile(path, !*check)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+				continue
+			}
+
+			if changed {
+				fmt.Println(path)
+
+				if *check {
+					status = 1
+				}
+			}
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/cache"
+	"github.com/justinbarrick/hone/pkg/config"
+	"github.com/justinbarrick/hone/pkg/git"
+	"github.com/justinbarrick/hone/pkg/reporting"
+)
+
+// Initialize the cache that builds of the Honefile at honePath report to: S3 if
+// it is enabled, otherwise the file cache.
+func historyCache(honePath string, vars map[string]string, varFiles []string) (cache.Cache, error) {
+	config, err := config.UnmarshalWithVars(honePath, vars, varFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Cache.S3.Enabled() {
+		return config.Cache.S3, config.Cache.S3.Init()
+	}
+
+	return config.Cache.File, config.Cache.File.Init()
+}
+
+// Return the branch to read the history of, defaulting to the current branch.
+func historyBranch(branch string) string {
+	if branch != "" {
+		return branch
+	}
+
+	repo, _ := git.NewRepository()
+	branch, _ = repo.Branch()
+	return branch
+}
+
+// List the recent builds of a branch and its flaky jobs, returns the exit code.
+func history(args []string, vars map[string]string, varFiles []string) int {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	branch := flags.String("branch", "", "The branch to list builds of, defaults to the current branch.")
+	limit := flags.Int("n", 20, "The number of recent builds to list.")
+	file := fileFlag(flags)
+	flags.Parse(args)
+
+	honePath := honefilePath(*file)
+	if flags.NArg() > 0 {
+		honePath = flags.Arg(0)
+	}
+
+	c, err := historyCache(honePath, vars, varFiles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	*branch = historyBranch(*branch)
+
+	entries, err := reporting.LoadHistory(c, *branch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintf(os.Stderr, "No builds found for branch %s.\n", *branch)
+		return 1
+	}
+
+	recent := entries
+	if *limit > 0 && len(recent) > *limit {
+		recent = recent[len(recent)-*limit:]
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Builds of %s:\n", *branch)
+	fmt.Fprintln(w, "BUILD\tCOMMIT\tTARGET\tSTARTED\tDURATION\tSTATE\tFAILED")
+
+	for i := len(recent) - 1; i >= 0; i-- {
+		entry := recent[i]
+
+		failed := []string{}
+		for _, j := range entry.Jobs {
+			if j.Status == "failed" {
+				failed = append(failed, j.Name)
+			}
+		}
+
+		commit := entry.GitCommit
+		if len(commit) > 8 {
+			commit = commit[:8]
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.BuildID, orDash(commit), entry.Target,
+			entry.StartTime.Local().Format("2006-01-02 15:04:05"), entry.Duration().Round(100*time.Millisecond),
+			orDash(entry.State), orDash(strings.Join(failed, ", ")))
+	}
+
+	flaky := reporting.FlakyJobs(entries)
+	if len(flaky) > 0 {
+		fmt.Fprintf(w, "\nFlaky jobs across %d builds:\n", len(entries))
+		fmt.Fprintln(w, "JOB\tHASH\tPASSED\tFAILED")
+
+		for _, f := range flaky {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", f.Name, f.Hash, f.Passed, f.Failed)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// Compare the job outcomes, durations and cache hits of two builds, given as
+// build IDs from the branch's history or as report.json files or URLs. Returns
+// the exit code.
+func diff(args []string, vars map[string]string, varFiles []string) int {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	branch := flags.String("branch", "", "The branch to find builds in, defaults to the current branch.")
+	file := fileFlag(flags)
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "Usage: hone diff [-branch branch] <build> <build> [honefile]")
+		return 1
+	}
+
+	honePath := honefilePath(*file)
+	if flags.NArg() > 2 {
+		honePath = flags.Arg(2)
+	}
+
+	var entries []reporting.HistoryEntry
+
+	load := func(build string) (reporting.BuildRecord, error) {
+		if strings.HasSuffix(build, ".json") || strings.Contains(build, "://") {
+			return reporting.LoadBuild(build)
+		}
+
+		if entries == nil {
+			c, err := historyCache(honePath, vars, varFiles)
+			if err != nil {
+				return reporting.BuildRecord{}, err
+			}
+
+			entries, err = reporting.LoadHistory(c, historyBranch(*branch))
+			if err != nil {
+				return reporting.BuildRecord{}, err
+			}
+		}
+
+		entry, err := reporting.FindBuild(entries, build)
+		if err != nil {
+			return reporting.BuildRecord{}, err
+		}
+
+		return reporting.LoadBuild(entry.ReportURL)
+	}
+
+	before, err := load(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	after, err := load(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	round := func(d time.Duration) time.Duration {
+		return d.Round(100 * time.Millisecond)
+	}
+
+	describe := func(build reporting.BuildRecord) string {
+		return fmt.Sprintf("%s (%s, %s, %s)", build.BuildID, build.GitCommit, orDash(build.State), round(build.EndTime.Sub(build.StartTime)))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Before: %s\nAfter:  %s\n\n", describe(before), describe(after))
+	fmt.Fprintln(w, "\tJOB\tBEFORE\tAFTER\tDURATION\tCHANGE")
+
+	for _, d := range reporting.DiffBuilds(before, after) {
+		beforeStatus, afterStatus := "-", "-"
+		var beforeDuration, afterDuration time.Duration
+
+		if d.Before != nil {
+			beforeStatus = d.Before.Status()
+			beforeDuration = d.Before.Duration()
+		}
+
+		if d.After != nil {
+			afterStatus = d.After.Status()
+			afterDuration = d.After.Duration()
+		}
+
+		marker := ""
+		if d.Changed() {
+			marker = "*"
+		}
+
+		change := round(d.Delta()).String()
+		if d.Delta() >= 0 {
+			change = "+" + change
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s -> %s\t%s\n", marker, d.Name, beforeStatus, afterStatus,
+			round(beforeDuration), round(afterDuration), change)
+	}
+
+	if err := w.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/justinbarrick/hone/pkg/cache"
 	"github.com/justinbarrick/hone/pkg/config"
+	"github.com/justinbarrick/hone/pkg/config/types"
 	"github.com/justinbarrick/hone/pkg/events"
 	"github.com/justinbarrick/hone/pkg/executors"
 	"github.com/justinbarrick/hone/pkg/executors/docker"
@@ -16,55 +21,200 @@ import (
 	"github.com/justinbarrick/hone/pkg/graph/node"
 	"github.com/justinbarrick/hone/pkg/job"
 	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/justinbarrick/hone/pkg/notify"
 	"github.com/justinbarrick/hone/pkg/reporting"
 	"github.com/justinbarrick/hone/pkg/scm"
 )
 
+// The path to write the build's JUnit report to, if any.
+var junitPath string
+
+type stringList []string
+
+func (s *stringList) String() string {
+	return fmt.Sprintf("%v", *s)
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// The Honefile, or directory containing one, given with -f.
+var honefile string
+
+// Return true if an argument names a Honefile rather than a target: it must
+// exist and contain a path separator, be one of the Honefile names or be a file
+// ending in .hcl or .json.
+func isHonefile(arg string) bool {
+	if !strings.ContainsRune(arg, '/') && !strings.ContainsRune(arg, filepath.Separator) {
+		isName := false
+		for _, name := range config.HonefileNames {
+			if arg == name {
+				isName = true
+			}
+		}
+
+		if ext := filepath.Ext(arg); ext == ".hcl" || ext == ".json" {
+			fi, err := os.Stat(arg)
+			isName = err == nil && fi.Mode().IsRegular()
+		}
+
+		if !isName {
+			return false
+		}
+	}
+
+	_, err := config.FindHonefile(arg)
+	return err == nil
+}
+
+// Split positional arguments into the Honefile path and the targets. The
+// Honefile is the -f flag if it is set and otherwise the first argument if it
+// is a path to a Honefile (see isHonefile). No targets are returned if none
+// were given.
+func parseArgs(file string, args []string) (string, []string) {
+	if file == "" && len(args) > 0 && isHonefile(args[0]) {
+		return args[0], args[1:]
+	}
+
+	return honefilePath(file), args
+}
+
+// Return the Honefile given with -f, or the local directory if it is unset.
+func honefilePath(file string) string {
+	if file == "" {
+		return "."
+	}
+
+	return file
+}
+
+// Register -f and -file on a subcommand's flags, defaulting to the Honefile
+// given before the subcommand.
+func fileFlag(flags *flag.FlagSet) *string {
+	file := flags.String("f", honefile, "The Honefile, or a directory containing one, to load.")
+	flags.StringVar(file, "file", honefile, "The Honefile, or a directory containing one, to load.")
+	return file
+}
+
+// The subcommands of hone, any other arguments are targets to run. Jobs named
+// after a subcommand can be run with "hone run <job>".
+var commands = []string{"run", "validate", "fmt", "list", "show", "stats", "history", "diff", "affected"}
+
+// Split arguments into the subcommand and its arguments, defaulting to run.
+func command(args []string) (string, []string) {
+	if len(args) > 0 {
+		for _, name := range commands {
+			if args[0] == name {
+				return name, args[1:]
+			}
+		}
+	}
+
+	return "run", args
+}
+
 func main() {
-	honePath := "Honefile"
-	target := "all"
+	var varFlags, varFiles, excludes stringList
+	flag.Var(&varFlags, "var", "Set a variable in the form name=value, can be repeated.")
+	flag.Var(&varFiles, "var-file", "Load variable values from an HCL or JSON file, can be repeated.")
+	flag.Var(&excludes, "exclude", "Do not run a job or the dependencies that only it needs, can be repeated.")
+	flag.StringVar(&junitPath, "junit", "", "Write a JUnit XML report of the build to this path.")
+	flag.StringVar(&honefile, "f", "", "The Honefile, or a directory containing one, to load.")
+	flag.StringVar(&honefile, "file", "", "The Honefile, or a directory containing one, to load.")
+	flag.Parse()
 
-	if len(os.Args) == 2 {
-		target = os.Args[1]
-	} else if len(os.Args) == 3 {
-		honePath = os.Args[1]
-		target = os.Args[2]
+	vars, err := config.ParseVarFlags(varFlags)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	logger.InitLogger(0, nil)
 
-	config, err := config.Unmarshal(honePath)
+	name, args := command(flag.Args())
+	switch name {
+	case "validate":
+		os.Exit(validate(args, vars, varFiles))
+	case "fmt":
+		os.Exit(format(args))
+	case "list":
+		os.Exit(list(args, vars, varFiles))
+	case "show":
+		os.Exit(show(args, vars, varFiles))
+	case "stats":
+		os.Exit(stats(args, vars, varFiles))
+	case "history":
+		os.Exit(history(args, vars, varFiles))
+	case "diff":
+		os.Exit(diff(args, vars, varFiles))
+	case "affected":
+		os.Exit(affectedTargets(args, vars, varFiles, excludes))
+	}
+
+	honePath, targets := parseArgs(honefile, args)
+	if len(targets) == 0 {
+		targets = []string{"all"}
+	}
+
+	config, err := config.UnmarshalWithVars(honePath, vars, varFiles)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	scms, err := scm.InitSCMs(config.SCM, config.Env)
+	run(config, targets, excludes)
+}
+
+// Run the targets and their dependencies, reporting the build to the configured
+// SCMs and exiting with the number of errors.
+func run(config *types.Config, targets []string, excludes []string) {
+	scms, err := scm.InitSCMs(config.SCM, config.Conditions())
 	if err != nil {
 		logger.Printf("Could not initialize SCMs: %s", err)
 	}
 
-	report, err := reporting.New(target, scms, config.Cache.S3)
+	report, err := reporting.New(strings.Join(targets, " "), scms, config.Cache.S3)
 	if err != nil {
 		logger.Printf("Could not initialize reporting: %s", err)
 	}
+	report.SetJUnitPath(junitPath)
+
+	notifiers, err := notify.Init(config.Notify, config.Conditions())
+	if err != nil {
+		logger.Printf("Could not initialize notifications: %s", err)
+	}
+	report.SetNotifiers(notifiers)
 
 	if err = scm.BuildStarted(scms); err != nil {
 		logger.Errorf("Error initializing SCMs: %s", err)
 		report.Exit(err)
 	}
+	report.Started()
 
 	g := graph.NewGraph(config.GetNodes())
 
-	longest, errs := g.LongestTarget(target)
+	longest, errs := g.LongestTargets(targets, excludes)
 	if len(errs) != 0 {
 		report.Exit(errs...)
 	}
 
-	callback := func(j *job.Job) error {
-		return executors.Run(config, j)
+	selected, err := g.Select(targets, excludes)
+	if err != nil {
+		report.Exit(err)
+	}
+
+	selectedJobs := []*job.Job{}
+	for _, j := range config.Jobs {
+		if selected[j.ID()] {
+			selectedJobs = append(selectedJobs, j)
+		}
 	}
 
-	callback = events.EventCallback(config.Env, callback)
+	callback := executors.Strict(config, func(j *job.Job) error {
+		return executors.Run(config, j)
+	})
+
+	callback = events.EventCallback(config.Conditions(), callback)
 
 	fileCache := config.Cache.File
 	if err = fileCache.Init(); err != nil {
@@ -74,6 +224,10 @@ func main() {
 
 	var logWriter io.WriteCloser
 
+	if !config.Cache.S3.Enabled() {
+		report.SetCache(fileCache)
+	}
+
 	if config.Cache.S3 != nil && config.Cache.S3.Enabled() {
 		if err = config.Cache.S3.Init(); err != nil {
 			logger.Errorf("Error initializing S3: %s", err)
@@ -93,18 +247,54 @@ func main() {
 		report.SetLogURL(logUrl)
 	}
 
-	logger.InitLogger(longest, logWriter)
+	logger.InitLogger(longest, report.LogStream(logWriter))
+
+	jobReporter := scm.NewJobReporter(scms, func(j *job.Job) string {
+		return report.JobURL(j.GetName())
+	})
+	jobReporter.Pending(selectedJobs)
+
+	callback = report.ReportJob(jobReporter.ReportJob(reporting.CollectTests(executors.PinImage(config, cache.CacheJob(fileCache, callback)))))
+
+	config.DockerConfig = &docker.DockerConfig{
+		BuildID:    report.BuildID,
+		Registries: config.Registries,
+	}
+	if executors.UsesDocker(config, selectedJobs) {
+		if err = config.DockerConfig.Init(); err != nil {
+			logger.Printf("Could not initialize Docker: %s", err)
+		}
+	}
 
-	callback = report.ReportJob(cache.CacheJob(fileCache, callback))
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 
-	config.DockerConfig = &docker.DockerConfig{}
-	config.DockerConfig.Init()
+	// Cancel the build on the first signal, the build is finished once the
+	// running jobs have stopped. A second signal exits immediately.
+	canceled := make(chan error, 1)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		logger.Printf("Received %s, canceling the build.", sig)
+		canceled <- fmt.Errorf("received %s", sig)
+		g.Cancel()
+	}()
 
-	errs = g.ResolveTarget(target, func(n node.Node) error {
+	errs = g.ResolveTargets(targets, excludes, func(n node.Node) error {
 		return logger.LogJob(callback)(n.(*job.Job))
 	})
 
-	report.Final(errs...)
+	jobReporter.Finish(selectedJobs)
+
+	code := len(errs)
+
+	select {
+	case reason := <-canceled:
+		report.Cancel(reason)
+		code = 130
+	default:
+		report.Final(errs...)
+	}
 
 	if logWriter != nil {
 		err = logWriter.Close()
@@ -114,5 +304,5 @@ func main() {
 	}
 
 	config.DockerConfig.Cleanup()
-	os.Exit(len(errs))
+	os.Exit(code)
 }
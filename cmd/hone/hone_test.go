@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommand(t *testing.T) {
+	name, args := command([]string{})
+	assert.Equal(t, "run", name)
+	assert.Equal(t, []string{}, args)
+
+	name, args = command([]string{"build", "test"})
+	assert.Equal(t, "run", name)
+	assert.Equal(t, []string{"build", "test"}, args)
+
+	name, args = command([]string{"list", "examples/"})
+	assert.Equal(t, "list", name)
+	assert.Equal(t, []string{"examples/"}, args)
+
+	name, args = command([]string{"run", "list", "diff"})
+	assert.Equal(t, "run", name)
+	assert.Equal(t, []string{"list", "diff"}, args)
+}
+
+func TestParseArgs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hone")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	defer os.Chdir(cwd)
+	assert.Nil(t, os.Chdir(dir))
+
+	assert.Nil(t, os.Mkdir("build", 0755))
+	assert.Nil(t, ioutil.WriteFile("build/Honefile", []byte(""), 0644))
+	assert.Nil(t, ioutil.WriteFile("Honefile", []byte(""), 0644))
+	assert.Nil(t, ioutil.WriteFile("ci.hcl", []byte(""), 0644))
+	assert.Nil(t, os.Mkdir("jobs.hcl", 0755))
+
+	path, targets := parseArgs("", []string{})
+	assert.Equal(t, ".", path)
+	assert.Equal(t, []string{}, targets)
+
+	path, targets = parseArgs("", []string{"build", "test"})
+	assert.Equal(t, ".", path)
+	assert.Equal(t, []string{"build", "test"}, targets)
+
+	path, targets = parseArgs("", []string{"./build", "test"})
+	assert.Equal(t, "./build", path)
+	assert.Equal(t, []string{"test"}, targets)
+
+	path, targets = parseArgs("", []string{"build/"})
+	assert.Equal(t, "build/", path)
+	assert.Equal(t, []string{}, targets)
+
+	path, targets = parseArgs("", []string{"Honefile", "build"})
+	assert.Equal(t, "Honefile", path)
+	assert.Equal(t, []string{"build"}, targets)
+
+	path, targets = parseArgs("", []string{"ci.hcl", "build"})
+	assert.Equal(t, "ci.hcl", path)
+	assert.Equal(t, []string{"build"}, targets)
+
+	path, targets = parseArgs("", []string{"jobs.hcl", "missing.json"})
+	assert.Equal(t, ".", path)
+	assert.Equal(t, []string{"jobs.hcl", "missing.json"}, targets)
+
+	path, targets = parseArgs("", []string{"./missing", "build"})
+	assert.Equal(t, ".", path)
+	assert.Equal(t, []string{"./missing", "build"}, targets)
+
+	path, targets = parseArgs("build", []string{"./build", "test"})
+	assert.Equal(t, "build", path)
+	assert.Equal(t, []string{"./build", "test"}, targets)
+}
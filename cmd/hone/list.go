@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/justinbarrick/hone/pkg/config"
+	"github.com/justinbarrick/hone/pkg/config/types"
+	"github.com/justinbarrick/hone/pkg/executors"
+	"github.com/justinbarrick/hone/pkg/job"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Load a Honefile without loading secrets from Vault.
+func loadConfig(path string, vars map[string]string, varFiles []string) (*types.Config, error) {
+	parser := config.NewParser()
+	parser.Vars = vars
+	parser.VarFiles = varFiles
+	parser.SkipSecrets = true
+
+	if err := parser.ParseFile(path); err != nil {
+		return nil, err
+	}
+
+	config, err := parser.DecodeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func orDash(str string) string {
+	if str == "" {
+		return "-"
+	}
+
+	return str
+}
+
+// Print every job with its description, dependencies, engine, template and
+// whether it is a service, returns the exit code.
+func list(args []string, vars map[string]string, varFiles []string) int {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	file := fileFlag(flags)
+	flags.Parse(args)
+
+	honePath := honefilePath(*file)
+	if flags.NArg() > 0 {
+		honePath = flags.Arg(0)
+	}
+
+	config, err := loadConfig(honePath, vars, varFiles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	jobs := append([]*job.Job{}, config.Jobs...)
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].GetName() < jobs[j].GetName()
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDESCRIPTION\tDEPS\tENGINE\tTEMPLATE\tSERVICE")
+
+	for _, j := range jobs {
+		deps := append([]string{}, j.GetDeps()...)
+		sort.Strings(deps)
+
+		engine := executors.EngineName(config, j)
+		if j.Aggregate {
+			engine = ""
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\n", j.GetName(), orDash(j.GetDescription()),
+			orDash(strings.Join(deps, ",")), orDash(engine), orDash(j.GetTemplate()), j.IsService())
+	}
+
+	if err := w.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// Print a job as JSON after its templates and expressions have been evaluated,
+// returns the exit code.
+func show(args []string, vars map[string]string, varFiles []string) int {
+	flags := flag.NewFlagSet("show", flag.ExitOnError)
+	file := fileFlag(flags)
+	flags.Parse(args)
+
+	honePath := honefilePath(*file)
+	name := ""
+
+	if flags.NArg() == 1 {
+		name = flags.Arg(0)
+	} else if flags.NArg() == 2 {
+		honePath = flags.Arg(0)
+		name = flags.Arg(1)
+	} else {
+		fmt.Fprintln(os.Stderr, "Usage: hone show [-f Honefile] [Honefile] <job>")
+		return 1
+	}
+
+	config, err := loadConfig(honePath, vars, varFiles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	for _, j := range config.Jobs {
+		if j.GetName() != name {
+			continue
+		}
+
+		value, err := j.ToCty()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		encoded, err := ctyjson.Marshal(value, value.Type())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, encoded, "", "  "); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		fmt.Println(out.String())
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "Job %s not found.\n", name)
+	return 1
+}
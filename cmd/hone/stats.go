@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/reporting"
+)
+
+// Format a number of bytes for display.
+func formatBytes(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	value := float64(n)
+
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f%s", value, units[unit])
+}
+
+// Print the critical path of the latest build and the slowest jobs across recent
+// builds, read from the given report.json files or URLs or from the branch's
+// history in the configured cache. Returns the exit code.
+func stats(args []string, vars map[string]string, varFiles []string) int {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	branch := flags.String("branch", "", "The branch to read builds of, defaults to the current branch.")
+	limit := flags.Int("n", 10, "The number of recent builds to read from the history.")
+	top := flags.Int("top", 10, "The number of slowest jobs to show.")
+	file := fileFlag(flags)
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		c, err := historyCache(honefilePath(*file), vars, varFiles)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		*branch = historyBranch(*branch)
+
+		entries, err := reporting.LoadHistory(c, *branch)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		if *limit > 0 && len(entries) > *limit {
+			entries = entries[len(entries)-*limit:]
+		}
+
+		for _, entry := range entries {
+			paths = append(paths, entry.ReportURL)
+		}
+
+		if len(paths) == 0 {
+			fmt.Fprintf(os.Stderr, "No builds found for branch %s.\n", *branch)
+			return 1
+		}
+	}
+
+	builds := []reporting.BuildRecord{}
+	for _, path := range paths {
+		build, err := reporting.LoadBuild(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		builds = append(builds, build)
+	}
+
+	latest := builds[0]
+	for _, build := range builds[1:] {
+		if build.StartTime.After(latest.StartTime) {
+			latest = build
+		}
+	}
+
+	round := func(d time.Duration) time.Duration {
+		return d.Round(100 * time.Millisecond)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Critical path of build %s (%s), %s:\n", latest.BuildID, latest.GitCommit, round(latest.EndTime.Sub(latest.StartTime)))
+	fmt.Fprintln(w, "JOB\tSTART\tDURATION\tWAITED\tCACHE\tENGINE")
+
+	for _, j := range latest.CriticalPath() {
+		engine := j.Stats.Engine
+		if j.Cached {
+			engine = "cached"
+		}
+
+		waited := "-"
+		if !j.QueuedTime.IsZero() {
+			waited = round(j.StartTime.Sub(j.QueuedTime)).String()
+		}
+
+		fmt.Fprintf(w, "%s\t+%s\t%s\t%s\t%s\t%s\n", j.Name, round(j.StartTime.Sub(latest.StartTime)),
+			round(j.Duration()), waited,
+			round(j.Stats.CacheRestore+j.Stats.CacheUpload), orDash(engine))
+	}
+
+	fmt.Fprintf(w, "\nSlowest jobs across %d builds:\n", len(builds))
+	fmt.Fprintln(w, "JOB\tRUNS\tCACHED\tFAILED\tAVERAGE\tMAX\tPEAK MEMORY")
+
+	for i, s := range reporting.SlowestJobs(builds) {
+		if i == *top {
+			break
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t%s\n", s.Name, s.Runs, s.Cached, s.Failed,
+			round(s.Average()), round(s.Max), formatBytes(s.PeakMemory))
+	}
+
+	if err := w.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
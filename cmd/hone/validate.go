@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/justinbarrick/hone/pkg/config"
+)
+
+// Validate a Honefile and print any problems found, returns the exit code.
+func validate(args []string, vars map[string]string, varFiles []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "Print diagnostics as JSON.")
+	file := fileFlag(flags)
+	flags.Parse(args)
+
+	honePath, targets := parseArgs(*file, flags.Args())
+	explicitTargets := len(targets) > 0
+	if !explicitTargets {
+		targets = []string{"all"}
+	}
+
+	parser := config.NewParser()
+	parser.Vars = vars
+	parser.VarFiles = varFiles
+	parser.Quiet = true
+	parser.SkipSecrets = true
+
+	var diags hcl.Diagnostics
+	if err := parser.ParseFile(honePath); err != nil {
+		if parseDiags, ok := err.(hcl.Diagnostics); ok {
+			diags = parseDiags
+		} else {
+			diags = hcl.Diagnostics{&hcl.Diagnostic{Severity: hcl.DiagError, Summary: err.Error()}}
+		}
+	} else {
+		diags = parser.Validate(targets, explicitTargets)
+	}
+
+	if *jsonOutput {
+		encoded, err := config.MarshalDiagnostics(diags)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(string(encoded))
+	} else {
+		wr := hcl.NewDiagnosticTextWriter(os.Stdout, parser.Files(), 78, true)
+		wr.WriteDiagnostics(diags)
+
+		if parser.Path() != "" {
+			honePath = parser.Path()
+		}
+
+		errors := len(diags.Errs())
+		warnings := len(diags) - errors
+
+		if errors == 0 {
+			fmt.Printf("%s is valid (%d warnings).\n", honePath, warnings)
+		} else {
+			fmt.Printf("%s has %d errors and %d warnings.\n", honePath, errors, warnings)
+		}
+	}
+
+	if diags.HasErrors() {
+		return 1
+	}
+
+	return 0
+}
@@ -12,7 +12,7 @@ import (
 )
 
 type DockerAuth struct {
-	Auth string `json"auth"`
+	Auth string `json:"auth"`
 }
 
 type DockerConfig struct {
@@ -5,7 +5,7 @@ env = [
 job "packer_build" {
     image = "hashicorp/packer"
 
-    input = "packer/*"
+    inputs = ["packer/*"]
 
     outputs = [
         "VERSION"
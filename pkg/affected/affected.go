@@ -0,0 +1,71 @@
+package affected
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/justinbarrick/hone/pkg/graph"
+	"github.com/justinbarrick/hone/pkg/graph/node"
+	"github.com/justinbarrick/hone/pkg/hermetic"
+	"github.com/justinbarrick/hone/pkg/job"
+)
+
+// Convert paths relative to root into paths relative to dir, paths outside of
+// dir are dropped.
+func RelativeTo(root, dir string, paths []string) ([]string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	relative := []string{}
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, filepath.Join(root, path))
+		if err != nil {
+			return nil, err
+		}
+
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+
+		relative = append(relative, rel)
+	}
+
+	return relative, nil
+}
+
+// Return the names of the jobs that have an input matching one of the changed
+// files.
+func Matching(jobs []*job.Job, changed []string) []string {
+	matching := []string{}
+
+	for _, j := range jobs {
+		for _, path := range changed {
+			if hermetic.Match(j.GetInputs(), path) {
+				matching = append(matching, j.GetName())
+				break
+			}
+		}
+	}
+
+	return matching
+}
+
+// Return the names of the jobs affected by the changed files: the jobs with a
+// matching input and every job that depends on them.
+func Jobs(jobs []*job.Job, changed []string) ([]string, error) {
+	nodes := []node.Node{}
+	for _, j := range jobs {
+		nodes = append(nodes, j)
+	}
+
+	g := graph.NewGraph(nodes)
+	return g.Dependents(Matching(jobs, changed))
+}
@@ -0,0 +1,62 @@
+package affected
+
+import (
+	"testing"
+
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func newJob(name string, inputs []string, deps ...string) *job.Job {
+	set := job.StringSet(inputs)
+	j := &job.Job{
+		Name:   name,
+		Inputs: &set,
+	}
+
+	for _, dep := range deps {
+		j.AddDep(dep)
+	}
+
+	return j
+}
+
+func testJobs() []*job.Job {
+	return []*job.Job{
+		newJob("generate", []string{"./schema/*.json"}),
+		newJob("build", []string{"./cmd/", "./pkg/**/*.go", "go.mod"}, "generate"),
+		newJob("docs", []string{"docs/"}),
+		newJob("image", []string{"Dockerfile"}, "build"),
+		newJob("release", nil, "image", "docs"),
+	}
+}
+
+func TestRelativeTo(t *testing.T) {
+	paths, err := RelativeTo("/repo", "/repo/services/api", []string{"services/api/main.go", "services/web/main.go", "go.mod"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"main.go"}, paths)
+
+	paths, err = RelativeTo("/repo", "/repo", []string{"go.mod"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"go.mod"}, paths)
+}
+
+func TestMatching(t *testing.T) {
+	assert.Equal(t, []string{"build"}, Matching(testJobs(), []string{"pkg/config/config.go"}))
+	assert.Equal(t, []string{"build", "docs"}, Matching(testJobs(), []string{"cmd/hone/main.go", "docs/index.md"}))
+	assert.Equal(t, []string{}, Matching(testJobs(), []string{"README.md"}))
+}
+
+func TestJobs(t *testing.T) {
+	affected, err := Jobs(testJobs(), []string{"schema/job.json"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"build", "generate", "image", "release"}, affected)
+
+	affected, err = Jobs(testJobs(), []string{"docs/index.md"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"docs", "release"}, affected)
+
+	affected, err = Jobs(testJobs(), []string{"README.md"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, affected)
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/bmatcuk/doublestar"
 	"github.com/cnf/structhash"
@@ -31,13 +32,38 @@ type Cache interface {
 	Enabled() bool
 	BaseURL() string
 	Writer(string, string) (io.WriteCloser, string, error)
+	Reader(string, string) (io.ReadCloser, error)
 }
 
 func WalkInputs(inputs []string, fn func(string) error) error {
+	return WalkInputsIn("", inputs, fn)
+}
+
+func WalkInputsIn(dir string, inputs []string, fn func(string) error) error {
+	join := func(path string) string {
+		if dir == "" {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	callback := func(path string) error {
+		if dir == "" {
+			return fn(path)
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		return fn(rel)
+	}
+
 	for _, input := range inputs {
-		inputFile, err := os.Open(input)
+		inputFile, err := os.Open(join(input))
 		if err != nil && os.IsNotExist(err) {
-			matches, err := doublestar.Glob(input)
+			matches, err := doublestar.Glob(join(input))
 			if err != nil {
 				return err
 			}
@@ -50,6 +76,7 @@ func WalkInputs(inputs []string, fn func(string) error) error {
 					continue
 				}
 				fi, err := inputFile.Stat()
+				inputFile.Close()
 				if err != nil {
 					continue
 				}
@@ -58,7 +85,7 @@ func WalkInputs(inputs []string, fn func(string) error) error {
 					continue
 				}
 
-				err = fn(match)
+				err = callback(match)
 				if err != nil {
 					return err
 				}
@@ -70,13 +97,14 @@ func WalkInputs(inputs []string, fn func(string) error) error {
 		}
 
 		fi, err := inputFile.Stat()
+		inputFile.Close()
 		switch {
 		case err != nil:
 			return err
 		case fi.IsDir():
-			err = filepath.Walk(input, func(path string, info os.FileInfo, err error) error {
+			err = filepath.Walk(join(input), func(path string, info os.FileInfo, err error) error {
 				if !info.IsDir() {
-					return fn(path)
+					return callback(path)
 				}
 				return nil
 			})
@@ -84,7 +112,7 @@ func WalkInputs(inputs []string, fn func(string) error) error {
 				return err
 			}
 		default:
-			err = fn(input)
+			err = callback(join(input))
 			if err != nil {
 				return err
 			}
@@ -99,6 +127,10 @@ func HashJob(job *config.Job) (string, error) {
 
 	sum.Write(structhash.Sha1(job, 1))
 
+	if job.ImageDigest != "" {
+		sum.Write([]byte(job.ImageDigest))
+	}
+
 	err := WalkInputs(job.GetInputs(), func(path string) error {
 		data, err := ioutil.ReadFile(path)
 		if err != nil {
@@ -148,7 +180,7 @@ func (c CacheEntry) SyncAttrs() error {
 
 func CacheJob(c Cache, callback func(*config.Job) error) func(*config.Job) error {
 	return func(job *config.Job) error {
-		if job.IsService() {
+		if job.IsService() || job.Aggregate {
 			return callback(job)
 		}
 
@@ -159,7 +191,9 @@ func CacheJob(c Cache, callback func(*config.Job) error) func(*config.Job) error
 
 		job.Hash = cacheKey
 
+		restoreStart := time.Now()
 		cached, err := LoadCache(c, cacheKey, job)
+		job.Stats.CacheRestore += time.Since(restoreStart)
 		if err != nil {
 			return err
 		}
@@ -189,7 +223,9 @@ func CacheJob(c Cache, callback func(*config.Job) error) func(*config.Job) error
 			return err
 		}
 
+		uploadStart := time.Now()
 		entries, err := DumpOutputs(cacheKey, c, outputs)
+		job.Stats.CacheUpload += time.Since(uploadStart)
 		if err != nil {
 			return err
 		}
@@ -200,6 +236,10 @@ func CacheJob(c Cache, callback func(*config.Job) error) func(*config.Job) error
 
 		for _, entry := range entries {
 			job.OutputHashes[entry.Filename] = entry.Hash
+
+			if fi, err := os.Stat(entry.Filename); err == nil {
+				job.Stats.BytesUploaded += fi.Size()
+			}
 		}
 
 		return nil
@@ -234,6 +274,10 @@ func LoadCache(c Cache, cacheKey string, job *config.Job) (bool, error) {
 				if err != nil {
 					return false, err
 				}
+
+				if fi, err := os.Stat(entry.Filename); err == nil {
+					job.Stats.BytesDownloaded += fi.Size()
+				}
 				logger.LogDebug(job, fmt.Sprintf("Loaded %s from cache (%s).", entry.Filename, c.Name()))
 			}
 		}
@@ -8,7 +8,7 @@ import (
 )
 
 func TestHashJob(t *testing.T) {
-	expected := "7b8bc47735cb57cce88991bc559dc50f62db66cc26e42a593a573ac76464c326"
+	expected := "6a0e4a2bf0f8c7d894ec1aa67843840224814860c10b3c06b47b5726c4db972b"
 
 	j1 := &job.Job{
 		Name: "hello",
@@ -37,3 +37,91 @@ func TestHashJob(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, hash, expected)
 }
+
+func TestHashJobImageDigest(t *testing.T) {
+	image := "alpine:latest"
+
+	j := &job.Job{
+		Name:  "hello",
+		Image: &image,
+	}
+
+	unpinned, err := HashJob(j)
+	assert.Nil(t, err)
+
+	j.ImageDigest = "alpine@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	pinned, err := HashJob(j)
+	assert.Nil(t, err)
+	assert.NotEqual(t, unpinned, pinned)
+
+	j.ImageDigest = "alpine@sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	moved, err := HashJob(j)
+	assert.Nil(t, err)
+	assert.NotEqual(t, pinned, moved)
+}
+
+func TestHashJobDescription(t *testing.T) {
+	j := &job.Job{
+		Name: "hello",
+	}
+
+	before, err := HashJob(j)
+	assert.Nil(t, err)
+
+	description := "Say hello."
+	j.Description = &description
+
+	after, err := HashJob(j)
+	assert.Nil(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestHashJobVolumes(t *testing.T) {
+	j := &job.Job{
+		Name: "hello",
+	}
+
+	before, err := HashJob(j)
+	assert.Nil(t, err)
+
+	j.Volumes = &job.StringSet{"./config:/etc/app:ro"}
+	mounted, err := HashJob(j)
+	assert.Nil(t, err)
+	assert.NotEqual(t, before, mounted)
+
+	j.CacheVolumes = &map[string]string{"gocache": "/root/.cache/go-build"}
+	cached, err := HashJob(j)
+	assert.Nil(t, err)
+	assert.Equal(t, mounted, cached)
+}
+
+func TestHashJobInterpreter(t *testing.T) {
+	j := &job.Job{
+		Name: "hello",
+	}
+
+	before, err := HashJob(j)
+	assert.Nil(t, err)
+
+	j.Interpreter = &[]string{"python3", "-c"}
+	python, err := HashJob(j)
+	assert.Nil(t, err)
+	assert.NotEqual(t, before, python)
+
+	j.Interpreter = &[]string{"-c", "python3"}
+	reordered, err := HashJob(j)
+	assert.Nil(t, err)
+	assert.NotEqual(t, python, reordered)
+
+	user := "nobody"
+	j.User = &user
+	asUser, err := HashJob(j)
+	assert.Nil(t, err)
+	assert.NotEqual(t, reordered, asUser)
+
+	sandbox := true
+	j.Sandbox = &sandbox
+	sandboxed, err := HashJob(j)
+	assert.Nil(t, err)
+	assert.NotEqual(t, asUser, sandboxed)
+}
@@ -142,10 +142,14 @@ func (c *FileCache) Writer(namespace string, filename string) (io.WriteCloser, s
 		return nil, path, err
 	}
 
-	outFile, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	outFile, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return nil, path, err
 	}
 
 	return outFile, path, nil
 }
+
+func (c *FileCache) Reader(namespace string, filename string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(c.CacheDir, namespace, filename))
+}
@@ -231,3 +231,21 @@ func (c *S3Cache) Writer(namespace string, filename string) (io.WriteCloser, str
 	url := writer.Init(c, namespace, filename)
 	return writer, url, nil
 }
+
+// Open a file in the bucket, returns os.ErrNotExist if it does not exist.
+func (c *S3Cache) Reader(namespace string, filename string) (io.ReadCloser, error) {
+	object, err := c.s3.GetObject(c.Bucket, filepath.Join(namespace, filename), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+
+	return object, nil
+}
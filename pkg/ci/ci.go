@@ -0,0 +1,284 @@
+package ci
+
+import (
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Information about the current build that a CI system exposes in its environment.
+type Build struct {
+	Provider    string `cty:"provider"`
+	SCM         string `cty:"scm"`
+	Branch      string `cty:"branch"`
+	Tag         string `cty:"tag"`
+	Commit      string `cty:"commit"`
+	PullRequest string `cty:"pull_request"`
+	BaseBranch  string `cty:"base_branch"`
+	BuildURL    string `cty:"build_url"`
+	Repo        string `cty:"repo"`
+}
+
+// A detector returns the build if the environment belongs to its CI system, or
+// nil.
+type Detector func(env map[string]string) *Build
+
+// Detectors in the order they are tried.
+var Detectors = []Detector{
+	GithubActions,
+	Gitlab,
+	Drone,
+	Jenkins,
+	CircleCI,
+	Buildkite,
+	Prow,
+}
+
+// Return the process environment as a map.
+func Environ() map[string]string {
+	env := map[string]string{}
+
+	for _, pair := range os.Environ() {
+		split := strings.SplitN(pair, "=", 2)
+		if len(split) == 2 {
+			env[split[0]] = split[1]
+		}
+	}
+
+	return env
+}
+
+// Detect the CI system from the environment, returns nil if none was detected.
+func Detect(env map[string]string) *Build {
+	for _, detector := range Detectors {
+		if build := detector(env); build != nil {
+			return build
+		}
+	}
+
+	return nil
+}
+
+// Return the build's variables for conditions and the environment.
+func (b *Build) Env() map[string]string {
+	if b == nil {
+		b = &Build{}
+	}
+
+	return map[string]string{
+		"CI_PROVIDER":     b.Provider,
+		"CI_PULL_REQUEST": b.PullRequest,
+		"CI_BASE_BRANCH":  b.BaseBranch,
+		"CI_BUILD_URL":    b.BuildURL,
+		"CI_REPO":         b.Repo,
+	}
+}
+
+// Return the first non-empty value.
+func first(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// Return the owner/name slug of a repository URL, supporting both URLs and
+// scp-style ssh remotes.
+func slug(repoURL string) string {
+	if repoURL == "" {
+		return ""
+	}
+
+	if !regexp.MustCompile("^[a-z]+://").MatchString(repoURL) {
+		repoURL = "ssh://" + strings.Replace(repoURL, ":", "/", 1)
+	}
+
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".git")
+}
+
+func GithubActions(env map[string]string) *Build {
+	if env["GITHUB_ACTIONS"] != "true" {
+		return nil
+	}
+
+	build := &Build{
+		Provider:   "github-actions",
+		SCM:        "github",
+		Commit:     env["GITHUB_SHA"],
+		BaseBranch: env["GITHUB_BASE_REF"],
+		Repo:       env["GITHUB_REPOSITORY"],
+	}
+
+	ref := env["GITHUB_REF"]
+
+	switch {
+	case strings.HasPrefix(ref, "refs/heads/"):
+		build.Branch = strings.TrimPrefix(ref, "refs/heads/")
+	case strings.HasPrefix(ref, "refs/tags/"):
+		build.Tag = strings.TrimPrefix(ref, "refs/tags/")
+	case strings.HasPrefix(ref, "refs/pull/"):
+		build.PullRequest = strings.Split(strings.TrimPrefix(ref, "refs/pull/"), "/")[0]
+	}
+
+	build.Branch = first(env["GITHUB_HEAD_REF"], build.Branch)
+
+	if build.Repo != "" && env["GITHUB_RUN_ID"] != "" {
+		server := first(env["GITHUB_SERVER_URL"], "https://github.com")
+		build.BuildURL = server + "/" + build.Repo + "/actions/runs/" + env["GITHUB_RUN_ID"]
+	}
+
+	return build
+}
+
+func Gitlab(env map[string]string) *Build {
+	if env["GITLAB_CI"] != "true" {
+		return nil
+	}
+
+	build := &Build{
+		Provider:    "gitlab",
+		SCM:         "gitlab",
+		Tag:         env["CI_COMMIT_TAG"],
+		Commit:      env["CI_COMMIT_SHA"],
+		PullRequest: env["CI_MERGE_REQUEST_IID"],
+		BaseBranch:  env["CI_MERGE_REQUEST_TARGET_BRANCH_NAME"],
+		BuildURL:    env["CI_PIPELINE_URL"],
+		Repo:        env["CI_PROJECT_PATH"],
+	}
+
+	if build.Tag == "" {
+		build.Branch = first(env["CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"], env["CI_COMMIT_BRANCH"], env["CI_COMMIT_REF_NAME"])
+	}
+
+	return build
+}
+
+func Drone(env map[string]string) *Build {
+	if env["DRONE"] != "true" {
+		return nil
+	}
+
+	build := &Build{
+		Provider:    "drone",
+		Tag:         env["DRONE_TAG"],
+		Commit:      first(env["DRONE_COMMIT_SHA"], env["DRONE_COMMIT"]),
+		PullRequest: env["DRONE_PULL_REQUEST"],
+		BuildURL:    env["DRONE_BUILD_LINK"],
+		Repo:        env["DRONE_REPO"],
+	}
+
+	if build.Tag == "" {
+		build.Branch = first(env["DRONE_SOURCE_BRANCH"], env["DRONE_BRANCH"])
+	}
+
+	if build.PullRequest != "" {
+		build.BaseBranch = first(env["DRONE_TARGET_BRANCH"], env["DRONE_BRANCH"])
+	}
+
+	return build
+}
+
+func Jenkins(env map[string]string) *Build {
+	if env["JENKINS_URL"] == "" {
+		return nil
+	}
+
+	build := &Build{
+		Provider:    "jenkins",
+		Tag:         env["TAG_NAME"],
+		Commit:      env["GIT_COMMIT"],
+		PullRequest: env["CHANGE_ID"],
+		BaseBranch:  env["CHANGE_TARGET"],
+		BuildURL:    env["BUILD_URL"],
+		Repo:        slug(env["GIT_URL"]),
+	}
+
+	if build.Tag == "" {
+		build.Branch = first(env["CHANGE_BRANCH"], env["BRANCH_NAME"], strings.TrimPrefix(env["GIT_BRANCH"], "origin/"))
+	}
+
+	return build
+}
+
+func CircleCI(env map[string]string) *Build {
+	if env["CIRCLECI"] != "true" {
+		return nil
+	}
+
+	build := &Build{
+		Provider:    "circleci",
+		Branch:      env["CIRCLE_BRANCH"],
+		Tag:         env["CIRCLE_TAG"],
+		Commit:      env["CIRCLE_SHA1"],
+		PullRequest: env["CIRCLE_PR_NUMBER"],
+		BuildURL:    env["CIRCLE_BUILD_URL"],
+	}
+
+	if build.PullRequest == "" && env["CIRCLE_PULL_REQUEST"] != "" {
+		parts := strings.Split(env["CIRCLE_PULL_REQUEST"], "/")
+		build.PullRequest = parts[len(parts)-1]
+	}
+
+	if env["CIRCLE_PROJECT_USERNAME"] != "" && env["CIRCLE_PROJECT_REPONAME"] != "" {
+		build.Repo = env["CIRCLE_PROJECT_USERNAME"] + "/" + env["CIRCLE_PROJECT_REPONAME"]
+	}
+
+	return build
+}
+
+func Buildkite(env map[string]string) *Build {
+	if env["BUILDKITE"] != "true" {
+		return nil
+	}
+
+	build := &Build{
+		Provider: "buildkite",
+		Branch:   env["BUILDKITE_BRANCH"],
+		Tag:      env["BUILDKITE_TAG"],
+		Commit:   env["BUILDKITE_COMMIT"],
+		BuildURL: env["BUILDKITE_BUILD_URL"],
+		Repo:     slug(env["BUILDKITE_REPO"]),
+	}
+
+	if pr := env["BUILDKITE_PULL_REQUEST"]; pr != "" && pr != "false" {
+		build.PullRequest = pr
+		build.BaseBranch = env["BUILDKITE_PULL_REQUEST_BASE_BRANCH"]
+	}
+
+	return build
+}
+
+func Prow(env map[string]string) *Build {
+	if env["PROW_JOB_ID"] == "" && (env["REPO_OWNER"] == "" || env["REPO_NAME"] == "") {
+		return nil
+	}
+
+	build := &Build{
+		Provider:    "prow",
+		SCM:         "github",
+		Commit:      first(env["PULL_PULL_SHA"], env["PULL_BASE_SHA"]),
+		PullRequest: env["PULL_NUMBER"],
+	}
+
+	if build.PullRequest != "" {
+		build.Branch = env["PULL_HEAD_REF"]
+		build.BaseBranch = env["PULL_BASE_REF"]
+	} else {
+		build.Branch = env["PULL_BASE_REF"]
+	}
+
+	if env["REPO_OWNER"] != "" && env["REPO_NAME"] != "" {
+		build.Repo = env["REPO_OWNER"] + "/" + env["REPO_NAME"]
+	}
+
+	return build
+}
@@ -0,0 +1,234 @@
+package ci
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectNone(t *testing.T) {
+	assert.Nil(t, Detect(map[string]string{
+		"HOME": "/root",
+	}))
+}
+
+func TestGithubActions(t *testing.T) {
+	build := Detect(map[string]string{
+		"GITHUB_ACTIONS":    "true",
+		"GITHUB_REF":        "refs/pull/42/merge",
+		"GITHUB_HEAD_REF":   "my-feature",
+		"GITHUB_BASE_REF":   "master",
+		"GITHUB_SHA":        "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		"GITHUB_REPOSITORY": "justinbarrick/hone",
+		"GITHUB_RUN_ID":     "1234",
+	})
+
+	assert.Equal(t, &Build{
+		Provider:    "github-actions",
+		SCM:         "github",
+		Branch:      "my-feature",
+		Commit:      "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		PullRequest: "42",
+		BaseBranch:  "master",
+		BuildURL:    "https://github.com/justinbarrick/hone/actions/runs/1234",
+		Repo:        "justinbarrick/hone",
+	}, build)
+
+	build = Detect(map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_REF":     "refs/tags/v1.0.0",
+	})
+	assert.Equal(t, "v1.0.0", build.Tag)
+	assert.Equal(t, "", build.Branch)
+
+	build = Detect(map[string]string{
+		"GITHUB_ACTIONS": "true",
+		"GITHUB_REF":     "refs/heads/master",
+	})
+	assert.Equal(t, "master", build.Branch)
+	assert.Equal(t, "", build.PullRequest)
+}
+
+func TestGitlab(t *testing.T) {
+	build := Detect(map[string]string{
+		"GITLAB_CI":                           "true",
+		"CI_COMMIT_REF_NAME":                  "my-feature",
+		"CI_MERGE_REQUEST_SOURCE_BRANCH_NAME": "my-feature",
+		"CI_MERGE_REQUEST_TARGET_BRANCH_NAME": "master",
+		"CI_MERGE_REQUEST_IID":                "7",
+		"CI_COMMIT_SHA":                       "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		"CI_PIPELINE_URL":                     "https://gitlab.com/group/sub/project/pipelines/99",
+		"CI_PROJECT_PATH":                     "group/sub/project",
+	})
+
+	assert.Equal(t, &Build{
+		Provider:    "gitlab",
+		SCM:         "gitlab",
+		Branch:      "my-feature",
+		Commit:      "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		PullRequest: "7",
+		BaseBranch:  "master",
+		BuildURL:    "https://gitlab.com/group/sub/project/pipelines/99",
+		Repo:        "group/sub/project",
+	}, build)
+
+	build = Detect(map[string]string{
+		"GITLAB_CI":          "true",
+		"CI_COMMIT_REF_NAME": "v1.0.0",
+		"CI_COMMIT_TAG":      "v1.0.0",
+	})
+	assert.Equal(t, "v1.0.0", build.Tag)
+	assert.Equal(t, "", build.Branch)
+}
+
+func TestDrone(t *testing.T) {
+	build := Detect(map[string]string{
+		"DRONE":               "true",
+		"DRONE_BRANCH":        "master",
+		"DRONE_SOURCE_BRANCH": "my-feature",
+		"DRONE_TARGET_BRANCH": "master",
+		"DRONE_PULL_REQUEST":  "3",
+		"DRONE_COMMIT_SHA":    "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		"DRONE_BUILD_LINK":    "https://drone.example.com/justinbarrick/hone/5",
+		"DRONE_REPO":          "justinbarrick/hone",
+	})
+
+	assert.Equal(t, &Build{
+		Provider:    "drone",
+		Branch:      "my-feature",
+		Commit:      "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		PullRequest: "3",
+		BaseBranch:  "master",
+		BuildURL:    "https://drone.example.com/justinbarrick/hone/5",
+		Repo:        "justinbarrick/hone",
+	}, build)
+
+	build = Detect(map[string]string{
+		"DRONE":        "true",
+		"DRONE_BRANCH": "master",
+	})
+	assert.Equal(t, "master", build.Branch)
+	assert.Equal(t, "", build.BaseBranch)
+}
+
+func TestJenkins(t *testing.T) {
+	build := Detect(map[string]string{
+		"JENKINS_URL":   "https://jenkins.example.com/",
+		"BRANCH_NAME":   "PR-12",
+		"CHANGE_ID":     "12",
+		"CHANGE_BRANCH": "my-feature",
+		"CHANGE_TARGET": "master",
+		"GIT_COMMIT":    "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		"GIT_URL":       "git@github.com:justinbarrick/hone.git",
+		"BUILD_URL":     "https://jenkins.example.com/job/hone/12/",
+	})
+
+	assert.Equal(t, &Build{
+		Provider:    "jenkins",
+		Branch:      "my-feature",
+		Commit:      "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		PullRequest: "12",
+		BaseBranch:  "master",
+		BuildURL:    "https://jenkins.example.com/job/hone/12/",
+		Repo:        "justinbarrick/hone",
+	}, build)
+
+	build = Detect(map[string]string{
+		"JENKINS_URL": "https://jenkins.example.com/",
+		"GIT_BRANCH":  "origin/master",
+		"GIT_URL":     "https://github.com/justinbarrick/hone",
+	})
+	assert.Equal(t, "master", build.Branch)
+	assert.Equal(t, "justinbarrick/hone", build.Repo)
+}
+
+func TestCircleCI(t *testing.T) {
+	build := Detect(map[string]string{
+		"CIRCLECI":                "true",
+		"CIRCLE_BRANCH":           "my-feature",
+		"CIRCLE_PULL_REQUEST":     "https://github.com/justinbarrick/hone/pull/8",
+		"CIRCLE_SHA1":             "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		"CIRCLE_BUILD_URL":        "https://circleci.com/gh/justinbarrick/hone/20",
+		"CIRCLE_PROJECT_USERNAME": "justinbarrick",
+		"CIRCLE_PROJECT_REPONAME": "hone",
+	})
+
+	assert.Equal(t, &Build{
+		Provider:    "circleci",
+		Branch:      "my-feature",
+		Commit:      "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		PullRequest: "8",
+		BuildURL:    "https://circleci.com/gh/justinbarrick/hone/20",
+		Repo:        "justinbarrick/hone",
+	}, build)
+}
+
+func TestBuildkite(t *testing.T) {
+	build := Detect(map[string]string{
+		"BUILDKITE":                          "true",
+		"BUILDKITE_BRANCH":                   "my-feature",
+		"BUILDKITE_PULL_REQUEST":             "15",
+		"BUILDKITE_PULL_REQUEST_BASE_BRANCH": "master",
+		"BUILDKITE_COMMIT":                   "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		"BUILDKITE_BUILD_URL":                "https://buildkite.com/org/hone/builds/30",
+		"BUILDKITE_REPO":                     "git@github.com:justinbarrick/hone.git",
+	})
+
+	assert.Equal(t, &Build{
+		Provider:    "buildkite",
+		Branch:      "my-feature",
+		Commit:      "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		PullRequest: "15",
+		BaseBranch:  "master",
+		BuildURL:    "https://buildkite.com/org/hone/builds/30",
+		Repo:        "justinbarrick/hone",
+	}, build)
+
+	build = Detect(map[string]string{
+		"BUILDKITE":                          "true",
+		"BUILDKITE_PULL_REQUEST":             "false",
+		"BUILDKITE_PULL_REQUEST_BASE_BRANCH": "",
+	})
+	assert.Equal(t, "", build.PullRequest)
+}
+
+func TestProw(t *testing.T) {
+	build := Detect(map[string]string{
+		"PROW_JOB_ID":   "abc",
+		"REPO_OWNER":    "justinbarrick",
+		"REPO_NAME":     "hone",
+		"PULL_BASE_REF": "master",
+		"PULL_BASE_SHA": "45f7c4bc1e422d450f791b1ebe844866dd6f837f",
+		"PULL_NUMBER":   "21",
+		"PULL_PULL_SHA": "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+	})
+
+	assert.Equal(t, &Build{
+		Provider:    "prow",
+		SCM:         "github",
+		Commit:      "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		PullRequest: "21",
+		BaseBranch:  "master",
+		Repo:        "justinbarrick/hone",
+	}, build)
+
+	build = Detect(map[string]string{
+		"REPO_OWNER":    "justinbarrick",
+		"REPO_NAME":     "hone",
+		"PULL_BASE_REF": "master",
+	})
+	assert.Equal(t, "master", build.Branch)
+	assert.Equal(t, "", build.BaseBranch)
+}
+
+func TestBuildEnv(t *testing.T) {
+	var build *Build
+	assert.Equal(t, "", build.Env()["CI_PROVIDER"])
+
+	build = &Build{
+		Provider:    "drone",
+		PullRequest: "3",
+	}
+	assert.Equal(t, "drone", build.Env()["CI_PROVIDER"])
+	assert.Equal(t, "3", build.Env()["CI_PULL_REQUEST"])
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,13 +12,16 @@ import (
 	"github.com/hashicorp/hcl2/hcl"
 	"github.com/hashicorp/hcl2/hclparse"
 	"github.com/justinbarrick/hone/pkg/cache/file"
+	"github.com/justinbarrick/hone/pkg/ci"
 	"github.com/justinbarrick/hone/pkg/config/types"
+	"github.com/justinbarrick/hone/pkg/executors/docker"
 	"github.com/justinbarrick/hone/pkg/executors/kubernetes"
 	"github.com/justinbarrick/hone/pkg/git"
 	"github.com/justinbarrick/hone/pkg/graph"
 	"github.com/justinbarrick/hone/pkg/graph/node"
 	"github.com/justinbarrick/hone/pkg/job"
 	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/justinbarrick/hone/pkg/notify"
 	"github.com/justinbarrick/hone/pkg/scm"
 	"github.com/justinbarrick/hone/pkg/secrets/vault"
 	"github.com/zclconf/go-cty/cty"
@@ -31,10 +35,22 @@ type Remains interface {
 }
 
 type Parser struct {
-	parser *hclparse.Parser
-	body   hcl.Body
-	remain hcl.Body
-	ctx    *hcl.EvalContext
+	Vars        map[string]string
+	VarFiles    []string
+	Quiet       bool
+	SkipSecrets bool
+
+	parser  *hclparse.Parser
+	path    string
+	body    hcl.Body
+	remain  hcl.Body
+	ctx     *hcl.EvalContext
+	decoded map[string]*job.Job
+	views   map[string]map[string]cty.Value
+	module  string
+	dir     string
+	git     git.Metadata
+	ci      *ci.Build
 }
 
 func NewParser() Parser {
@@ -50,21 +66,44 @@ func (p *Parser) Parse(config string) error {
 	return p.checkErrors(diags)
 }
 
+// Parse a Honefile, if path is a directory the Honefile in it is found with
+// FindHonefile.
 func (p *Parser) ParseFile(path string) error {
-	hclFile, diags := p.parser.ParseHCLFile(path)
-	if hclFile != nil {
-		p.remain = hclFile.Body
-		p.body = hclFile.Body
+	path, err := FindHonefile(path)
+	if err != nil {
+		return err
 	}
-	return p.checkErrors(diags)
+
+	p.path = path
+
+	body, err := p.loadFile(path)
+	if err != nil {
+		return err
+	}
+
+	p.remain = body
+	p.body = body
+	return nil
+}
+
+// Return the path of the Honefile that was parsed.
+func (p *Parser) Path() string {
+	return p.path
+}
+
+// Return the files that have been parsed, keyed by filename.
+func (p *Parser) Files() map[string]*hcl.File {
+	return p.parser.Files()
 }
 
 func (p *Parser) checkErrors(err error) error {
 	switch e := err.(type) {
 	case hcl.Diagnostics:
 		if e.HasErrors() {
-			wr := hcl.NewDiagnosticTextWriter(os.Stderr, p.parser.Files(), 78, true)
-			wr.WriteDiagnostics(e)
+			if !p.Quiet {
+				wr := hcl.NewDiagnosticTextWriter(os.Stderr, p.parser.Files(), 78, true)
+				wr.WriteDiagnostics(e)
+			}
 			return e
 		}
 		return nil
@@ -216,6 +255,10 @@ func (p *Parser) GetContext() *hcl.EvalContext {
 				return gocty.ToCtyValue(strs, cty.List(cty.String))
 			},
 		})
+
+		for name, fn := range p.functions() {
+			p.ctx.Functions[name] = fn
+		}
 	}
 
 	if p.ctx.Variables == nil {
@@ -269,22 +312,98 @@ func (p *Parser) DecodeEnv() (map[string]string, error) {
 		}
 	}
 
+	p.git = git.Metadata{Tags: []string{}}
+	p.ci = ci.Detect(ci.Environ())
+
 	if repo, err := git.NewRepository(); err == nil {
-		for key, value := range repo.GitEnv() {
+		repo.CI = p.ci
+		p.git = repo.Metadata(p.gitFields())
+		for key, value := range p.git.Env() {
 			envMap[key] = value
 		}
 	} else {
 		logger.Printf("Failed to load git environment: %s", err)
 	}
 
+	for key, value := range p.ci.Env() {
+		envMap[key] = value
+	}
+
 	p.ctx.Variables["env"], err = gocty.ToCtyValue(envMap, cty.Map(cty.String))
 	if err != nil {
 		return envMap, err
 	}
 
+	gitType, err := gocty.ImpliedType(p.git)
+	if err != nil {
+		return envMap, err
+	}
+
+	p.ctx.Variables["git"], err = gocty.ToCtyValue(p.git, gitType)
+	if err != nil {
+		return envMap, err
+	}
+
+	build := ci.Build{}
+	if p.ci != nil {
+		build = *p.ci
+	}
+
+	ciType, err := gocty.ImpliedType(build)
+	if err != nil {
+		return envMap, err
+	}
+
+	p.ctx.Variables["ci"], err = gocty.ToCtyValue(build, ciType)
+	if err != nil {
+		return envMap, err
+	}
+
 	return envMap, nil
 }
 
+// The ways a Honefile can refer to each of the metadata fields that are only
+// collected when used.
+var gitReferences = map[string][]string{
+	"dirty":      {"GIT_DIRTY", "git.dirty"},
+	"describe":   {"GIT_DESCRIBE", "git.describe"},
+	"merge_base": {"GIT_MERGE_BASE", "git.merge_base"},
+}
+
+// Return the git metadata fields that the Honefile and the modules it loads
+// refer to. If a module cannot be loaded, every field is collected.
+func (p *Parser) gitFields() git.MetadataFields {
+	// Errors are reported when the modules are decoded.
+	quiet := p.Quiet
+	p.Quiet = true
+	defer func() {
+		p.Quiet = quiet
+	}()
+
+	if p.body != nil {
+		if err := p.loadModules(p.body, map[string]bool{}); err != nil {
+			return git.AllMetadata
+		}
+	}
+
+	referenced := map[string]bool{}
+	for _, file := range p.parser.Files() {
+		for field, references := range gitReferences {
+			for _, reference := range references {
+				if bytes.Contains(file.Bytes, []byte(reference)) {
+					referenced[field] = true
+				}
+			}
+		}
+	}
+
+	return git.MetadataFields{
+		Dirty:     referenced["dirty"],
+		Describe:  referenced["describe"],
+		MergeBase: referenced["merge_base"],
+	}
+}
+
 func (p *Parser) DecodeSecrets() (map[string]string, error) {
 	secretsMap := map[string]string{}
 
@@ -314,6 +433,18 @@ func (p *Parser) DecodeSecrets() (map[string]string, error) {
 		return secretsMap, setSecrets()
 	}
 
+	if p.SkipSecrets {
+		for _, secret := range *secretsStruct.Secrets {
+			secretSplit := strings.SplitN(secret, "=", 2)
+			secretsMap[secretSplit[0]] = ""
+			if len(secretSplit) > 1 {
+				secretsMap[secretSplit[0]] = secretSplit[1]
+			}
+		}
+
+		return secretsMap, setSecrets()
+	}
+
 	workspace := "default"
 	if secretsStruct.Workspace != nil {
 		workspace = *secretsStruct.Workspace
@@ -342,10 +473,17 @@ func (p *Parser) DecodeSecrets() (map[string]string, error) {
 }
 
 type JobPartial struct {
-	Name     string    `hcl:"name,label"`
-	Template *string   `hcl:"template"`
-	Deps     *[]string `hcl:"deps"`
-	Remain   hcl.Body  `hcl:",remain"`
+	Name         string               `hcl:"name,label"`
+	Template     *string              `hcl:"template"`
+	Deps         *[]string            `hcl:"deps"`
+	Matrix       *map[string][]string `hcl:"matrix"`
+	Remain       hcl.Body             `hcl:",remain"`
+	Module       string
+	Dir          string
+	Templates    []JobPartial
+	MatrixValues map[string]string
+	DeclRange    hcl.Range
+	Group        *Group
 }
 
 func (j JobPartial) GetDeps(p *Parser, templates []JobPartial, jobIsTemplate bool) ([]string, error) {
@@ -357,11 +495,29 @@ func (j JobPartial) GetDeps(p *Parser, templates []JobPartial, jobIsTemplate boo
 		}
 	}
 
-	attributes, diags := j.Remain.JustAttributes()
+	schema, _ := gohcl.ImpliedBodySchema(&job.Job{})
+
+	content, _, diags := j.Remain.PartialContent(schema)
 	if diags.HasErrors() {
 		return nil, diags
 	}
 
+	attributes := []*hcl.Attribute{}
+	for _, attr := range content.Attributes {
+		attributes = append(attributes, attr)
+	}
+
+	for _, block := range content.Blocks {
+		blockAttributes, diags := block.Body.JustAttributes()
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		for _, attr := range blockAttributes {
+			attributes = append(attributes, attr)
+		}
+	}
+
 	for _, attr := range attributes {
 		variables := attr.Expr.Variables()
 		for _, variable := range variables {
@@ -369,16 +525,21 @@ func (j JobPartial) GetDeps(p *Parser, templates []JobPartial, jobIsTemplate boo
 				continue
 			}
 
-			if len(variable) < 2 {
-				continue
+			parts := []string{}
+			for _, traverser := range variable[1:] {
+				attr, ok := traverser.(hcl.TraverseAttr)
+				if !ok {
+					break
+				}
+
+				parts = append(parts, attr.Name)
 			}
 
-			depName, ok := variable[1].(hcl.TraverseAttr)
-			if !ok {
+			if len(parts) == 0 {
 				continue
 			}
 
-			deps = append(deps, depName.Name)
+			deps = append(deps, strings.Join(parts, "."))
 		}
 	}
 
@@ -428,6 +589,32 @@ func (p *Parser) DecodeSCMs() ([]*scm.SCM, error) {
 	return load.Repositories, nil
 }
 
+func (p *Parser) DecodeNotifiers() ([]*notify.Notifier, error) {
+	load := struct {
+		Notifiers []*notify.Notifier `hcl:"notify,block"`
+		Remain    hcl.Body           `hcl:",remain"`
+	}{}
+
+	if err := p.DecodeBody(&load); err != nil {
+		return nil, err
+	}
+
+	return load.Notifiers, nil
+}
+
+func (p *Parser) DecodeRegistries() ([]*docker.Registry, error) {
+	load := struct {
+		Registries []*docker.Registry `hcl:"registry,block"`
+		Remain     hcl.Body           `hcl:",remain"`
+	}{}
+
+	if err := p.DecodeBody(&load); err != nil {
+		return nil, err
+	}
+
+	return load.Registries, nil
+}
+
 func (p *Parser) DecodeCache() (types.CacheConfig, error) {
 	load := struct {
 		Cache  *types.CacheConfig `hcl:"cache,block"`
@@ -492,21 +679,56 @@ func (p *Parser) TemplateForJob(job *job.Job, templates []JobPartial, jobIsTempl
 }
 
 func (p *Parser) DecodeJobs(templates []JobPartial) ([]*job.Job, error) {
-	load := struct {
-		Jobs   []JobPartial `hcl:"job,block"`
-		Remain hcl.Body     `hcl:",remain"`
-	}{}
+	loading := map[string]bool{}
+	if p.path != "" {
+		if key, err := filepath.Abs(p.path); err == nil {
+			loading[key] = true
+		}
+	}
 
-	if err := p.DecodeBody(&load); err != nil {
+	partials, err := p.decodeModule(p.body, "", "", templates, loading)
+	if err != nil {
+		return nil, err
+	}
+
+	partials, aggregates, err := expandMatrices(partials)
+	if err != nil {
 		return nil, err
 	}
 
 	g := graph.NewGraph(nil)
 
-	remains := map[string]hcl.Body{}
+	names := map[string]bool{}
+	for _, partialJob := range partials {
+		if names[partialJob.Name] {
+			return nil, fmt.Errorf("Job %s is defined more than once.", partialJob.Name)
+		}
+		names[partialJob.Name] = true
+	}
+
+	for name := range aggregates {
+		if names[name] {
+			return nil, fmt.Errorf("Job %s is defined more than once.", name)
+		}
+		names[name] = true
+	}
+
+	partials, groups := splitGroups(partials, aggregates, names)
+
+	for name, deps := range aggregates {
+		g.AddNode(aggregateNode(name, deps, groups[name]))
+	}
+
+	for _, partialJob := range partials {
+		if partialJob.Module != "" && names[partialJob.Module] {
+			return nil, fmt.Errorf("Job %s conflicts with module %s.", partialJob.Module, partialJob.Module)
+		}
+	}
+
+	partialMap := map[string]JobPartial{}
 
-	for _, partialJob := range load.Jobs {
-		remains[partialJob.Name] = partialJob.Remain
+	for _, partialJob := range partials {
+		partialMap[partialJob.Name] = partialJob
 
 		j := &job.Job{
 			Name:     partialJob.Name,
@@ -515,28 +737,69 @@ func (p *Parser) DecodeJobs(templates []JobPartial) ([]*job.Job, error) {
 
 		g.AddNode(j)
 
-		deps, err := partialJob.GetDeps(p, templates, false)
+		deps, err := partialJob.GetDeps(p, partialJob.Templates, false)
 		if err != nil {
 			return nil, err
 		}
 
 		for _, dep := range deps {
-			j.AddDep(dep)
+			j.AddDep(resolveDep(partialJob.Module, dep, names))
 		}
 	}
 
+	p.decoded = map[string]*job.Job{}
+	p.views = map[string]map[string]cty.Value{}
+
 	jobs := []*job.Job{}
 
 	errors := g.IterSorted(func(node node.Node) (err error) {
 		j := node.(*job.Job)
 
-		if err := p.decodeJob(j, remains[j.GetName()], 0, templates, false, nil); err != nil {
+		if matrixJobs, ok := aggregates[j.GetName()]; ok {
+			children := []*job.Job{}
+			for _, name := range matrixJobs {
+				children = append(children, p.decoded[name])
+			}
+
+			aggregateJob(j, children)
+			p.decoded[j.GetName()] = j
+			jobs = append(jobs, j)
+			return nil
+		}
+
+		partialJob := partialMap[j.GetName()]
+
+		p.module = partialJob.Module
+		p.dir = partialJob.Dir
+		j.Matrix = partialJob.MatrixValues
+
+		if err := p.setMatrix(partialJob.MatrixValues); err != nil {
 			return err
 		}
 
+		if err := p.decodeJob(j, partialJob.Remain, 0, partialJob.Templates, false, nil); err != nil {
+			return err
+		}
+
+		if err := j.ValidateOptions(); err != nil {
+			return fmt.Errorf("Error validating job %s: %s", j.GetName(), err)
+		}
+
+		if j.Deps != nil {
+			deps := job.StringSet{}
+			for _, dep := range *j.Deps {
+				deps = append(deps, resolveDep(partialJob.Module, dep, names))
+			}
+			j.Deps = &deps
+		}
+
+		p.decoded[j.GetName()] = moduleJob(j, partialJob.Dir)
 		jobs = append(jobs, j)
 		return nil
 	})
+	p.module = ""
+	p.dir = ""
+
 	if len(errors) > 0 {
 		return nil, errors[0]
 	}
@@ -544,10 +807,41 @@ func (p *Parser) DecodeJobs(templates []JobPartial) ([]*job.Job, error) {
 	return jobs, nil
 }
 
-func (p *Parser) setJob(j *job.Job) error {
+// Return the decoded jobs as cty values, with their paths relative to the
+// directory of the module being decoded.
+func (p *Parser) jobValues() (map[string]cty.Value, error) {
+	if p.views == nil {
+		p.views = map[string]map[string]cty.Value{}
+	}
+
+	view := p.views[p.dir]
+	if view == nil {
+		view = map[string]cty.Value{}
+		p.views[p.dir] = view
+	}
+
 	jobMap := map[string]cty.Value{}
-	if !p.ctx.Variables["jobs"].IsNull() {
-		jobMap = p.ctx.Variables["jobs"].AsValueMap()
+
+	for name, j := range p.decoded {
+		if _, ok := view[name]; !ok {
+			value, err := j.MapPaths(relativeTo(p.dir)).ToCty()
+			if err != nil {
+				return nil, err
+			}
+
+			view[name] = value
+		}
+
+		jobMap[name] = view[name]
+	}
+
+	return jobMap, nil
+}
+
+func (p *Parser) setJob(j *job.Job) error {
+	jobMap, err := p.jobValues()
+	if err != nil {
+		return err
 	}
 
 	jobCty, err := j.ToCty()
@@ -556,7 +850,7 @@ func (p *Parser) setJob(j *job.Job) error {
 	}
 
 	jobMap[j.Name] = jobCty
-	p.ctx.Variables["jobs"] = cty.MapVal(jobMap)
+	p.ctx.Variables["jobs"] = jobsTree(jobMap, p.module)
 	p.ctx.Variables["self"] = jobCty
 	return nil
 }
@@ -614,44 +908,78 @@ func (p *Parser) decodeJob(j *job.Job, body hcl.Body, depth int, templates []Job
 }
 
 func (p *Parser) DecodeConfig() (config types.Config, err error) {
-	if config.Env, err = p.DecodeEnv(); err != nil {
+	if err = p.decodeInputs(&config); err != nil {
+		return
+	}
+
+	templates, err := p.DecodeTemplates()
+	if err != nil {
 		return
 	}
 
-	if config.Secrets, err = p.DecodeSecrets(); err != nil {
+	err = p.decodeConfig(&config, templates)
+	return
+}
+
+// Decode the variables, environment and secrets, which the rest of the
+// configuration is evaluated with.
+func (p *Parser) decodeInputs(config *types.Config) (err error) {
+	if _, err = p.DecodeVariables(); err != nil {
 		return
 	}
 
+	if config.Env, err = p.DecodeEnv(); err != nil {
+		return
+	}
+
+	config.Git = p.git
+	config.CI = p.ci
+
+	config.Secrets, err = p.DecodeSecrets()
+	return
+}
+
+// Decode the rest of the configuration into config once its inputs have been
+// decoded.
+func (p *Parser) decodeConfig(config *types.Config, templates []JobPartial) (err error) {
 	if config.SCM, err = p.DecodeSCMs(); err != nil {
 		return
 	}
 
-	if config.Cache, err = p.DecodeCache(); err != nil {
+	if config.Notify, err = p.DecodeNotifiers(); err != nil {
 		return
 	}
 
-	if config.Kubernetes, err = p.DecodeKubernetes(); err != nil {
+	if config.Registries, err = p.DecodeRegistries(); err != nil {
 		return
 	}
 
-	if config.Engine, err = p.DecodeEngine(); err != nil {
+	if config.Cache, err = p.DecodeCache(); err != nil {
 		return
 	}
 
-	templates, err := p.DecodeTemplates()
-	if err != nil {
+	if config.Kubernetes, err = p.DecodeKubernetes(); err != nil {
 		return
 	}
 
-	if config.Jobs, err = p.DecodeJobs(templates); err != nil {
+	if config.Engine, err = p.DecodeEngine(); err != nil {
 		return
 	}
 
+	config.Jobs, err = p.DecodeJobs(templates)
 	return
 }
 
 func Unmarshal(path string) (*types.Config, error) {
+	return UnmarshalWithVars(path, nil, nil)
+}
+
+// Load a Honefile, setting its variables from vars (name to raw value) and
+// the variable files in varFiles.
+func UnmarshalWithVars(path string, vars map[string]string, varFiles []string) (*types.Config, error) {
 	parser := NewParser()
+	parser.Vars = vars
+	parser.VarFiles = varFiles
 
 	if err := parser.ParseFile(path); err != nil {
 		return nil, err
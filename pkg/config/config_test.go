@@ -1,10 +1,15 @@
 package config
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"testing"
 
+	"github.com/justinbarrick/hone/pkg/cache"
+	"github.com/justinbarrick/hone/pkg/git"
+	"github.com/justinbarrick/hone/pkg/job"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -143,6 +148,42 @@ job "moon" {
 	assert.Equal(t, 0, len(jobs))
 }
 
+func TestConfigInvalidPull(t *testing.T) {
+	example := `
+job "moon" {
+	image = "alpine"
+	pull = "sometimes"
+	shell = "echo hi"
+}
+`
+
+	parser := NewParser()
+	err := parser.Parse(example)
+	assert.Nil(t, err)
+
+	_, err = parser.DecodeJobs([]JobPartial{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "Error validating job moon: Pull must be one of always, missing, or never.", err.Error())
+}
+
+func TestConfigInvalidStrict(t *testing.T) {
+	example := `
+job "moon" {
+	image = "alpine"
+	strict = "yes"
+	shell = "echo hi"
+}
+`
+
+	parser := NewParser()
+	err := parser.Parse(example)
+	assert.Nil(t, err)
+
+	_, err = parser.DecodeJobs([]JobPartial{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "Error validating job moon: Strict must be one of off, warn, or error.", err.Error())
+}
+
 func TestConfigComplexSelfReferential(t *testing.T) {
 	example := `
 job "moon" {
@@ -239,6 +280,35 @@ job "test" {
 	assert.Equal(t, jobs[0].GetEnv()["HELLO"], "hello")
 }
 
+func TestConfigGit(t *testing.T) {
+	example := `
+job "test" {
+    image = "lol"
+    env = {
+        "COMMIT" = "${git.commit_short}"
+        "DIRTY" = "${git.dirty}"
+        "CI" = "${ci.provider}"
+    }
+}
+`
+
+	parser := NewParser()
+	err := parser.Parse(example)
+	assert.Nil(t, err)
+
+	config, err := parser.DecodeConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(config.Jobs))
+	assert.Equal(t, config.Env["GIT_COMMIT_SHORT"], config.Jobs[0].GetEnv()["COMMIT"])
+	assert.Equal(t, config.Env["GIT_DIRTY"], config.Jobs[0].GetEnv()["DIRTY"])
+	assert.Equal(t, config.Env["CI_PROVIDER"], config.Jobs[0].GetEnv()["CI"])
+	assert.NotEqual(t, "", config.Git.Commit)
+
+	conditions := config.Conditions()
+	assert.Equal(t, config.Git.Tags, conditions["GIT_TAGS"])
+	assert.Equal(t, config.Git.Dirty, conditions["GIT_DIRTY"])
+}
+
 func TestConfigSecrets(t *testing.T) {
 	example := `
 secrets = [
@@ -591,3 +661,635 @@ job "moon" {
 		"HELLO": "moon",
 	}, jobs[0].GetEnv())
 }
+
+func TestConfigBuildBlock(t *testing.T) {
+	example := `
+job "binary" {
+	image = "golang"
+	outputs = ["docker/hone"]
+	shell = "go build -o docker/hone ./cmd/hone"
+}
+
+job "image" {
+	inputs = jobs.binary.outputs
+
+	build {
+		dockerfile = "docker/Dockerfile.hone"
+		context = "docker/"
+		tags = ["justinbarrick/hone:${jobs.binary.name}"]
+		args = {
+			"VERSION" = "1.0"
+		}
+		push = true
+	}
+}
+`
+
+	parser := NewParser()
+	err := parser.Parse(example)
+	assert.Nil(t, err)
+
+	jobs, err := parser.DecodeJobs([]JobPartial{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(jobs))
+
+	assert.Equal(t, "image", jobs[1].GetName())
+	assert.Equal(t, []string{"binary"}, jobs[1].GetDeps())
+	assert.Equal(t, "docker/Dockerfile.hone", jobs[1].Build.GetDockerfile())
+	assert.Equal(t, "docker/", jobs[1].Build.GetContext())
+	assert.Equal(t, []string{"justinbarrick/hone:binary"}, jobs[1].Build.GetTags())
+	assert.Equal(t, true, jobs[1].Build.ShouldPush())
+	assert.Nil(t, jobs[1].Validate(""))
+}
+
+func writeHonefiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "hone-test")
+	assert.Nil(t, err)
+
+	for path, contents := range files {
+		path = filepath.Join(dir, path)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+
+	return dir
+}
+
+func TestConfigModules(t *testing.T) {
+	dir := writeHonefiles(t, map[string]string{
+		"Honefile": `
+include = ["jobs/*.hcl"]
+
+template "default" {
+	image = "golang"
+}
+
+module "svc" {
+	source = "./services/svc"
+}
+`,
+		"jobs/release.hcl": `
+job "release" {
+	inputs = jobs.svc.build.outputs
+	outputs = ["release.tgz"]
+	shell = "tar czf release.tgz ${join(jobs.svc.build.outputs, " ")}"
+}
+`,
+		"services/svc/Honefile": `
+job "build" {
+	inputs = ["./main.go"]
+	outputs = ["bin/svc"]
+	shell = "go build -o ${self.outputs[0]} ."
+}
+
+job "test" {
+	inputs = concat(jobs.build.outputs, ["main_test.go"])
+	workdir = "test"
+	test_reports = ["report.xml"]
+	shell = "go test ."
+}
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(dir))
+	defer os.Chdir(cwd)
+
+	parser := NewParser()
+	assert.Nil(t, parser.ParseFile("Honefile"))
+
+	templates, err := parser.DecodeTemplates()
+	assert.Nil(t, err)
+
+	jobs, err := parser.DecodeJobs(templates)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(jobs))
+
+	jobMap := map[string]*job.Job{}
+	for _, j := range jobs {
+		jobMap[j.GetName()] = j
+	}
+
+	build := jobMap["svc.build"]
+	assert.Equal(t, "golang:latest", build.GetImage())
+	assert.Equal(t, []string{"services/svc/main.go"}, build.GetInputs())
+	assert.Equal(t, []string{"services/svc/bin/svc"}, build.GetOutputs())
+	assert.Equal(t, "services/svc", build.GetWorkdir())
+	assert.Equal(t, []string{"/bin/sh", "-cex", "go build -o bin/svc ."}, build.GetShell())
+
+	test := jobMap["svc.test"]
+	assert.Equal(t, []string{"svc.build"}, test.GetDeps())
+	assert.Equal(t, []string{"services/svc/bin/svc", "services/svc/main_test.go"}, test.GetInputs())
+	assert.Equal(t, "services/svc/test", test.GetWorkdir())
+	assert.Equal(t, []string{"services/svc/report.xml"}, test.GetTestReports())
+
+	release := jobMap["release"]
+	assert.Equal(t, []string{"svc.build"}, release.GetDeps())
+	assert.Equal(t, []string{"services/svc/bin/svc"}, release.GetInputs())
+	assert.Equal(t, []string{"release.tgz"}, release.GetOutputs())
+	assert.Equal(t, []string{"/bin/sh", "-cex", "tar czf release.tgz services/svc/bin/svc"}, release.GetShell())
+}
+
+func TestConfigModuleFunctions(t *testing.T) {
+	dir := writeHonefiles(t, map[string]string{
+		"Honefile": `
+module "svc" {
+	source = "./services/svc"
+}
+
+job "root" {
+	image = "alpine"
+	env = {
+		"VERSION" = trimspace(file("VERSION"))
+	}
+	shell = "echo"
+}
+`,
+		"VERSION": "v1.0.0\n",
+		"services/svc/Honefile": `
+job "build" {
+	image = "golang"
+	inputs = glob("*.go")
+	env = {
+		"VERSION" = trimspace(file("VERSION"))
+		"HAS_MAIN" = "${fileexists("main.go")}"
+		"SUM" = filesha256("VERSION")
+		"GREETING" = templatefile("greeting.tpl", {name = "svc"})
+	}
+	shell = "go build ."
+}
+`,
+		"services/svc/VERSION":      "v2.0.0\n",
+		"services/svc/main.go":      "package main",
+		"services/svc/greeting.tpl": "Hello, ${name}!",
+	})
+	defer os.RemoveAll(dir)
+
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(dir))
+	defer os.Chdir(cwd)
+
+	parser := NewParser()
+	assert.Nil(t, parser.ParseFile("Honefile"))
+
+	jobs, err := parser.DecodeJobs([]JobPartial{})
+	assert.Nil(t, err)
+
+	jobMap := map[string]*job.Job{}
+	for _, j := range jobs {
+		jobMap[j.GetName()] = j
+	}
+
+	assert.Equal(t, "v1.0.0", jobMap["root"].GetEnv()["VERSION"])
+
+	build := jobMap["svc.build"]
+	assert.Equal(t, []string{"services/svc/main.go"}, build.GetInputs())
+	assert.Equal(t, "v2.0.0", build.GetEnv()["VERSION"])
+	assert.Equal(t, "true", build.GetEnv()["HAS_MAIN"])
+	assert.Equal(t, "Hello, svc!", build.GetEnv()["GREETING"])
+
+	sum, err := cache.HashFile("services/svc/VERSION")
+	assert.Nil(t, err)
+	assert.Equal(t, sum, build.GetEnv()["SUM"])
+}
+
+func TestConfigGitFields(t *testing.T) {
+	dir := writeHonefiles(t, map[string]string{
+		"Honefile": `
+module "svc" {
+	source = "./svc"
+}
+
+job "release" {
+	image = "alpine"
+	condition = "GIT_DIRTY=false"
+	shell = "echo"
+}
+`,
+		"svc/Honefile": `
+job "build" {
+	image = "golang"
+	shell = "echo ${git.describe}"
+}
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	parser := NewParser()
+	assert.Nil(t, parser.ParseFile(filepath.Join(dir, "Honefile")))
+	assert.Equal(t, git.MetadataFields{Dirty: true, Describe: true}, parser.gitFields())
+
+	parser = NewParser()
+	assert.Nil(t, parser.Parse(`
+job "build" {
+	shell = "echo ${git.commit}"
+}
+`))
+	assert.Equal(t, git.MetadataFields{}, parser.gitFields())
+
+	parser = NewParser()
+	assert.Nil(t, parser.Parse(`
+module "missing" {
+	source = "./missing"
+}
+`))
+	assert.Equal(t, git.AllMetadata, parser.gitFields())
+}
+
+func TestConfigModuleVariables(t *testing.T) {
+	dir := writeHonefiles(t, map[string]string{
+		"Honefile": `
+module "svc" {
+	source = "./svc"
+}
+`,
+		"svc/Honefile": `
+variable "tag" {
+	default = "latest"
+}
+
+job "build" {
+	image = "golang"
+	shell = "echo ${var.tag}"
+}
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	parser := NewParser()
+	assert.Nil(t, parser.ParseFile(filepath.Join(dir, "Honefile")))
+
+	_, err := parser.DecodeJobs([]JobPartial{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Variable tag is declared in module svc")
+}
+
+func TestConfigMatrix(t *testing.T) {
+	example := `
+job "build" {
+	image = "golang"
+	matrix = {
+		os = ["linux", "darwin"]
+		arch = ["amd64", "arm64"]
+	}
+	env = {
+		"GOOS" = "${matrix.os}"
+		"GOARCH" = "${matrix.arch}"
+	}
+	outputs = ["bin/hone-${matrix.os}-${matrix.arch}"]
+	shell = "go build -o ${self.outputs[0]} ./cmd/hone"
+}
+
+job "release" {
+	image = "alpine"
+	inputs = jobs.build.outputs
+	shell = "ls"
+}
+`
+
+	parser := NewParser()
+	err := parser.Parse(example)
+	assert.Nil(t, err)
+
+	jobs, err := parser.DecodeJobs([]JobPartial{})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(jobs))
+
+	jobMap := map[string]*job.Job{}
+	for _, j := range jobs {
+		jobMap[j.GetName()] = j
+		assert.Nil(t, j.Validate(""))
+	}
+
+	darwin := jobMap["build-arm64-darwin"]
+	assert.NotNil(t, darwin)
+	assert.Equal(t, map[string]string{"os": "darwin", "arch": "arm64"}, darwin.Matrix)
+	assert.Equal(t, map[string]string{"GOOS": "darwin", "GOARCH": "arm64"}, darwin.GetEnv())
+	assert.Equal(t, []string{"bin/hone-darwin-arm64"}, darwin.GetOutputs())
+
+	build := jobMap["build"]
+	assert.True(t, build.Aggregate)
+	assert.Equal(t, sorted([]string{
+		"build-amd64-darwin", "build-amd64-linux", "build-arm64-darwin", "build-arm64-linux",
+	}), build.GetDeps())
+	assert.Equal(t, 4, len(build.GetOutputs()))
+
+	release := jobMap["release"]
+	assert.Equal(t, []string{"build"}, release.GetDeps())
+	assert.Equal(t, sorted([]string{
+		"bin/hone-darwin-amd64", "bin/hone-darwin-arm64", "bin/hone-linux-amd64", "bin/hone-linux-arm64",
+	}), sorted(release.GetInputs()))
+}
+
+func TestMatrixJobName(t *testing.T) {
+	assert.Equal(t, "build-amd64-linux", MatrixJobName("build", map[string]string{"os": "linux", "arch": "amd64"}))
+	assert.Equal(t, "test-1-11", MatrixJobName("test", map[string]string{"go": "1.11"}))
+}
+
+func TestConfigVariables(t *testing.T) {
+	example := `
+variable "s3_enabled" {
+	type = bool
+	default = false
+	description = "Enable the S3 cache."
+}
+
+variable "replicas" {
+	type = number
+	default = 1
+
+	validation {
+		condition = var.replicas > 0
+		error_message = "Replicas must be positive."
+	}
+}
+
+variable "tags" {
+	type = list(string)
+	default = ["latest"]
+}
+
+variable "name" {}
+
+variable "user" {
+	type = string
+	default = null
+}
+
+job "hello" {
+	image = "alpine"
+	user = var.user
+	privileged = var.s3_enabled
+	env = {
+		"REPLICAS" = "${var.replicas}"
+		"NAME" = var.name
+	}
+	outputs = var.tags
+	shell = "echo hi"
+}
+`
+
+	os.Setenv("HONE_VAR_replicas", "3")
+	defer os.Unsetenv("HONE_VAR_replicas")
+
+	parser := NewParser()
+	parser.Vars = map[string]string{
+		"s3_enabled": "true",
+		"name":       "world",
+	}
+	err := parser.Parse(example)
+	assert.Nil(t, err)
+
+	_, err = parser.DecodeVariables()
+	assert.Nil(t, err)
+
+	jobs, err := parser.DecodeJobs([]JobPartial{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobs))
+
+	assert.Equal(t, true, jobs[0].IsPrivileged())
+	assert.Equal(t, map[string]string{"REPLICAS": "3", "NAME": "world"}, jobs[0].GetEnv())
+	assert.Equal(t, []string{"latest"}, jobs[0].GetOutputs())
+	assert.Nil(t, jobs[0].User)
+}
+
+func TestConfigVariablesErrors(t *testing.T) {
+	example := `
+variable "enabled" {
+	type = bool
+}
+
+variable "replicas" {
+	type = number
+	default = 0
+
+	validation {
+		condition = var.replicas > 0
+		error_message = "Replicas must be positive."
+	}
+}
+`
+
+	parser := NewParser()
+	assert.Nil(t, parser.Parse(example))
+	_, err := parser.DecodeVariables()
+	assert.NotNil(t, err)
+
+	parser = NewParser()
+	parser.Vars = map[string]string{"enabled": "lol", "replicas": "1"}
+	assert.Nil(t, parser.Parse(example))
+	_, err = parser.DecodeVariables()
+	assert.NotNil(t, err)
+
+	parser = NewParser()
+	parser.Vars = map[string]string{"enabled": "true"}
+	assert.Nil(t, parser.Parse(example))
+	_, err = parser.DecodeVariables()
+	assert.NotNil(t, err)
+
+	parser = NewParser()
+	parser.Vars = map[string]string{"enabled": "true", "replicas": "2", "missing": "1"}
+	assert.Nil(t, parser.Parse(example))
+	_, err = parser.DecodeVariables()
+	assert.NotNil(t, err)
+
+	parser = NewParser()
+	parser.Vars = map[string]string{"enabled": "true", "replicas": "2"}
+	assert.Nil(t, parser.Parse(example))
+	values, err := parser.DecodeVariables()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(values))
+}
+
+func TestConfigVariableFile(t *testing.T) {
+	dir := writeHonefiles(t, map[string]string{
+		"vars.hcl":  "replicas = 5\n",
+		"vars.json": `{"tags": ["a", "b"]}`,
+	})
+	defer os.RemoveAll(dir)
+
+	example := `
+variable "replicas" {
+	type = number
+}
+
+variable "tags" {
+	type = list(string)
+	default = []
+}
+`
+
+	parser := NewParser()
+	parser.VarFiles = []string{filepath.Join(dir, "vars.hcl"), filepath.Join(dir, "vars.json")}
+	parser.Vars = map[string]string{"replicas": "7"}
+	assert.Nil(t, parser.Parse(example))
+
+	values, err := parser.DecodeVariables()
+	assert.Nil(t, err)
+	assert.Equal(t, "7", values["replicas"].AsBigFloat().String())
+	assert.Equal(t, 2, values["tags"].LengthInt())
+}
+
+func TestParseVarFlags(t *testing.T) {
+	vars, err := ParseVarFlags([]string{"a=b", "c=d=e"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": "b", "c": "d=e"}, vars)
+
+	_, err = ParseVarFlags([]string{"a"})
+	assert.NotNil(t, err)
+}
+
+func TestFindHonefile(t *testing.T) {
+	dir := writeHonefiles(t, map[string]string{
+		"hcl/Honefile.hcl":   "",
+		"json/Honefile.json": "{}",
+		"both/Honefile":      "",
+		"both/Honefile.json": "{}",
+	})
+	defer os.RemoveAll(dir)
+
+	for subdir, expected := range map[string]string{
+		"hcl":  "Honefile.hcl",
+		"json": "Honefile.json",
+		"both": "Honefile",
+	} {
+		honefile, err := FindHonefile(filepath.Join(dir, subdir))
+		assert.Nil(t, err)
+		assert.Equal(t, filepath.Join(dir, subdir, expected), honefile)
+	}
+
+	_, err := FindHonefile(dir)
+	assert.NotNil(t, err)
+}
+
+func TestConfigJSON(t *testing.T) {
+	dir := writeHonefiles(t, map[string]string{
+		"Honefile.json": `{
+	"include": ["jobs.hcl"],
+	"job": {
+		"build": {
+			"image": "golang",
+			"outputs": ["bin/hone"],
+			"shell": "go build -o ${self.outputs[0]}"
+		}
+	}
+}`,
+		"jobs.hcl": `
+job "release" {
+	image = "alpine"
+	inputs = jobs.build.outputs
+	shell = "tar cz bin"
+}
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	parser := NewParser()
+	assert.Nil(t, parser.ParseFile(dir))
+	assert.Equal(t, filepath.Join(dir, "Honefile.json"), parser.Path())
+
+	jobs, err := parser.DecodeJobs([]JobPartial{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(jobs))
+
+	assert.Equal(t, "build", jobs[0].GetName())
+	assert.Equal(t, []string{"/bin/sh", "-cex", "go build -o bin/hone"}, jobs[0].GetShell())
+	assert.Equal(t, "release", jobs[1].GetName())
+	assert.Equal(t, []string{"bin/hone"}, jobs[1].GetInputs())
+}
+
+func TestConfigDescription(t *testing.T) {
+	example := `
+template "default" {
+	description = "Not inherited."
+}
+
+job "build" {
+	description = "Build the binary."
+	image = "golang"
+	shell = "go build"
+}
+
+job "release" {
+	image = "alpine"
+	shell = "echo ${jobs.build.description}"
+}
+`
+
+	parser := NewParser()
+	assert.Nil(t, parser.Parse(example))
+
+	templates, err := parser.DecodeTemplates()
+	assert.Nil(t, err)
+
+	jobs, err := parser.DecodeJobs(templates)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(jobs))
+
+	assert.Equal(t, "Build the binary.", jobs[0].GetDescription())
+	assert.Equal(t, "", jobs[1].GetDescription())
+	assert.Equal(t, []string{"/bin/sh", "-cex", "echo Build the binary."}, jobs[1].GetShell())
+}
+
+func TestConfigGroups(t *testing.T) {
+	example := `
+job "build" {
+	image = "golang"
+	outputs = ["bin/hone"]
+	shell = "go build"
+}
+
+job "lint" {
+	image = "golang"
+	shell = "go vet"
+}
+
+group "ci" {
+	description = "Everything CI runs."
+	targets = ["build", "lint"]
+}
+
+group "all" {
+	targets = ["ci"]
+}
+
+job "release" {
+	image = "alpine"
+	inputs = jobs.ci.outputs
+	shell = "tar cz bin"
+}
+`
+
+	parser := NewParser()
+	assert.Nil(t, parser.Parse(example))
+
+	jobs, err := parser.DecodeJobs([]JobPartial{})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(jobs))
+
+	jobMap := map[string]*job.Job{}
+	for _, j := range jobs {
+		jobMap[j.GetName()] = j
+	}
+
+	assert.True(t, jobMap["ci"].Aggregate)
+	assert.Equal(t, "Everything CI runs.", jobMap["ci"].GetDescription())
+	assert.Equal(t, []string{"build", "lint"}, sorted(jobMap["ci"].GetDeps()))
+	assert.Nil(t, jobMap["ci"].Image)
+	assert.Nil(t, jobMap["ci"].Validate(""))
+	assert.Equal(t, []string{"ci"}, jobMap["all"].GetDeps())
+	assert.Equal(t, []string{"bin/hone"}, jobMap["release"].GetInputs())
+
+	parser = NewParser()
+	assert.Nil(t, parser.Parse(`
+group "ci" {
+	targets = ["missing"]
+}
+`))
+
+	_, err = parser.DecodeJobs([]JobPartial{})
+	assert.NotNil(t, err)
+}
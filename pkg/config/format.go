@@ -0,0 +1,50 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclwrite"
+)
+
+// Return src in the canonical HCL format, src must be valid HCL.
+func Format(src []byte, filename string) ([]byte, error) {
+	_, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	return hclwrite.Format(src), nil
+}
+
+// Format the HCL file at path, returning whether it was not already formatted.
+// The file is only rewritten if write is true.
+func FormatFile(path string, write bool) (bool, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	formatted, err := Format(src, path)
+	if err != nil {
+		return false, err
+	}
+
+	if bytes.Equal(src, formatted) {
+		return false, nil
+	}
+
+	if !write {
+		return true, nil
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return true, err
+	}
+
+	return true, ioutil.WriteFile(path, formatted, fi.Mode())
+}
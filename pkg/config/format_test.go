@@ -0,0 +1,62 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	formatted, err := Format([]byte(`
+job "build" {
+    image = "golang"
+    deps = [ "test" ]
+    env = {
+        "GO111MODULE" = "on"
+        "GOPATH" = "/build/.go"
+    }
+}
+`), "Honefile")
+	assert.Nil(t, err)
+	assert.Equal(t, `
+job "build" {
+  image = "golang"
+  deps  = ["test"]
+  env = {
+    "GO111MODULE" = "on"
+    "GOPATH"      = "/build/.go"
+  }
+}
+`, string(formatted))
+
+	_, err = Format([]byte(`job "build" {`), "Honefile")
+	assert.NotNil(t, err)
+}
+
+func TestFormatFile(t *testing.T) {
+	dir := writeHonefiles(t, map[string]string{
+		"Honefile": "job \"build\" {\n    image = \"golang\"\n}\n",
+	})
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Honefile")
+
+	changed, err := FormatFile(path, false)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+
+	changed, err = FormatFile(path, true)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "job \"build\" {\n  image = \"golang\"\n}\n", string(data))
+
+	changed, err = FormatFile(path, false)
+	assert.Nil(t, err)
+	assert.False(t, changed)
+}
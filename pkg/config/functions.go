@@ -0,0 +1,393 @@
+package config
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/justinbarrick/hone/pkg/cache"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+var semverRegex = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+func stringFunc(fn func(string) (string, error)) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "str",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			result, err := fn(args[0].AsString())
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			return cty.StringVal(result), nil
+		},
+	})
+}
+
+// Resolve a path given to a file function. Like job paths, it is relative to
+// the directory of the module being decoded.
+func (p *Parser) resolvePath(path string) string {
+	return relativeToRoot(p.dir)(path)
+}
+
+func (p *Parser) fileFunc() function.Function {
+	return stringFunc(func(path string) (string, error) {
+		data, err := ioutil.ReadFile(p.resolvePath(path))
+		if err != nil {
+			return "", err
+		}
+
+		return string(data), nil
+	})
+}
+
+func (p *Parser) fileExistsFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			fi, err := os.Stat(p.resolvePath(args[0].AsString()))
+			if err != nil {
+				if os.IsNotExist(err) {
+					return cty.False, nil
+				}
+				return cty.NilVal, err
+			}
+
+			return cty.BoolVal(fi.Mode().IsRegular()), nil
+		},
+	})
+}
+
+// Return the files matching a pattern, relative to the module's directory so
+// that they can be used as its jobs' inputs.
+func (p *Parser) globFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "pattern",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.List(cty.String)),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			matches, err := doublestar.Glob(p.resolvePath(args[0].AsString()))
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			if len(matches) == 0 {
+				return cty.ListValEmpty(cty.String), nil
+			}
+
+			for i, match := range matches {
+				matches[i] = relativeTo(p.dir)(match)
+			}
+
+			sort.Strings(matches)
+			return gocty.ToCtyValue(matches, cty.List(cty.String))
+		},
+	})
+}
+
+func (p *Parser) fileSha256Func() function.Function {
+	return stringFunc(func(path string) (string, error) {
+		return cache.HashFile(p.resolvePath(path))
+	})
+}
+
+var Sha256Func = stringFunc(func(str string) (string, error) {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(str))), nil
+})
+
+var TrimSpaceFunc = stringFunc(func(str string) (string, error) {
+	return strings.TrimSpace(str), nil
+})
+
+var DirnameFunc = stringFunc(func(path string) (string, error) {
+	return filepath.Dir(path), nil
+})
+
+// Replace substr in str, if substr is wrapped in slashes it is treated as a
+// regular expression.
+var ReplaceFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "str",
+			Type: cty.String,
+		},
+		{
+			Name: "substr",
+			Type: cty.String,
+		},
+		{
+			Name: "replace",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		str := args[0].AsString()
+		substr := args[1].AsString()
+		replace := args[2].AsString()
+
+		if len(substr) > 1 && strings.HasPrefix(substr, "/") && strings.HasSuffix(substr, "/") {
+			re, err := regexp.Compile(substr[1 : len(substr)-1])
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			return cty.StringVal(re.ReplaceAllString(str, replace)), nil
+		}
+
+		return cty.StringVal(strings.Replace(str, substr, replace, -1)), nil
+	},
+})
+
+// Match a regular expression against a string. Returns the match if the pattern
+// has no capture groups, a map if it has named groups and a list otherwise.
+var RegexFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "pattern",
+			Type: cty.String,
+		},
+		{
+			Name: "str",
+			Type: cty.String,
+		},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		if !args[0].IsKnown() {
+			return cty.DynamicPseudoType, nil
+		}
+
+		re, err := regexp.Compile(args[0].AsString())
+		if err != nil {
+			return cty.NilType, function.NewArgError(0, err)
+		}
+
+		return regexReturnType(re), nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		re := regexp.MustCompile(args[0].AsString())
+		str := args[1].AsString()
+
+		matches := re.FindStringSubmatch(str)
+		if matches == nil {
+			return cty.NilVal, fmt.Errorf("Pattern did not match %q.", str)
+		}
+
+		switch {
+		case retType == cty.String:
+			return cty.StringVal(matches[0]), nil
+		case retType.IsMapType():
+			groups := map[string]cty.Value{}
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					groups[name] = cty.StringVal(matches[i])
+				}
+			}
+			return cty.MapVal(groups), nil
+		default:
+			groups := []cty.Value{}
+			for _, match := range matches[1:] {
+				groups = append(groups, cty.StringVal(match))
+			}
+			return cty.ListVal(groups), nil
+		}
+	},
+})
+
+func regexReturnType(re *regexp.Regexp) cty.Type {
+	if re.NumSubexp() == 0 {
+		return cty.String
+	}
+
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return cty.Map(cty.String)
+		}
+	}
+
+	return cty.List(cty.String)
+}
+
+// Return an environment variable, or the default if it is unset or empty.
+var EnvFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "name",
+			Type: cty.String,
+		},
+	},
+	VarParam: &function.Parameter{
+		Name: "default",
+		Type: cty.String,
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if len(args) > 2 {
+			return cty.NilVal, fmt.Errorf("env takes at most two arguments.")
+		}
+
+		value := os.Getenv(args[0].AsString())
+		if value == "" && len(args) == 2 {
+			value = args[1].AsString()
+		}
+
+		return cty.StringVal(value), nil
+	},
+})
+
+// Return the current time. A job that uses it in a hashed field, such as its
+// env, shell or inputs, gets a new cache key every build and is never cached.
+var TimestampFunc = function.New(&function.Spec{
+	Params: []function.Parameter{},
+	Type:   function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		return cty.StringVal(time.Now().UTC().Format(time.RFC3339)), nil
+	},
+})
+
+var semverType = cty.Object(map[string]cty.Type{
+	"version":    cty.String,
+	"major":      cty.Number,
+	"minor":      cty.Number,
+	"patch":      cty.Number,
+	"prerelease": cty.String,
+	"metadata":   cty.String,
+})
+
+// Parse a semantic version (with an optional leading v) into its parts.
+var SemverFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "version",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(semverType),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		version := args[0].AsString()
+
+		parts := semverRegex.FindStringSubmatch(version)
+		if parts == nil {
+			return cty.NilVal, fmt.Errorf("%q is not a valid semantic version.", version)
+		}
+
+		number := func(str string) cty.Value {
+			n, _ := strconv.ParseInt(str, 10, 64)
+			return cty.NumberIntVal(n)
+		}
+
+		return cty.ObjectVal(map[string]cty.Value{
+			"version":    cty.StringVal(strings.TrimPrefix(version, "v")),
+			"major":      number(parts[1]),
+			"minor":      number(parts[2]),
+			"patch":      number(parts[3]),
+			"prerelease": cty.StringVal(parts[4]),
+			"metadata":   cty.StringVal(parts[5]),
+		}), nil
+	},
+})
+
+// Render a file as an HCL template with vars, the parser's functions are
+// available in the template. The path is relative to the module's directory.
+func (p *Parser) templateFileFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+			{
+				Name: "vars",
+				Type: cty.DynamicPseudoType,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path := p.resolvePath(args[0].AsString())
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			vars := args[1]
+			if !vars.IsNull() && !vars.Type().IsObjectType() && !vars.Type().IsMapType() {
+				return cty.NilVal, function.NewArgErrorf(1, "vars must be a map or object.")
+			}
+
+			expr, diags := hclsyntax.ParseTemplate(data, path, hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				return cty.NilVal, diags
+			}
+
+			ctx := &hcl.EvalContext{
+				Variables: map[string]cty.Value{},
+				Functions: p.GetContext().Functions,
+			}
+
+			if !vars.IsNull() {
+				for key, value := range vars.AsValueMap() {
+					ctx.Variables[key] = value
+				}
+			}
+
+			result, diags := expr.Value(ctx)
+			if diags.HasErrors() {
+				return cty.NilVal, diags
+			}
+
+			if result.IsNull() || !result.Type().Equals(cty.String) {
+				return cty.NilVal, fmt.Errorf("Template %s did not render to a string.", path)
+			}
+
+			return result, nil
+		},
+	})
+}
+
+func (p *Parser) functions() map[string]function.Function {
+	return map[string]function.Function{
+		"file":         p.fileFunc(),
+		"fileexists":   p.fileExistsFunc(),
+		"glob":         p.globFunc(),
+		"sha256":       Sha256Func,
+		"filesha256":   p.fileSha256Func(),
+		"templatefile": p.templateFileFunc(),
+		"replace":      ReplaceFunc,
+		"regex":        RegexFunc,
+		"trimspace":    TrimSpaceFunc,
+		"dirname":      DirnameFunc,
+		"env":          EnvFunc,
+		"timestamp":    TimestampFunc,
+		"semver":       SemverFunc,
+	}
+}
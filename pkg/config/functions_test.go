@@ -0,0 +1,191 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func evalExpr(src string) (cty.Value, error) {
+	parser := NewParser()
+
+	expr, diags := hclsyntax.ParseExpression([]byte(src), "test", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	value, diags := expr.Value(parser.GetContext())
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	return value, nil
+}
+
+func mustEval(t *testing.T, src string) cty.Value {
+	value, err := evalExpr(src)
+	assert.Nil(t, err)
+	return value
+}
+
+func withFiles(t *testing.T, files map[string]string) func() {
+	dir := writeHonefiles(t, files)
+
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(dir))
+
+	return func() {
+		os.Chdir(cwd)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestFunctionFile(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"version": "1.2.3\n",
+	})()
+
+	assert.Equal(t, cty.StringVal("1.2.3\n"), mustEval(t, `file("version")`))
+	assert.Equal(t, cty.StringVal("1.2.3"), mustEval(t, `trimspace(file("version"))`))
+
+	_, err := evalExpr(`file("missing")`)
+	assert.NotNil(t, err)
+}
+
+func TestFunctionFileExists(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"dir/file": "hello",
+	})()
+
+	assert.Equal(t, cty.True, mustEval(t, `fileexists("dir/file")`))
+	assert.Equal(t, cty.False, mustEval(t, `fileexists("dir")`))
+	assert.Equal(t, cty.False, mustEval(t, `fileexists("missing")`))
+}
+
+func TestFunctionGlob(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"cmd/hone/main.go":  "",
+		"cmd/shim/main.go":  "",
+		"cmd/shim/main.txt": "",
+	})()
+
+	assert.Equal(t, cty.ListVal([]cty.Value{
+		cty.StringVal("cmd/hone/main.go"),
+		cty.StringVal("cmd/shim/main.go"),
+	}), mustEval(t, `glob("cmd/**/*.go")`))
+
+	assert.Equal(t, cty.ListValEmpty(cty.String), mustEval(t, `glob("pkg/**/*.go")`))
+}
+
+func TestFunctionSha256(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"hello": "hello",
+	})()
+
+	hash := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	assert.Equal(t, cty.StringVal(hash), mustEval(t, `sha256("hello")`))
+	assert.Equal(t, cty.StringVal(hash), mustEval(t, `filesha256("hello")`))
+}
+
+func TestFunctionTemplateFile(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"template.tpl": "Hello, ${name}!%{ for tag in tags } ${upper(tag)}%{ endfor }",
+	})()
+
+	assert.Equal(t, cty.StringVal("Hello, world! A B"), mustEval(t, `templatefile("template.tpl", {name = "world", tags = ["a", "b"]})`))
+
+	_, err := evalExpr(`templatefile("template.tpl", {})`)
+	assert.NotNil(t, err)
+}
+
+func TestFunctionReplace(t *testing.T) {
+	assert.Equal(t, cty.StringVal("refs-heads-master"), mustEval(t, `replace("refs/heads/master", "/", "-")`))
+	assert.Equal(t, cty.StringVal("v1-x-x"), mustEval(t, `replace("v1.2.3", "/\\.[0-9]+/", "-x")`))
+}
+
+func TestFunctionRegex(t *testing.T) {
+	assert.Equal(t, cty.StringVal("1.2"), mustEval(t, `regex("[0-9]+\\.[0-9]+", "go1.2.3")`))
+
+	assert.Equal(t, cty.ListVal([]cty.Value{
+		cty.StringVal("1"), cty.StringVal("2"),
+	}), mustEval(t, `regex("([0-9]+)\\.([0-9]+)", "go1.2.3")`))
+
+	assert.Equal(t, cty.StringVal("2"), mustEval(t, `regex("(?P<major>[0-9]+)\\.(?P<minor>[0-9]+)", "go1.2.3").minor`))
+
+	_, err := evalExpr(`regex("[0-9]+", "hello")`)
+	assert.NotNil(t, err)
+}
+
+func TestFunctionDirname(t *testing.T) {
+	assert.Equal(t, cty.StringVal("cmd/hone"), mustEval(t, `dirname("cmd/hone/main.go")`))
+	assert.Equal(t, cty.StringVal("."), mustEval(t, `dirname("main.go")`))
+}
+
+func TestFunctionEnv(t *testing.T) {
+	os.Setenv("HONE_TEST_ENV", "hello")
+	defer os.Unsetenv("HONE_TEST_ENV")
+
+	assert.Equal(t, cty.StringVal("hello"), mustEval(t, `env("HONE_TEST_ENV")`))
+	assert.Equal(t, cty.StringVal("hello"), mustEval(t, `env("HONE_TEST_ENV", "default")`))
+	assert.Equal(t, cty.StringVal(""), mustEval(t, `env("HONE_TEST_MISSING")`))
+	assert.Equal(t, cty.StringVal("default"), mustEval(t, `env("HONE_TEST_MISSING", "default")`))
+}
+
+func TestFunctionTimestamp(t *testing.T) {
+	timestamp := mustEval(t, `timestamp()`)
+
+	parsed, err := time.Parse(time.RFC3339, timestamp.AsString())
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), parsed, time.Minute)
+}
+
+func TestFunctionSemver(t *testing.T) {
+	version := mustEval(t, `semver("v1.12.3-rc.1+abc")`)
+
+	assert.Equal(t, cty.StringVal("1.12.3-rc.1+abc"), version.GetAttr("version"))
+	assert.Equal(t, cty.NumberIntVal(1), version.GetAttr("major"))
+	assert.Equal(t, cty.NumberIntVal(12), version.GetAttr("minor"))
+	assert.Equal(t, cty.NumberIntVal(3), version.GetAttr("patch"))
+	assert.Equal(t, cty.StringVal("rc.1"), version.GetAttr("prerelease"))
+	assert.Equal(t, cty.StringVal("abc"), version.GetAttr("metadata"))
+
+	assert.Equal(t, cty.StringVal("v2"), mustEval(t, `"v${semver("2.0.0").major}"`))
+
+	_, err := evalExpr(`semver("latest")`)
+	assert.NotNil(t, err)
+}
+
+func TestFunctionsInJob(t *testing.T) {
+	defer withFiles(t, map[string]string{
+		"VERSION": "v0.3.1\n",
+	})()
+
+	example := `
+job "release" {
+	image = "alpine"
+	inputs = glob("VERSION")
+	env = {
+		"VERSION" = trimspace(file("VERSION"))
+		"MAJOR" = "${semver(trimspace(file("VERSION"))).major}"
+	}
+	shell = "echo ${dirname("bin/hone")}"
+}
+`
+
+	parser := NewParser()
+	assert.Nil(t, parser.Parse(example))
+
+	jobs, err := parser.DecodeJobs([]JobPartial{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(jobs))
+
+	assert.Equal(t, []string{"VERSION"}, jobs[0].GetInputs())
+	assert.Equal(t, map[string]string{"VERSION": "v0.3.1", "MAJOR": "0"}, jobs[0].GetEnv())
+	assert.Equal(t, []string{"/bin/sh", "-cex", "echo bin"}, jobs[0].GetShell())
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/justinbarrick/hone/pkg/job"
+)
+
+// A named set of targets, run like a job that only has dependencies.
+type Group struct {
+	Name        string   `hcl:"name,label"`
+	Description *string  `hcl:"description"`
+	Targets     []string `hcl:"targets"`
+	Remain      hcl.Body `hcl:",remain"`
+}
+
+// Remove the groups from partials, adding them to aggregates with their targets
+// resolved to job names. The groups are returned keyed by name.
+func splitGroups(partials []JobPartial, aggregates map[string][]string, names map[string]bool) ([]JobPartial, map[string]*Group) {
+	jobs := []JobPartial{}
+	groups := map[string]*Group{}
+
+	for _, partial := range partials {
+		if partial.Group == nil {
+			jobs = append(jobs, partial)
+			continue
+		}
+
+		targets := []string{}
+		for _, target := range partial.Group.Targets {
+			targets = append(targets, resolveDep(partial.Module, target, names))
+		}
+
+		aggregates[partial.Name] = targets
+		groups[partial.Name] = partial.Group
+	}
+
+	return jobs, groups
+}
+
+// Return the job that runs a group or a matrix job's generated jobs.
+func aggregateNode(name string, deps []string, group *Group) *job.Job {
+	j := &job.Job{
+		Name:      name,
+		Aggregate: true,
+	}
+
+	if group != nil {
+		j.Description = group.Description
+	}
+
+	for _, dep := range deps {
+		j.AddDep(dep)
+	}
+
+	return j
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Return every combination of the values in a matrix, keys are iterated in
+// sorted order.
+func MatrixCombinations(matrix map[string][]string) ([]map[string]string, error) {
+	keys := []string{}
+	for key := range matrix {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	combinations := []map[string]string{{}}
+
+	for _, key := range keys {
+		if len(matrix[key]) == 0 {
+			return nil, fmt.Errorf("Matrix key %s has no values.", key)
+		}
+
+		expanded := []map[string]string{}
+
+		for _, combination := range combinations {
+			for _, value := range matrix[key] {
+				next := map[string]string{key: value}
+				for k, v := range combination {
+					next[k] = v
+				}
+				expanded = append(expanded, next)
+			}
+		}
+
+		combinations = expanded
+	}
+
+	return combinations, nil
+}
+
+// Return the name of the job generated for a matrix combination, for example
+// build-amd64-linux.
+func MatrixJobName(name string, combination map[string]string) string {
+	keys := []string{}
+	for key := range combination {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{name}
+	for _, key := range keys {
+		parts = append(parts, strings.Trim(invalidNameChars.ReplaceAllString(combination[key], "-"), "-"))
+	}
+
+	return strings.Join(parts, "-")
+}
+
+// Replace jobs that have a matrix with one job per combination. The
+// aggregate jobs are returned keyed by name along with the jobs they depend on.
+func expandMatrices(partials []JobPartial) ([]JobPartial, map[string][]string, error) {
+	expanded := []JobPartial{}
+	aggregates := map[string][]string{}
+
+	for _, partial := range partials {
+		if partial.Matrix == nil {
+			expanded = append(expanded, partial)
+			continue
+		}
+
+		combinations, err := MatrixCombinations(*partial.Matrix)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error expanding matrix for job %s: %s", partial.Name, err)
+		}
+
+		aggregates[partial.Name] = []string{}
+
+		for _, combination := range combinations {
+			matrixJob := partial
+			matrixJob.Name = MatrixJobName(partial.Name, combination)
+			matrixJob.MatrixValues = combination
+			matrixJob.Matrix = nil
+
+			aggregates[partial.Name] = append(aggregates[partial.Name], matrixJob.Name)
+			expanded = append(expanded, matrixJob)
+		}
+	}
+
+	return expanded, aggregates, nil
+}
+
+// Create the job that depends on every job generated from a matrix, its outputs
+// are the outputs of all of the generated jobs.
+func aggregateJob(j *job.Job, jobs []*job.Job) {
+	outputs := job.StringSet{}
+
+	for _, matrixJob := range jobs {
+		outputs = append(outputs, matrixJob.GetOutputs()...)
+	}
+
+	j.Aggregate = true
+	j.Outputs = &outputs
+}
+
+func (p *Parser) setMatrix(combination map[string]string) error {
+	if len(combination) == 0 {
+		p.ctx.Variables["matrix"] = cty.EmptyObjectVal
+		return nil
+	}
+
+	matrix, err := gocty.ToCtyValue(combination, cty.Map(cty.String))
+	if err != nil {
+		return err
+	}
+
+	p.ctx.Variables["matrix"] = matrix
+	return nil
+}
@@ -0,0 +1,468 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/zclconf/go-cty/cty"
+)
+
+type Module struct {
+	Name   string   `hcl:"name,label"`
+	Source string   `hcl:"source"`
+	Remain hcl.Body `hcl:",remain"`
+}
+
+// The file names that are tried, in order, when looking for a Honefile in a directory.
+var HonefileNames = []string{"Honefile", "Honefile.hcl", "Honefile.json"}
+
+// Return the Honefile to load for a path, if path is a directory the first of
+// HonefileNames that exists inside of it is used.
+func FindHonefile(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !fi.IsDir() {
+		return path, nil
+	}
+
+	for _, name := range HonefileNames {
+		honefile := filepath.Join(path, name)
+		if fi, err := os.Stat(honefile); err == nil && !fi.IsDir() {
+			return honefile, nil
+		}
+	}
+
+	return "", fmt.Errorf("No Honefile found in %s.", path)
+}
+
+// Parse a file with HCL's JSON syntax if it has a .json extension and with
+// the native syntax otherwise.
+func (p *Parser) parseFile(path string) (*hcl.File, hcl.Diagnostics) {
+	if filepath.Ext(path) == ".json" {
+		return p.parser.ParseJSONFile(path)
+	}
+
+	return p.parser.ParseHCLFile(path)
+}
+
+// Parse a file and merge in any files listed in its include attribute.
+func (p *Parser) loadFile(path string) (hcl.Body, error) {
+	hclFile, diags := p.parseFile(path)
+	if err := p.checkErrors(diags); err != nil {
+		return nil, err
+	}
+
+	load := struct {
+		Include *[]string `hcl:"include"`
+		Remain  hcl.Body  `hcl:",remain"`
+	}{}
+
+	if err := p.checkErrors(p.Decode(hclFile.Body, &load)); err != nil {
+		return nil, err
+	}
+
+	if load.Include == nil {
+		return hclFile.Body, nil
+	}
+
+	bodies := []hcl.Body{hclFile.Body}
+	seen := map[string]bool{filepath.Clean(path): true}
+
+	for _, include := range *load.Include {
+		matches, err := doublestar.Glob(filepath.Join(filepath.Dir(path), include))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("Include %s did not match any files.", include)
+		}
+
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			if seen[filepath.Clean(match)] {
+				continue
+			}
+			seen[filepath.Clean(match)] = true
+
+			includeFile, diags := p.parseFile(match)
+			if err := p.checkErrors(diags); err != nil {
+				return nil, err
+			}
+
+			bodies = append(bodies, includeFile.Body)
+		}
+	}
+
+	return hcl.MergeBodies(bodies), nil
+}
+
+// Return an error for each variable declared in a module, since modules use the
+// variables of the top-level Honefile.
+func moduleVariables(body hcl.Body, module string) hcl.Diagnostics {
+	content, _, _ := body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "variable", LabelNames: []string{"name"}},
+		},
+	})
+
+	diags := hcl.Diagnostics{}
+	for _, block := range content.Blocks {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Variable declared in module",
+			Detail:   fmt.Sprintf("Variable %s is declared in module %s, but variables can only be declared in the top-level Honefile and its includes. Modules can use them as var.<name>.", block.Labels[0], module),
+			Subject:  block.DefRange.Ptr(),
+		})
+	}
+
+	return diags
+}
+
+// Parse the files of the modules in body, recursively, without decoding them.
+func (p *Parser) loadModules(body hcl.Body, loading map[string]bool) error {
+	content, _, diags := body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "module", LabelNames: []string{"name"}},
+		},
+	})
+	if diags.HasErrors() {
+		return diags
+	}
+
+	for _, block := range content.Blocks {
+		attrs, _ := block.Body.JustAttributes()
+
+		source, ok := attrs["source"]
+		if !ok {
+			continue
+		}
+
+		value, diags := source.Expr.Value(p.ctx)
+		if diags.HasErrors() || !value.IsKnown() || value.IsNull() || value.Type() != cty.String {
+			return fmt.Errorf("Could not evaluate the source of module %s.", block.Labels[0])
+		}
+
+		honefile, err := FindHonefile(filepath.Join(filepath.Dir(block.DefRange.Filename), value.AsString()))
+		if err != nil {
+			return err
+		}
+
+		key, err := filepath.Abs(honefile)
+		if err != nil {
+			return err
+		}
+
+		if loading[key] {
+			continue
+		}
+		loading[key] = true
+
+		moduleBody, err := p.loadFile(honefile)
+		if err != nil {
+			return err
+		}
+
+		if err := p.loadModules(moduleBody, loading); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Return the directory that a module's source is in, relative to the root
+// Honefile's directory.
+func (p *Parser) moduleDir(honefile string) string {
+	dir, err := filepath.Rel(p.rootDir(), filepath.Dir(honefile))
+	if err != nil {
+		return filepath.Dir(honefile)
+	}
+
+	return dir
+}
+
+func (p *Parser) rootDir() string {
+	if p.path == "" {
+		return "."
+	}
+
+	return filepath.Dir(p.path)
+}
+
+// Load the jobs, templates and modules in body, recursing into modules. Job names are
+// prefixed with the module's name and each module's templates fall back to its parent's.
+func (p *Parser) decodeModule(body hcl.Body, prefix, dir string, templates []JobPartial, loading map[string]bool) ([]JobPartial, error) {
+	load := struct {
+		Jobs      []JobPartial `hcl:"job,block"`
+		Templates []JobPartial `hcl:"template,block"`
+		Modules   []Module     `hcl:"module,block"`
+		Groups    []Group      `hcl:"group,block"`
+		Remain    hcl.Body     `hcl:",remain"`
+	}{}
+
+	if err := p.checkErrors(p.Decode(body, &load)); err != nil {
+		return nil, err
+	}
+
+	if prefix != "" {
+		if err := p.checkErrors(moduleVariables(body, prefix)); err != nil {
+			return nil, err
+		}
+
+		_, diags := body.Content(moduleSchema)
+		if err := p.checkErrors(diags); err != nil {
+			return nil, err
+		}
+
+		overridden := map[string]bool{}
+		for _, template := range load.Templates {
+			overridden[template.Name] = true
+		}
+
+		for _, template := range templates {
+			if !overridden[template.Name] {
+				load.Templates = append(load.Templates, template)
+			}
+		}
+
+		templates = load.Templates
+	}
+
+	partials := []JobPartial{}
+
+	for _, partial := range load.Jobs {
+		partial.Module = prefix
+		partial.Dir = dir
+		partial.Templates = templates
+		partial.Name = qualify(prefix, partial.Name)
+		partial.DeclRange = declRange(partial.Remain)
+		partials = append(partials, partial)
+	}
+
+	for _, group := range load.Groups {
+		_, diags := group.Remain.Content(&hcl.BodySchema{})
+		if err := p.checkErrors(diags); err != nil {
+			return nil, err
+		}
+
+		group := group
+		partials = append(partials, JobPartial{
+			Name:      qualify(prefix, group.Name),
+			Module:    prefix,
+			Dir:       dir,
+			Remain:    group.Remain,
+			DeclRange: declRange(group.Remain),
+			Group:     &group,
+		})
+	}
+
+	for _, module := range load.Modules {
+		_, diags := module.Remain.Content(&hcl.BodySchema{})
+		if err := p.checkErrors(diags); err != nil {
+			return nil, err
+		}
+
+		if strings.Contains(module.Name, ".") {
+			return nil, fmt.Errorf("Module name %s must not contain '.'.", module.Name)
+		}
+
+		from := filepath.Dir(module.Remain.MissingItemRange().Filename)
+
+		honefile, err := FindHonefile(filepath.Join(from, module.Source))
+		if err != nil {
+			return nil, fmt.Errorf("Error loading module %s: %s", module.Name, err)
+		}
+
+		key, err := filepath.Abs(honefile)
+		if err != nil {
+			return nil, err
+		}
+
+		if loading[key] {
+			return nil, fmt.Errorf("Module %s includes itself.", module.Name)
+		}
+
+		moduleBody, err := p.loadFile(honefile)
+		if err != nil {
+			return nil, err
+		}
+
+		loading[key] = true
+		modulePartials, err := p.decodeModule(moduleBody, qualify(prefix, module.Name), p.moduleDir(honefile), templates, loading)
+		delete(loading, key)
+		if err != nil {
+			return nil, err
+		}
+
+		partials = append(partials, modulePartials...)
+	}
+
+	return partials, nil
+}
+
+// Return the range of the opening brace of a block's body, so that diagnostics
+// show the block's header.
+func declRange(body hcl.Body) hcl.Range {
+	syntaxBody, ok := body.(*hclsyntax.Body)
+	if !ok {
+		return body.MissingItemRange()
+	}
+
+	start := syntaxBody.SrcRange.Start
+	return hcl.Range{
+		Filename: syntaxBody.SrcRange.Filename,
+		Start:    start,
+		End:      hcl.Pos{Line: start.Line, Column: start.Column + 1, Byte: start.Byte + 1},
+	}
+}
+
+func qualify(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "." + name
+}
+
+// Resolve a dependency reference (such as "build.outputs" or "svc.build") from a
+// module to a job name, preferring jobs in the same module.
+func resolveDep(module, ref string, names map[string]bool) string {
+	parts := strings.Split(ref, ".")
+
+	for i := len(parts); i > 0; i-- {
+		name := strings.Join(parts[:i], ".")
+
+		if module != "" && names[qualify(module, name)] {
+			return qualify(module, name)
+		}
+
+		if names[name] {
+			return name
+		}
+	}
+
+	return qualify(module, parts[0])
+}
+
+// Convert a path relative to the root directory to one relative to dir.
+func relativeTo(dir string) func(string) string {
+	return func(path string) string {
+		if dir == "" || dir == "." || filepath.IsAbs(path) {
+			return path
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return path
+		}
+
+		return rel
+	}
+}
+
+// Convert a path relative to dir to one relative to the root directory.
+func relativeToRoot(dir string) func(string) string {
+	return func(path string) string {
+		if dir == "" || dir == "." || filepath.IsAbs(path) {
+			return path
+		}
+
+		return filepath.Join(dir, path)
+	}
+}
+
+// Return a job with its paths made relative to the root directory.
+func moduleJob(j *job.Job, dir string) *job.Job {
+	if dir == "" || dir == "." {
+		return j
+	}
+
+	if j.Workdir == nil {
+		workdir := "."
+		j.Workdir = &workdir
+	}
+
+	if j.Build != nil && j.Build.Context == nil {
+		context := "."
+		j.Build.Context = &context
+	}
+
+	mapped := j.MapPaths(relativeToRoot(dir))
+	j.Inputs = mapped.Inputs
+	j.Outputs = mapped.Outputs
+	j.TestReports = mapped.TestReports
+	j.Workdir = mapped.Workdir
+	j.Build = mapped.Build
+	return j
+}
+
+// Build the value of the jobs variable for the module being decoded: jobs are nested
+// by module and the module's own jobs are also available without their prefix.
+func jobsTree(values map[string]cty.Value, module string) cty.Value {
+	tree := map[string]interface{}{}
+
+	for name, value := range values {
+		node := tree
+		parts := strings.Split(name, ".")
+
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[part] = child
+			}
+			node = child
+		}
+
+		node[parts[len(parts)-1]] = value
+	}
+
+	if module != "" {
+		node := tree
+		for _, part := range strings.Split(module, ".") {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				node = nil
+				break
+			}
+			node = child
+		}
+
+		for key, value := range node {
+			tree[key] = value
+		}
+	}
+
+	return treeToCty(tree)
+}
+
+func treeToCty(tree map[string]interface{}) cty.Value {
+	if len(tree) == 0 {
+		return cty.EmptyObjectVal
+	}
+
+	objMap := map[string]cty.Value{}
+
+	for key, value := range tree {
+		switch v := value.(type) {
+		case cty.Value:
+			objMap[key] = v
+		case map[string]interface{}:
+			objMap[key] = treeToCty(v)
+		}
+	}
+
+	return cty.ObjectVal(objMap)
+}
@@ -6,21 +6,28 @@ import (
 
 	"github.com/justinbarrick/hone/pkg/cache/file"
 	"github.com/justinbarrick/hone/pkg/cache/s3"
+	"github.com/justinbarrick/hone/pkg/ci"
 	"github.com/justinbarrick/hone/pkg/executors/docker"
 	"github.com/justinbarrick/hone/pkg/executors/kubernetes"
+	"github.com/justinbarrick/hone/pkg/git"
 	"github.com/justinbarrick/hone/pkg/graph/node"
 	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/notify"
 	"github.com/justinbarrick/hone/pkg/scm"
 )
 
 type Config struct {
 	Env          map[string]string
+	Git          git.Metadata
+	CI           *ci.Build
 	Secrets      map[string]string
 	SCM          []*scm.SCM
+	Notify       []*notify.Notifier
 	Jobs         []*job.Job
 	Cache        CacheConfig
 	Kubernetes   *kubernetes.Kubernetes
 	DockerConfig *docker.DockerConfig
+	Registries   []*docker.Registry
 	Engine       *string
 }
 
@@ -30,6 +37,12 @@ type CacheConfig struct {
 }
 
 func (c Config) Validate() error {
+	for _, notifier := range c.Notify {
+		if err := notifier.Validate(); err != nil {
+			return errors.New(fmt.Sprintf("Error validating notify %s: %s", notifier.Driver, err))
+		}
+	}
+
 	for _, job := range c.Jobs {
 		if err := job.Validate(c.GetEngine()); err != nil {
 			return errors.New(fmt.Sprintf("Error validating job %s: %s", job.GetName(), err))
@@ -60,6 +73,25 @@ func (c Config) RenderTemplates(templates []*job.Job) error {
 	return nil
 }
 
+// Return the variables available to conditions: the environment along with the
+// git and CI metadata, where GIT_TAGS is a list and GIT_DIRTY a boolean.
+func (c Config) Conditions() map[string]interface{} {
+	conditions := map[string]interface{}{}
+	for key, value := range c.Env {
+		conditions[key] = value
+	}
+
+	for key, value := range c.CI.Env() {
+		conditions[key] = value
+	}
+
+	for key, value := range c.Git.Conditions() {
+		conditions[key] = value
+	}
+
+	return conditions
+}
+
 func (c Config) GetEngine() string {
 	if c.Engine != nil {
 		return *c.Engine
@@ -0,0 +1,407 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/justinbarrick/hone/pkg/config/types"
+	"github.com/justinbarrick/hone/pkg/graph"
+	"github.com/justinbarrick/hone/pkg/job"
+)
+
+var rootSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "env"},
+		{Name: "secrets"},
+		{Name: "workspace"},
+		{Name: "engine"},
+		{Name: "include"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "vault"},
+		{Type: "cache"},
+		{Type: "repository"},
+		{Type: "notify", LabelNames: []string{"driver"}},
+		{Type: "registry", LabelNames: []string{"address"}},
+		{Type: "kubernetes"},
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "template", LabelNames: []string{"name"}},
+		{Type: "job", LabelNames: []string{"name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+		{Type: "group", LabelNames: []string{"name"}},
+	},
+}
+
+var moduleSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "include"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "template", LabelNames: []string{"name"}},
+		{Type: "job", LabelNames: []string{"name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+		{Type: "group", LabelNames: []string{"name"}},
+	},
+}
+
+// Convert an error to diagnostics, errors that are not diagnostics have no
+// source range.
+func toDiagnostics(err error) hcl.Diagnostics {
+	if err == nil {
+		return nil
+	}
+
+	if diags, ok := err.(hcl.Diagnostics); ok {
+		return diags
+	}
+
+	return hcl.Diagnostics{
+		&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  err.Error(),
+		},
+	}
+}
+
+// Check a job or template body for attributes and blocks that are not supported.
+func checkJobBody(body hcl.Body) hcl.Diagnostics {
+	schema, _ := gohcl.ImpliedBodySchema(&job.Job{})
+	buildSchema, _ := gohcl.ImpliedBodySchema(&job.Build{})
+
+	content, diags := body.Content(schema)
+	if content == nil {
+		return diags
+	}
+
+	for _, block := range content.Blocks {
+		if block.Type != "build" {
+			continue
+		}
+
+		_, buildDiags := block.Body.Content(buildSchema)
+		diags = append(diags, buildDiags...)
+	}
+
+	return diags
+}
+
+// Validate the configuration without running any jobs. Reports unknown attributes,
+// missing templates and dependencies, duplicate jobs, dependency cycles, invalid
+// jobs and jobs that none of the targets depend on. If the targets were not set
+// explicitly, a missing target is only a warning.
+func (p *Parser) Validate(targets []string, explicitTargets bool) hcl.Diagnostics {
+	quiet := p.Quiet
+	p.Quiet = true
+	defer func() {
+		p.Quiet = quiet
+	}()
+
+	_, diags := p.body.Content(rootSchema)
+
+	variables, err := p.DecodeVariableBlocks()
+	diags = append(diags, toDiagnostics(err)...)
+	for _, variable := range variables {
+		_, varDiags := variable.Remain.Content(&hcl.BodySchema{})
+		diags = append(diags, varDiags...)
+	}
+
+	config := types.Config{}
+	if err := p.decodeInputs(&config); err != nil {
+		return append(diags, toDiagnostics(err)...)
+	}
+
+	templates, err := p.DecodeTemplates()
+	if err != nil {
+		return append(diags, toDiagnostics(err)...)
+	}
+
+	loading := map[string]bool{}
+	if p.path != "" {
+		if key, err := filepath.Abs(p.path); err == nil {
+			loading[key] = true
+		}
+	}
+
+	partials, err := p.decodeModule(p.body, "", "", templates, loading)
+	if err != nil {
+		return append(diags, toDiagnostics(err)...)
+	}
+
+	checked := map[string]bool{}
+	checkTemplates := func(templates []JobPartial) {
+		for _, template := range templates {
+			declRange := declRange(template.Remain)
+			if checked[declRange.String()] {
+				continue
+			}
+			checked[declRange.String()] = true
+
+			diags = append(diags, checkJobBody(template.Remain)...)
+		}
+	}
+
+	checkTemplates(templates)
+	for _, partial := range partials {
+		if partial.Group != nil {
+			continue
+		}
+
+		checkTemplates(partial.Templates)
+		diags = append(diags, checkJobBody(partial.Remain)...)
+	}
+
+	partials, aggregates, err := expandMatrices(partials)
+	if err != nil {
+		return append(diags, toDiagnostics(err)...)
+	}
+
+	names := map[string]bool{}
+	ranges := map[string]hcl.Range{}
+
+	for _, partial := range partials {
+		declRange := partial.DeclRange
+
+		if names[partial.Name] {
+			previous := ranges[partial.Name]
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate job",
+				Detail:   fmt.Sprintf("Job %s is already defined at %s.", partial.Name, previous.String()),
+				Subject:  &declRange,
+			})
+			continue
+		}
+
+		names[partial.Name] = true
+		ranges[partial.Name] = declRange
+	}
+
+	for name := range aggregates {
+		if names[name] {
+			declRange := ranges[name]
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate job",
+				Detail:   fmt.Sprintf("Job %s conflicts with a matrix job of the same name.", name),
+				Subject:  &declRange,
+			})
+		}
+		names[name] = true
+	}
+
+	g := graph.NewGraph(nil)
+
+	for name, matrixJobs := range aggregates {
+		g.AddNode(aggregateNode(name, matrixJobs, nil))
+	}
+
+	added := map[string]bool{}
+
+	for _, partial := range partials {
+		declRange := partial.DeclRange
+
+		if added[partial.Name] {
+			continue
+		}
+		added[partial.Name] = true
+
+		var deps []string
+
+		if partial.Group != nil {
+			deps = partial.Group.Targets
+		} else {
+			if _, err := p.TemplateForJob(&job.Job{Name: partial.Name, Template: partial.Template}, partial.Templates, false); err != nil {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Missing template",
+					Detail:   fmt.Sprintf("Job %s uses template %s, which does not exist.", partial.Name, *partial.Template),
+					Subject:  &declRange,
+				})
+				continue
+			}
+
+			deps, err = partial.GetDeps(p, partial.Templates, false)
+			if err != nil {
+				diags = append(diags, toDiagnostics(err)...)
+				continue
+			}
+		}
+
+		j := &job.Job{
+			Name: partial.Name,
+		}
+
+		g.AddNode(j)
+
+		for _, dep := range deps {
+			resolved := resolveDep(partial.Module, dep, names)
+
+			if resolved == partial.Name {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Dependency cycle",
+					Detail:   fmt.Sprintf("Job %s depends on itself.", partial.Name),
+					Subject:  &declRange,
+				})
+				continue
+			}
+
+			if !names[resolved] {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Missing dependency",
+					Detail:   fmt.Sprintf("Job %s depends on %s, which does not exist.", partial.Name, resolved),
+					Subject:  &declRange,
+				})
+				continue
+			}
+
+			j.AddDep(resolved)
+		}
+	}
+
+	for _, cycle := range g.Cycles() {
+		diag := &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Dependency cycle",
+			Detail:   fmt.Sprintf("Dependency cycle between %s.", strings.Join(cycle, ", ")),
+		}
+
+		if declRange, ok := ranges[cycle[0]]; ok {
+			diag.Subject = &declRange
+		}
+
+		diags = append(diags, diag)
+	}
+
+	if diags.HasErrors() {
+		return diags
+	}
+
+	if err := p.decodeConfig(&config, templates); err != nil {
+		return append(diags, toDiagnostics(err)...)
+	}
+
+	for _, j := range config.Jobs {
+		if err := j.Validate(config.GetEngine()); err != nil {
+			diag := &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid job",
+				Detail:   fmt.Sprintf("Job %s: %s", j.GetName(), err),
+			}
+
+			if declRange, ok := ranges[j.GetName()]; ok {
+				diag.Subject = &declRange
+			}
+
+			diags = append(diags, diag)
+		}
+	}
+
+	for _, n := range config.Notify {
+		if err := n.Validate(); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid notify block",
+				Detail:   fmt.Sprintf("Notify %s: %s", n.Driver, err),
+			})
+		}
+	}
+
+	missing := false
+	for _, target := range targets {
+		if names[target] {
+			continue
+		}
+
+		severity := hcl.DiagWarning
+		if explicitTargets {
+			severity = hcl.DiagError
+		}
+
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: severity,
+			Summary:  "Missing target",
+			Detail:   fmt.Sprintf("Target %s does not exist.", target),
+		})
+		missing = true
+	}
+
+	if missing {
+		return diags
+	}
+
+	unreachable, err := g.Unreachable(targets)
+	if err != nil {
+		return append(diags, toDiagnostics(err)...)
+	}
+
+	for _, name := range unreachable {
+		diag := &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unreachable job",
+			Detail:   fmt.Sprintf("Job %s is not a dependency of %s.", name, strings.Join(targets, ", ")),
+		}
+
+		if declRange, ok := ranges[name]; ok {
+			diag.Subject = &declRange
+		}
+
+		diags = append(diags, diag)
+	}
+
+	return diags
+}
+
+type jsonPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+type jsonRange struct {
+	Filename string  `json:"filename"`
+	Start    jsonPos `json:"start"`
+	End      jsonPos `json:"end"`
+}
+
+type jsonDiagnostic struct {
+	Severity string     `json:"severity"`
+	Summary  string     `json:"summary"`
+	Detail   string     `json:"detail,omitempty"`
+	Range    *jsonRange `json:"range,omitempty"`
+}
+
+// Encode diagnostics as a JSON list.
+func MarshalDiagnostics(diags hcl.Diagnostics) ([]byte, error) {
+	encoded := []jsonDiagnostic{}
+
+	for _, diag := range diags {
+		severity := "error"
+		if diag.Severity == hcl.DiagWarning {
+			severity = "warning"
+		}
+
+		jsonDiag := jsonDiagnostic{
+			Severity: severity,
+			Summary:  diag.Summary,
+			Detail:   diag.Detail,
+		}
+
+		if diag.Subject != nil {
+			jsonDiag.Range = &jsonRange{
+				Filename: diag.Subject.Filename,
+				Start:    jsonPos{diag.Subject.Start.Line, diag.Subject.Start.Column, diag.Subject.Start.Byte},
+				End:      jsonPos{diag.Subject.End.Line, diag.Subject.End.Column, diag.Subject.End.Byte},
+			}
+		}
+
+		encoded = append(encoded, jsonDiag)
+	}
+
+	return json.MarshalIndent(encoded, "", "  ")
+}
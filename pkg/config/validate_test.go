@@ -0,0 +1,208 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/stretchr/testify/assert"
+)
+
+func validateConfig(t *testing.T, config, target string, explicit bool) hcl.Diagnostics {
+	parser := NewParser()
+	parser.Quiet = true
+	parser.SkipSecrets = true
+	assert.Nil(t, parser.Parse(config))
+	return parser.Validate([]string{target}, explicit)
+}
+
+func summaries(diags hcl.Diagnostics) []string {
+	result := []string{}
+	for _, diag := range diags {
+		result = append(result, diag.Summary)
+	}
+	return result
+}
+
+func TestValidate(t *testing.T) {
+	diags := validateConfig(t, `
+job "build" {
+	image = "golang"
+	shell = "go build"
+}
+
+job "all" {
+	image = "alpine"
+	deps = ["build"]
+	shell = "echo ${jobs.build.image}"
+}
+`, "all", true)
+
+	assert.Equal(t, 0, len(diags))
+}
+
+func TestValidateUnknownAttribute(t *testing.T) {
+	diags := validateConfig(t, `
+unknown = "hello"
+
+job "packer" {
+	image = "hashicorp/packer"
+	input = "packer/*"
+	shell = "packer build"
+
+	build {
+		contxt = "."
+	}
+}
+`, "packer", true)
+
+	assert.Equal(t, []string{"Unsupported attribute", "Unsupported attribute", "Unsupported attribute"}, summaries(diags))
+	assert.Equal(t, 2, diags[0].Subject.Start.Line)
+	assert.Equal(t, 6, diags[1].Subject.Start.Line)
+	assert.Contains(t, diags[1].Detail, `Did you mean "inputs"?`)
+	assert.Equal(t, 10, diags[2].Subject.Start.Line)
+}
+
+func TestValidateDependencies(t *testing.T) {
+	diags := validateConfig(t, `
+job "a" {
+	image = "alpine"
+	deps = ["missing"]
+	shell = "echo"
+}
+
+job "b" {
+	template = "missing"
+	shell = "echo"
+}
+
+job "a" {
+	image = "alpine"
+	shell = "echo"
+}
+`, "a", true)
+
+	assert.Equal(t, []string{"Duplicate job", "Missing dependency", "Missing template"}, summaries(diags))
+	assert.Equal(t, 13, diags[0].Subject.Start.Line)
+	assert.Equal(t, "Job a depends on missing, which does not exist.", diags[1].Detail)
+	assert.Equal(t, 2, diags[1].Subject.Start.Line)
+	assert.Equal(t, 8, diags[2].Subject.Start.Line)
+}
+
+func TestValidateCycle(t *testing.T) {
+	diags := validateConfig(t, `
+job "a" {
+	image = "alpine"
+	shell = "echo ${jobs.c.image}"
+}
+
+job "b" {
+	image = "alpine"
+	shell = "echo ${jobs.a.image}"
+}
+
+job "c" {
+	image = "alpine"
+	deps = ["b"]
+	shell = "echo"
+}
+
+job "d" {
+	image = "alpine"
+	deps = ["d"]
+	shell = "echo"
+}
+`, "a", true)
+
+	assert.Equal(t, 2, len(diags))
+	assert.Equal(t, "Job d depends on itself.", diags[0].Detail)
+	assert.Equal(t, "Dependency cycle between a, b, c.", diags[1].Detail)
+}
+
+func TestValidateEngine(t *testing.T) {
+	diags := validateConfig(t, `
+engine = "local"
+
+job "image" {
+	build {
+		context = "."
+	}
+}
+`, "image", true)
+
+	assert.Equal(t, 1, len(diags))
+	assert.Equal(t, "Job image: Build is only supported by the docker engine.", diags[0].Detail)
+	assert.Equal(t, 4, diags[0].Subject.Start.Line)
+}
+
+func TestValidateNotify(t *testing.T) {
+	diags := validateConfig(t, `
+notify "slack" {
+	url = "https://hooks.slack.com/services/T000/B000/XXX"
+	condition = "BUILD_STATE='failure' and GIT_BRANCH='master'"
+}
+
+notify "smtp" {
+	address = "smtp.example.com:587"
+	events = ["finished", "merged"]
+}
+
+notify "irc" {
+}
+
+job "build" {
+	image = "golang"
+	shell = "go build"
+}
+`, "build", true)
+
+	assert.Equal(t, []string{"Invalid notify block", "Invalid notify block"}, summaries(diags))
+	assert.Equal(t, "Notify smtp: Unknown event merged, must be one of started, finished or job_failed.", diags[0].Detail)
+	assert.Equal(t, "Notify irc: Unknown notification driver irc, must be one of webhook, slack or smtp.", diags[1].Detail)
+}
+
+func TestValidateTarget(t *testing.T) {
+	config := `
+job "build" {
+	image = "golang"
+	shell = "go build"
+}
+
+job "lint" {
+	image = "golang"
+	shell = "go vet"
+}
+`
+
+	diags := validateConfig(t, config, "build", true)
+	assert.Equal(t, 1, len(diags))
+	assert.Equal(t, hcl.DiagWarning, diags[0].Severity)
+	assert.Equal(t, "Job lint is not a dependency of build.", diags[0].Detail)
+
+	diags = validateConfig(t, config, "all", true)
+	assert.True(t, diags.HasErrors())
+
+	diags = validateConfig(t, config, "all", false)
+	assert.False(t, diags.HasErrors())
+	assert.Equal(t, []string{"Missing target"}, summaries(diags))
+}
+
+func TestMarshalDiagnostics(t *testing.T) {
+	diags := validateConfig(t, `
+job "a" {
+	image = "alpine"
+	deps = ["missing"]
+	shell = "echo"
+}
+`, "a", true)
+
+	encoded, err := MarshalDiagnostics(diags)
+	assert.Nil(t, err)
+
+	decoded := []map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, 1, len(decoded))
+	assert.Equal(t, "error", decoded[0]["severity"])
+	assert.Equal(t, "Missing dependency", decoded[0]["summary"])
+	assert.Equal(t, "test", decoded[0]["range"].(map[string]interface{})["filename"])
+}
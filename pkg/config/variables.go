@@ -0,0 +1,297 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/ext/typeexpr"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+const VarEnvPrefix = "HONE_VAR_"
+
+type Validation struct {
+	Condition    hcl.Expression `hcl:"condition"`
+	ErrorMessage string         `hcl:"error_message"`
+}
+
+type Variable struct {
+	Name        string         `hcl:"name,label"`
+	Type        hcl.Expression `hcl:"type"`
+	Default     *cty.Value     `hcl:"default"`
+	Description *string        `hcl:"description"`
+	Validations []Validation   `hcl:"validation,block"`
+	Remain      hcl.Body       `hcl:",remain"`
+}
+
+func (v Variable) GetDescription() string {
+	if v.Description == nil {
+		return ""
+	}
+
+	return *v.Description
+}
+
+func (v Variable) DeclRange() hcl.Range {
+	return declRange(v.Remain)
+}
+
+// Return the variable's type constraint, cty.DynamicPseudoType if it has none.
+func (v Variable) GetType() (cty.Type, hcl.Diagnostics) {
+	if v.Type == nil {
+		return cty.DynamicPseudoType, nil
+	}
+
+	if value, diags := v.Type.Value(nil); !diags.HasErrors() && value.IsNull() {
+		return cty.DynamicPseudoType, nil
+	}
+
+	return typeexpr.TypeConstraint(v.Type)
+}
+
+// Parse a variable value set on the command line or in the environment. Strings
+// are used as is, other types are parsed as HCL expressions.
+func ParseVariableValue(name, raw string, ty cty.Type) (cty.Value, hcl.Diagnostics) {
+	if ty == cty.String || ty == cty.DynamicPseudoType {
+		return cty.StringVal(raw), nil
+	}
+
+	expr, diags := hclsyntax.ParseExpression([]byte(raw), fmt.Sprintf("<value for var.%s>", name), hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+
+	return expr.Value(nil)
+}
+
+// Parse the -var command line flags in the form name=value.
+func ParseVarFlags(flags []string) (map[string]string, error) {
+	vars := map[string]string{}
+
+	for _, flag := range flags {
+		split := strings.SplitN(flag, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			return nil, fmt.Errorf("Invalid variable %q, must be in the form name=value.", flag)
+		}
+
+		vars[split[0]] = split[1]
+	}
+
+	return vars, nil
+}
+
+func (p *Parser) DecodeVariableBlocks() ([]Variable, error) {
+	load := struct {
+		Variables []Variable `hcl:"variable,block"`
+		Remain    hcl.Body   `hcl:",remain"`
+	}{}
+
+	if err := p.DecodeBody(&load); err != nil {
+		return nil, err
+	}
+
+	return load.Variables, nil
+}
+
+// Load the attributes of a variable file, either HCL or JSON.
+func (p *Parser) loadVarFile(path string) (hcl.Attributes, hcl.Diagnostics) {
+	file, diags := p.parseFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	return file.Body.JustAttributes()
+}
+
+// Decode variable blocks and set their values from, in increasing order of
+// precedence, their defaults, HONE_VAR_ environment variables, variable files
+// and -var flags. The values are exposed as var.<name>.
+func (p *Parser) DecodeVariables() (map[string]cty.Value, error) {
+	ctx := p.GetContext()
+
+	variables, err := p.DecodeVariableBlocks()
+	if err != nil {
+		return nil, err
+	}
+
+	declared := map[string]Variable{}
+	types := map[string]cty.Type{}
+	values := map[string]cty.Value{}
+	ranges := map[string]*hcl.Range{}
+	diags := hcl.Diagnostics{}
+
+	for _, variable := range variables {
+		declRange := variable.DeclRange()
+
+		if _, ok := declared[variable.Name]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate variable",
+				Detail:   fmt.Sprintf("Variable %s is declared more than once.", variable.Name),
+				Subject:  &declRange,
+			})
+			continue
+		}
+
+		declared[variable.Name] = variable
+
+		ty, typeDiags := variable.GetType()
+		diags = append(diags, typeDiags...)
+		types[variable.Name] = ty
+
+		// A null default makes the variable optional, its value is null.
+		if variable.Default != nil {
+			values[variable.Name] = *variable.Default
+			ranges[variable.Name] = &declRange
+		}
+	}
+
+	for _, env := range os.Environ() {
+		split := strings.SplitN(env, "=", 2)
+		if len(split) != 2 || !strings.HasPrefix(split[0], VarEnvPrefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(split[0], VarEnvPrefix)
+		if _, ok := declared[name]; !ok {
+			continue
+		}
+
+		value, valueDiags := ParseVariableValue(name, split[1], types[name])
+		diags = append(diags, valueDiags...)
+		values[name] = value
+		ranges[name] = nil
+	}
+
+	for _, path := range p.VarFiles {
+		attrs, fileDiags := p.loadVarFile(path)
+		diags = append(diags, fileDiags...)
+
+		for name, attr := range attrs {
+			if _, ok := declared[name]; !ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Undeclared variable",
+					Detail:   fmt.Sprintf("A value was set for variable %s, but it is not declared.", name),
+					Subject:  &attr.NameRange,
+				})
+				continue
+			}
+
+			value, valueDiags := attr.Expr.Value(nil)
+			diags = append(diags, valueDiags...)
+			values[name] = value
+			ranges[name] = attr.Expr.Range().Ptr()
+		}
+	}
+
+	names := []string{}
+	for name := range p.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := declared[name]; !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Undeclared variable",
+				Detail:   fmt.Sprintf("A value was set for variable %s with -var, but it is not declared.", name),
+			})
+			continue
+		}
+
+		value, valueDiags := ParseVariableValue(name, p.Vars[name], types[name])
+		diags = append(diags, valueDiags...)
+		values[name] = value
+		ranges[name] = nil
+	}
+
+	if diags.HasErrors() {
+		return nil, p.checkErrors(diags)
+	}
+
+	for _, variable := range variables {
+		declRange := variable.DeclRange()
+		value, ok := values[variable.Name]
+
+		if !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "No value for required variable",
+				Detail:   fmt.Sprintf("Variable %s has no default, set it with -var, -var-file or %s%s.", variable.Name, VarEnvPrefix, variable.Name),
+				Subject:  &declRange,
+			})
+			continue
+		}
+
+		converted, err := convert.Convert(value, types[variable.Name])
+		if err != nil {
+			subject := ranges[variable.Name]
+			if subject == nil {
+				subject = &declRange
+			}
+
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid value for variable",
+				Detail:   fmt.Sprintf("Variable %s must be %s: %s.", variable.Name, typeexpr.TypeString(types[variable.Name]), err),
+				Subject:  subject,
+			})
+			continue
+		}
+
+		values[variable.Name] = converted
+	}
+
+	if diags.HasErrors() {
+		return nil, p.checkErrors(diags)
+	}
+
+	if len(values) == 0 {
+		ctx.Variables["var"] = cty.EmptyObjectVal
+	} else {
+		ctx.Variables["var"] = cty.ObjectVal(values)
+	}
+
+	for _, variable := range variables {
+		for _, validation := range variable.Validations {
+			result, valueDiags := validation.Condition.Value(ctx)
+			diags = append(diags, valueDiags...)
+			if valueDiags.HasErrors() {
+				continue
+			}
+
+			result, err := convert.Convert(result, cty.Bool)
+			if err != nil || result.IsNull() || !result.IsKnown() {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid validation condition",
+					Detail:   "The condition must be a boolean.",
+					Subject:  validation.Condition.Range().Ptr(),
+				})
+				continue
+			}
+
+			if result.False() {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  fmt.Sprintf("Invalid value for variable %s", variable.Name),
+					Detail:   validation.ErrorMessage,
+					Subject:  validation.Condition.Range().Ptr(),
+				})
+			}
+		}
+	}
+
+	if diags.HasErrors() {
+		return nil, p.checkErrors(diags)
+	}
+
+	return values, nil
+}
@@ -16,14 +16,9 @@ func YQLMatch(condition *string, env map[string]interface{}) (bool, error) {
 	return yql.Match(*condition, env)
 }
 
-func EventCallback(env map[string]string, cb func(j *job.Job) error) func(j *job.Job) error {
-	envMap := map[string]interface{}{}
-	for key, val := range env {
-		envMap[key] = val
-	}
-
+func EventCallback(env map[string]interface{}, cb func(j *job.Job) error) func(j *job.Job) error {
 	return func(j *job.Job) error {
-		run, err := YQLMatch(j.Condition, envMap)
+		run, err := YQLMatch(j.Condition, env)
 		if err != nil {
 			return err
 		}
@@ -32,6 +27,7 @@ func EventCallback(env map[string]string, cb func(j *job.Job) error) func(j *job
 			return cb(j)
 		}
 
+		j.Skipped = true
 		logger.Log(j, fmt.Sprintf("Skipping job, since condition not met: %s", *j.Condition))
 		return nil
 	}
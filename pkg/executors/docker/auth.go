@@ -0,0 +1,244 @@
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/justinbarrick/hone/pkg/logger"
+)
+
+type Registry struct {
+	Address  string `hcl:"address,label"`
+	Username string `hcl:"username"`
+	Password string `hcl:"password"`
+}
+
+type dockerConfigAuth struct {
+	Auth          string `json:"auth"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+type dockerConfigFile struct {
+	Auths       map[string]dockerConfigAuth `json:"auths"`
+	CredsStore  string                      `json:"credsStore"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+}
+
+// The credentials printed by a credential helper.
+type helperCredentials struct {
+	Username string
+	Secret   string
+}
+
+func EncodeAuth(auth types.AuthConfig) (string, error) {
+	encoded, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(encoded), nil
+}
+
+func RegistryForImage(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+
+	return reference.Domain(named), nil
+}
+
+func NormalizeRegistry(address string) string {
+	address = strings.TrimPrefix(address, "https://")
+	address = strings.TrimPrefix(address, "http://")
+	address = strings.SplitN(address, "/", 2)[0]
+
+	switch address {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return "docker.io"
+	}
+
+	return address
+}
+
+func DockerConfigPath() string {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	return filepath.Join(dir, "config.json")
+}
+
+// Read a Docker config.json, a missing file is an empty config.
+func readDockerConfig(path string) (dockerConfigFile, error) {
+	cfg := dockerConfigFile{}
+
+	if path == "" {
+		return cfg, nil
+	}
+
+	cfgFile, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+	defer cfgFile.Close()
+
+	err = json.NewDecoder(cfgFile).Decode(&cfg)
+	return cfg, err
+}
+
+func configAuths(cfg dockerConfigFile) (map[string]types.AuthConfig, error) {
+	auths := map[string]types.AuthConfig{}
+
+	for address, auth := range cfg.Auths {
+		authConfig := types.AuthConfig{
+			ServerAddress: address,
+			IdentityToken: auth.IdentityToken,
+		}
+
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, err
+			}
+
+			userPass := strings.SplitN(string(decoded), ":", 2)
+			if len(userPass) == 2 {
+				authConfig.Username = userPass[0]
+				authConfig.Password = userPass[1]
+			}
+		}
+
+		auths[NormalizeRegistry(address)] = authConfig
+	}
+
+	return auths, nil
+}
+
+func LoadDockerConfigAuths(path string) (map[string]types.AuthConfig, error) {
+	cfg, err := readDockerConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return configAuths(cfg)
+}
+
+// Get the credentials for a registry from a credential helper, by running
+// docker-credential-<helper> like the Docker CLI does.
+func helperAuth(helper, registry string) (types.AuthConfig, error) {
+	serverAddress := registry
+	if registry == "docker.io" {
+		serverAddress = "https://index.docker.io/v1/"
+	}
+
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverAddress)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return types.AuthConfig{}, fmt.Errorf("Error running credential helper %s: %s", helper, err)
+	}
+
+	creds := helperCredentials{}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return types.AuthConfig{}, fmt.Errorf("Error decoding credentials from helper %s: %s", helper, err)
+	}
+
+	auth := types.AuthConfig{
+		ServerAddress: serverAddress,
+	}
+
+	if creds.Username == "<token>" {
+		auth.IdentityToken = creds.Secret
+	} else {
+		auth.Username = creds.Username
+		auth.Password = creds.Secret
+	}
+
+	return auth, nil
+}
+
+func (dc *DockerConfig) LoadAuths() error {
+	cfg, err := readDockerConfig(DockerConfigPath())
+	if err != nil {
+		return err
+	}
+
+	auths, err := configAuths(cfg)
+	if err != nil {
+		return err
+	}
+
+	dc.auths = auths
+	dc.credsStore = cfg.CredsStore
+	dc.credHelpers = map[string]string{}
+	for address, helper := range cfg.CredHelpers {
+		dc.credHelpers[NormalizeRegistry(address)] = helper
+	}
+
+	return nil
+}
+
+// Return the credential helper for a registry, if there is one.
+func (dc *DockerConfig) helperFor(registry string) string {
+	if helper, ok := dc.credHelpers[registry]; ok {
+		return helper
+	}
+
+	return dc.credsStore
+}
+
+func (dc *DockerConfig) AuthFor(registry string) types.AuthConfig {
+	registry = NormalizeRegistry(registry)
+
+	for _, r := range dc.Registries {
+		if NormalizeRegistry(r.Address) == registry && r.Username != "" {
+			return types.AuthConfig{
+				Username:      r.Username,
+				Password:      r.Password,
+				ServerAddress: registry,
+			}
+		}
+	}
+
+	if helper := dc.helperFor(registry); helper != "" {
+		auth, err := helperAuth(helper, registry)
+		if err == nil {
+			return auth
+		}
+		logger.Printf("Failed to get credentials for %s: %s", registry, err)
+	}
+
+	if auth, ok := dc.auths[registry]; ok {
+		return auth
+	}
+
+	return types.AuthConfig{
+		ServerAddress: registry,
+	}
+}
+
+func (dc *DockerConfig) EncodedAuthFor(image string) (string, error) {
+	registry, err := RegistryForImage(image)
+	if err != nil {
+		return "", err
+	}
+
+	return EncodeAuth(dc.AuthFor(registry))
+}
@@ -0,0 +1,139 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeRegistry(t *testing.T) {
+	assert.Equal(t, "docker.io", NormalizeRegistry("https://index.docker.io/v1/"))
+	assert.Equal(t, "docker.io", NormalizeRegistry("docker.io"))
+	assert.Equal(t, "quay.io", NormalizeRegistry("https://quay.io"))
+	assert.Equal(t, "localhost:5000", NormalizeRegistry("localhost:5000"))
+}
+
+func TestRegistryForImage(t *testing.T) {
+	registry, err := RegistryForImage("alpine")
+	assert.Nil(t, err)
+	assert.Equal(t, "docker.io", registry)
+
+	registry, err = RegistryForImage("quay.io/justinbarrick/hone:latest")
+	assert.Nil(t, err)
+	assert.Equal(t, "quay.io", registry)
+}
+
+func TestAuthFor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hone-docker-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{
+  "auths": {
+    "https://index.docker.io/v1/": {"auth": "dXNlcjpwYXNz"},
+    "quay.io": {"auth": "cXVheTpzZWNyZXQ="}
+  }
+}`), 0600)
+	assert.Nil(t, err)
+
+	auths, err := LoadDockerConfigAuths(path)
+	assert.Nil(t, err)
+
+	dc := DockerConfig{
+		auths: auths,
+		Registries: []*Registry{
+			{
+				Address:  "quay.io",
+				Username: "secret-user",
+				Password: "secret-pass",
+			},
+		},
+	}
+
+	assert.Equal(t, types.AuthConfig{
+		Username:      "user",
+		Password:      "pass",
+		ServerAddress: "https://index.docker.io/v1/",
+	}, dc.AuthFor("docker.io"))
+
+	assert.Equal(t, types.AuthConfig{
+		Username:      "secret-user",
+		Password:      "secret-pass",
+		ServerAddress: "quay.io",
+	}, dc.AuthFor("quay.io"))
+
+	assert.Equal(t, types.AuthConfig{
+		ServerAddress: "gcr.io",
+	}, dc.AuthFor("gcr.io"))
+}
+
+func TestLoadDockerConfigAuthsMissing(t *testing.T) {
+	auths, err := LoadDockerConfigAuths("/does/not/exist/config.json")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(auths))
+}
+
+func TestAuthForCredentialHelpers(t *testing.T) {
+	logger.InitLogger(0, nil)
+
+	dir, err := ioutil.TempDir("", "hone-docker-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	helper := `#!/bin/sh
+read server
+case "$server" in
+  quay.io) echo '{"ServerURL":"quay.io","Username":"<token>","Secret":"quay-token"}' ;;
+  https://index.docker.io/v1/) echo '{"ServerURL":"https://index.docker.io/v1/","Username":"store","Secret":"store-pass"}' ;;
+  *) echo "credentials not found in native keychain"; exit 1 ;;
+esac
+`
+	for _, name := range []string{"docker-credential-quay", "docker-credential-desktop"} {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(helper), 0755))
+	}
+
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+
+	config := filepath.Join(dir, "config.json")
+	assert.Nil(t, ioutil.WriteFile(config, []byte(`{
+  "auths": {
+    "gcr.io": {"auth": "Z2NyOnNlY3JldA=="}
+  },
+  "credsStore": "desktop",
+  "credHelpers": {
+    "quay.io": "quay"
+  }
+}`), 0600))
+
+	dockerConfig := os.Getenv("DOCKER_CONFIG")
+	defer os.Setenv("DOCKER_CONFIG", dockerConfig)
+	os.Setenv("DOCKER_CONFIG", dir)
+
+	dc := DockerConfig{}
+	assert.Nil(t, dc.LoadAuths())
+
+	assert.Equal(t, types.AuthConfig{
+		IdentityToken: "quay-token",
+		ServerAddress: "quay.io",
+	}, dc.AuthFor("quay.io"))
+
+	assert.Equal(t, types.AuthConfig{
+		Username:      "store",
+		Password:      "store-pass",
+		ServerAddress: "https://index.docker.io/v1/",
+	}, dc.AuthFor("docker.io"))
+
+	assert.Equal(t, types.AuthConfig{
+		Username:      "gcr",
+		Password:      "secret",
+		ServerAddress: "gcr.io",
+	}, dc.AuthFor("gcr.io"))
+}
@@ -0,0 +1,311 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/justinbarrick/hone/pkg/cache"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+)
+
+func BuildContext(contextDir string, files []string) io.ReadCloser {
+	reader, writer := io.Pipe()
+
+	go func() {
+		tarWriter := tar.NewWriter(writer)
+
+		for _, file := range files {
+			if err := addToTar(tarWriter, contextDir, file); err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+
+		if err := tarWriter.Close(); err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+
+		writer.Close()
+	}()
+
+	return reader
+}
+
+func addToTar(tarWriter *tar.Writer, contextDir, file string) error {
+	rel, err := filepath.Rel(contextDir, file)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+
+	header.Name = filepath.ToSlash(rel)
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(tarWriter, f)
+	return err
+}
+
+func ContextFiles(contextDir, dockerfile string, inputs []string) ([]string, error) {
+	seen := map[string]bool{}
+	files := []string{}
+
+	add := func(path string) error {
+		rel, err := filepath.Rel(contextDir, path)
+		if err != nil {
+			return err
+		}
+
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil
+		}
+
+		if seen[rel] {
+			return nil
+		}
+
+		seen[rel] = true
+		files = append(files, path)
+		return nil
+	}
+
+	if err := cache.WalkInputs(append([]string{dockerfile}, inputs...), add); err != nil {
+		return nil, err
+	}
+
+	if !seen[mustRel(contextDir, dockerfile)] {
+		return nil, fmt.Errorf("Dockerfile %s not found in build context %s.", dockerfile, contextDir)
+	}
+
+	return files, nil
+}
+
+func mustRel(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+
+	return rel
+}
+
+type jsonMessage struct {
+	Stream string `json:"stream"`
+	Status string `json:"status"`
+	ID     string `json:"id"`
+	Error  string `json:"error"`
+	Detail struct {
+		Code int `json:"code"`
+	} `json:"errorDetail"`
+	Aux *json.RawMessage `json:"aux"`
+}
+
+// An error reported by the Docker daemon in a stream of JSON messages, such as
+// a build step failing.
+type messageError struct {
+	Code    int
+	Message string
+}
+
+func (e *messageError) Error() string {
+	return e.Message
+}
+
+// Return a failed build step as a job.ExitError so that it is reported as a
+// failure rather than an error.
+func buildError(err error) error {
+	msgErr, ok := err.(*messageError)
+	if !ok {
+		return err
+	}
+
+	code := msgErr.Code
+	if code == 0 {
+		code = 1
+	}
+
+	return &job.ExitError{Code: code, Message: msgErr.Message}
+}
+
+func readJSONMessages(j *job.Job, reader io.Reader, aux func(*json.RawMessage) error) error {
+	decoder := json.NewDecoder(reader)
+	stdout := logger.LogWriter(j)
+	statuses := map[string]string{}
+
+	for {
+		msg := jsonMessage{}
+
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if msg.Error != "" {
+			return &messageError{Code: msg.Detail.Code, Message: msg.Error}
+		}
+
+		if msg.Aux != nil && aux != nil {
+			if err := aux(msg.Aux); err != nil {
+				return err
+			}
+		}
+
+		if msg.Stream != "" {
+			stdout.Write([]byte(msg.Stream))
+		} else if msg.Status != "" && msg.ID != "" {
+			if statuses[msg.ID] == msg.Status {
+				continue
+			}
+
+			statuses[msg.ID] = msg.Status
+			logger.LogDebug(j, fmt.Sprintf("%s: %s", msg.ID, msg.Status))
+		} else if msg.Status != "" {
+			logger.LogDebug(j, msg.Status)
+		}
+	}
+}
+
+func (d *Docker) BuildImage(ctx context.Context, j *job.Job) error {
+	build := j.Build
+
+	contextDir := build.GetContext()
+	dockerfile := build.GetDockerfile()
+
+	files, err := ContextFiles(contextDir, dockerfile, j.GetInputs())
+	if err != nil {
+		return err
+	}
+
+	buildContext := BuildContext(contextDir, files)
+	defer buildContext.Close()
+
+	logger.Log(j, fmt.Sprintf("Building image from %s with %d files in context.", dockerfile, len(files)))
+
+	response, err := d.DockerConfig.docker.ImageBuild(ctx, buildContext, types.ImageBuildOptions{
+		Tags:       build.GetTags(),
+		Dockerfile: filepath.ToSlash(mustRel(contextDir, dockerfile)),
+		BuildArgs:  build.GetArgs(),
+		Target:     build.GetTarget(),
+		Remove:     true,
+	})
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	imageID := ""
+	err = readJSONMessages(j, response.Body, func(aux *json.RawMessage) error {
+		result := types.BuildResult{}
+		if err := json.Unmarshal(*aux, &result); err != nil {
+			return err
+		}
+
+		imageID = result.ID
+		return nil
+	})
+	if err != nil {
+		return buildError(err)
+	}
+
+	if j.OutputHashes == nil {
+		j.OutputHashes = map[string]string{}
+	}
+
+	logger.Log(j, fmt.Sprintf("Built image: %s", imageID))
+
+	if !build.ShouldPush() {
+		for _, tag := range build.GetTags() {
+			j.OutputHashes[tag] = imageID
+		}
+		return nil
+	}
+
+	for _, tag := range build.GetTags() {
+		digest, err := d.PushImage(ctx, j, tag)
+		if err != nil {
+			return err
+		}
+
+		j.OutputHashes[tag] = digest
+		logger.Log(j, fmt.Sprintf("Pushed %s: %s", tag, digest))
+	}
+
+	return nil
+}
+
+func (d *Docker) PushImage(ctx context.Context, j *job.Job, tag string) (string, error) {
+	registry, err := RegistryForImage(tag)
+	if err != nil {
+		return "", err
+	}
+
+	authConfig := d.DockerConfig.AuthFor(registry)
+	if j.Build.GetUsername() != "" {
+		authConfig = types.AuthConfig{
+			Username:      j.Build.GetUsername(),
+			Password:      j.Build.GetPassword(),
+			ServerAddress: registry,
+		}
+	}
+
+	auth, err := EncodeAuth(authConfig)
+	if err != nil {
+		return "", err
+	}
+
+	reader, err := d.DockerConfig.docker.ImagePush(ctx, tag, types.ImagePushOptions{
+		RegistryAuth: auth,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	digest := ""
+	err = readJSONMessages(j, reader, func(aux *json.RawMessage) error {
+		result := types.PushResult{}
+		if err := json.Unmarshal(*aux, &result); err != nil {
+			return err
+		}
+
+		digest = result.Digest
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if digest == "" {
+		return "", fmt.Errorf("Registry did not return a digest for %s.", tag)
+	}
+
+	return digest, nil
+}
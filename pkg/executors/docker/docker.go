@@ -2,11 +2,15 @@ package docker
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -18,11 +22,25 @@ import (
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/justinbarrick/hone/pkg/job"
 	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/justinbarrick/hone/pkg/utils"
+)
+
+const (
+	LabelBuildID = "hone.build-id"
+	LabelJob     = "hone.job"
+	LabelHost    = "hone.host"
+	LabelPid     = "hone.pid"
 )
 
 type DockerConfig struct {
-	docker  *docker.Client
-	network string
+	BuildID     string
+	Registries  []*Registry
+	auths       map[string]types.AuthConfig
+	credsStore  string
+	credHelpers map[string]string
+	docker      *docker.Client
+	network     string
+	networkName string
 }
 
 func (dc *DockerConfig) Init() error {
@@ -34,15 +52,56 @@ func (dc *DockerConfig) Init() error {
 	dc.docker = dockerClient
 	dc.docker.NegotiateAPIVersion(context.TODO())
 
+	if dc.BuildID == "" {
+		dc.BuildID = utils.NewBuildID()
+	}
+
+	if err := dc.LoadAuths(); err != nil {
+		logger.Printf("Failed to load Docker registry credentials: %s", err)
+	}
+
+	if err := dc.ReapStale(); err != nil {
+		logger.Printf("Failed to clean up stale Docker resources: %s", err)
+	}
+
 	return dc.CreateNetwork()
 }
 
 func (dc *DockerConfig) Cleanup() error {
+	if dc.docker == nil {
+		return nil
+	}
+
+	if err := dc.RemoveContainers(dc.BuildID); err != nil {
+		return err
+	}
+
 	return dc.DeleteNetwork()
 }
 
+func (dc *DockerConfig) Labels() map[string]string {
+	hostname, _ := os.Hostname()
+
+	return map[string]string{
+		LabelBuildID: dc.BuildID,
+		LabelHost:    hostname,
+		LabelPid:     strconv.Itoa(os.Getpid()),
+	}
+}
+
+func (dc *DockerConfig) JobLabels(j *job.Job) map[string]string {
+	labels := dc.Labels()
+	labels[LabelJob] = j.GetName()
+	return labels
+}
+
 func (dc *DockerConfig) CreateNetwork() error {
-	network, err := dc.docker.NetworkCreate(context.TODO(), "hone", types.NetworkCreate{})
+	dc.networkName = fmt.Sprintf("hone-%s", dc.BuildID)
+
+	network, err := dc.docker.NetworkCreate(context.TODO(), dc.networkName, types.NetworkCreate{
+		CheckDuplicate: true,
+		Labels:         dc.Labels(),
+	})
 	if err != nil {
 		return err
 	}
@@ -55,6 +114,110 @@ func (dc *DockerConfig) DeleteNetwork() error {
 	return dc.docker.NetworkRemove(context.TODO(), dc.network)
 }
 
+func (dc *DockerConfig) RemoveContainers(buildID string) error {
+	args := filters.NewArgs()
+	args.Add("label", fmt.Sprintf("%s=%s", LabelBuildID, buildID))
+
+	containers, err := dc.docker.ContainerList(context.TODO(), types.ContainerListOptions{
+		All:     true,
+		Filters: args,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ctr := range containers {
+		err := dc.docker.ContainerRemove(context.TODO(), ctr.ID, types.ContainerRemoveOptions{
+			Force: true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsStale returns true if the labels belong to a build started on this host by a
+// process that is no longer running.
+func IsStale(labels map[string]string) bool {
+	hostname, _ := os.Hostname()
+	if labels[LabelHost] != hostname {
+		return false
+	}
+
+	pid, err := strconv.Atoi(labels[LabelPid])
+	if err != nil {
+		return false
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return true
+	}
+
+	return processGone(process.Signal(syscall.Signal(0)))
+}
+
+// Return true if the error from signalling a process means that it is no longer
+// running. Other errors, such as EPERM for a process owned by another user, mean
+// that it is still alive.
+func processGone(err error) bool {
+	return err == os.ErrProcessDone || errors.Is(err, syscall.ESRCH)
+}
+
+func (dc *DockerConfig) ReapStale() error {
+	args := filters.NewArgs()
+	args.Add("label", LabelBuildID)
+
+	networks, err := dc.docker.NetworkList(context.TODO(), types.NetworkListOptions{
+		Filters: args,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, network := range networks {
+		if !IsStale(network.Labels) {
+			continue
+		}
+
+		buildID := network.Labels[LabelBuildID]
+		logger.Printf("Removing stale resources from build %s.", buildID)
+
+		if err := dc.RemoveContainers(buildID); err != nil {
+			return err
+		}
+
+		if err := dc.docker.NetworkRemove(context.TODO(), network.ID); err != nil {
+			return err
+		}
+	}
+
+	args = filters.NewArgs()
+	args.Add("name", "hone")
+
+	networks, err = dc.docker.NetworkList(context.TODO(), types.NetworkListOptions{
+		Filters: args,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, network := range networks {
+		if network.Name != "hone" || len(network.Containers) > 0 {
+			continue
+		}
+
+		logger.Printf("Removing legacy hone network.")
+		if err := dc.docker.NetworkRemove(context.TODO(), network.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 type Docker struct {
 	DockerConfig *DockerConfig
 	ctr          string
@@ -64,7 +227,7 @@ func (d *Docker) Init() error {
 	return nil
 }
 
-func (d *Docker) Pull(ctx context.Context, image string) error {
+func (d *Docker) ImageExists(ctx context.Context, image string) (bool, error) {
 	args := filters.NewArgs()
 	args.Add("reference", image)
 
@@ -72,25 +235,82 @@ func (d *Docker) Pull(ctx context.Context, image string) error {
 		Filters: args,
 	})
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	if len(images) < 1 {
-		reader, err := d.DockerConfig.docker.ImagePull(ctx, image, types.ImagePullOptions{})
+	return len(images) > 0, nil
+}
+
+func (d *Docker) Pull(ctx context.Context, j *job.Job) error {
+	image := j.GetImage()
+	policy := j.GetPull()
+
+	if policy != job.PullAlways {
+		exists, err := d.ImageExists(ctx, image)
 		if err != nil {
 			return err
 		}
-		_, err = io.Copy(os.Stdout, reader)
-		if err != nil {
-			return err
+
+		if exists {
+			return nil
+		} else if policy == job.PullNever {
+			return fmt.Errorf("Image %s not found locally and pull is set to never.", image)
 		}
 	}
 
+	auth, err := d.DockerConfig.EncodedAuthFor(image)
+	if err != nil {
+		return err
+	}
+
+	logger.Log(j, fmt.Sprintf("Pulling image %s.", image))
+
+	reader, err := d.DockerConfig.docker.ImagePull(ctx, image, types.ImagePullOptions{
+		RegistryAuth: auth,
+	})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return readJSONMessages(j, reader, nil)
+}
+
+func (d *Docker) ResolveImage(ctx context.Context, j *job.Job) error {
+	if err := d.Pull(ctx, j); err != nil {
+		return err
+	}
+
+	inspect, _, err := d.DockerConfig.docker.ImageInspectWithRaw(ctx, j.GetImage())
+	if err != nil {
+		return err
+	}
+
+	j.ImageDigest = inspect.ID
+	if len(inspect.RepoDigests) > 0 {
+		j.ImageDigest = inspect.RepoDigests[0]
+	}
+
+	logger.LogDebug(j, fmt.Sprintf("Resolved image %s to %s.", j.GetImage(), j.ImageDigest))
 	return nil
 }
 
 func (d *Docker) Wait(ctx context.Context, j *job.Job) error {
+	if j.Build != nil {
+		return d.BuildImage(ctx, j)
+	}
+
 	logger.Log(j, fmt.Sprintf("Started container: %s", d.ctr[:8]))
+
+	statsCtx, stopStats := context.WithCancel(ctx)
+	statsCh := d.collectStats(statsCtx)
+	defer func() {
+		stopStats()
+		stats := <-statsCh
+		j.Stats.PeakMemory = stats.PeakMemory
+		j.Stats.CPUTime = stats.CPUTime
+	}()
+
 	out, err := d.DockerConfig.docker.ContainerLogs(ctx, d.ctr, types.ContainerLogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
@@ -113,60 +333,166 @@ func (d *Docker) Wait(ctx context.Context, j *job.Job) error {
 	}
 
 	logger.Log(j, fmt.Sprintf("Container exited with status: %d", statusCode))
-	if statusCode != 128 && statusCode != 0 {
-		return errors.New(fmt.Sprintf("Container returned status code: %d", statusCode))
-	} else if !j.IsService() && statusCode == 128 {
-		return errors.New(fmt.Sprintf("Container returned status code: %d", statusCode))
+	if (statusCode != 128 && statusCode != 0) || (!j.IsService() && statusCode == 128) {
+		return &job.ExitError{
+			Code:    int(statusCode),
+			Message: fmt.Sprintf("Container returned status code: %d", statusCode),
+		}
 	}
 
 	return nil
 }
 
 func (d *Docker) Stop(ctx context.Context, j *job.Job) error {
+	if d.ctr == "" {
+		return nil
+	}
+
 	timeout := 5 * time.Second
 	d.DockerConfig.docker.ContainerStop(ctx, d.ctr, &timeout)
 	return d.DockerConfig.docker.ContainerRemove(ctx, d.ctr, types.ContainerRemoveOptions{})
 }
 
+func ParseVolume(cwd, volume string) (mount.Mount, error) {
+	parts := strings.Split(volume, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return mount.Mount{}, fmt.Errorf("Invalid volume %s, expected source:target[:ro].", volume)
+	}
+
+	source := parts[0]
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(cwd, source)
+	}
+
+	readOnly := false
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			readOnly = true
+		case "rw":
+		default:
+			return mount.Mount{}, fmt.Errorf("Invalid volume mode %s, expected ro or rw.", parts[2])
+		}
+	}
+
+	return mount.Mount{
+		Type:     mount.TypeBind,
+		Source:   source,
+		Target:   parts[1],
+		ReadOnly: readOnly,
+	}, nil
+}
+
+func CacheVolumeName(name string) string {
+	return fmt.Sprintf("hone-cache-%s", name)
+}
+
+func Mounts(cwd string, j *job.Job) ([]mount.Mount, error) {
+	mounts := []mount.Mount{
+		{
+			Type:   mount.TypeBind,
+			Source: cwd,
+			Target: "/build",
+		},
+	}
+
+	for _, volume := range j.GetVolumes() {
+		m, err := ParseVolume(cwd, volume)
+		if err != nil {
+			return nil, err
+		}
+
+		mounts = append(mounts, m)
+	}
+
+	cacheVolumes := j.GetCacheVolumes()
+
+	names := []string{}
+	for name := range cacheVolumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeVolume,
+			Source: CacheVolumeName(name),
+			Target: cacheVolumes[name],
+		})
+	}
+
+	for _, tmpfs := range j.GetTmpfs() {
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeTmpfs,
+			Target: tmpfs,
+		})
+	}
+
+	return mounts, nil
+}
+
 func (d *Docker) Start(ctx context.Context, j *job.Job) error {
-	err := d.Pull(ctx, j.GetImage())
+	if j.Build != nil {
+		return nil
+	}
+
+	if j.ImageDigest == "" {
+		if err := d.ResolveImage(ctx, j); err != nil {
+			return err
+		}
+	}
+
+	cwd, err := os.Getwd()
 	if err != nil {
 		return err
 	}
 
+	user := j.GetUser()
+	if user == "host" {
+		user = fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+	}
+
 	env := []string{}
 	for name, value := range j.GetEnv() {
 		env = append(env, fmt.Sprintf("%s=%s", name, value))
 	}
 
-	cwd, err := os.Getwd()
+	if j.GetUser() == "host" && j.GetEnv()["HOME"] == "" {
+		env = append(env, "HOME=/tmp")
+	}
+
+	mounts, err := Mounts(cwd, j)
 	if err != nil {
 		return err
 	}
 
+	networkMode := j.GetNetworkMode()
+
+	networkConfig := &network.NetworkingConfig{}
+	if networkMode == "" {
+		networkConfig.EndpointsConfig = map[string]*network.EndpointSettings{
+			d.DockerConfig.networkName: {
+				Aliases:   []string{j.GetName()},
+				NetworkID: d.DockerConfig.network,
+			},
+		}
+	}
+
 	ctr, err := d.DockerConfig.docker.ContainerCreate(ctx, &container.Config{
 		Hostname:   j.GetName(),
-		Image:      j.GetImage(),
+		Image:      j.ImageDigest,
 		Entrypoint: j.GetShell(),
 		Env:        env,
+		User:       user,
+		Labels:     d.DockerConfig.JobLabels(j),
 		WorkingDir: filepath.Join("/build", j.GetWorkdir()),
 	}, &container.HostConfig{
-		Mounts: []mount.Mount{
-			{
-				Type:   mount.TypeBind,
-				Source: cwd,
-				Target: "/build",
-			},
-		},
-		Privileged: j.IsPrivileged(),
-	}, &network.NetworkingConfig{
-		EndpointsConfig: map[string]*network.EndpointSettings{
-			"hone": {
-				Aliases:   []string{j.GetName()},
-				NetworkID: d.DockerConfig.network,
-			},
-		},
-	}, "")
+		Mounts:      mounts,
+		Privileged:  j.IsPrivileged(),
+		NetworkMode: container.NetworkMode(networkMode),
+		DNS:         j.GetDNS(),
+		ExtraHosts:  j.GetExtraHosts(),
+	}, networkConfig, "")
 	if err != nil {
 		return err
 	}
@@ -176,5 +502,49 @@ func (d *Docker) Start(ctx context.Context, j *job.Job) error {
 	}
 
 	d.ctr = ctr.ID
+	j.Stats.ContainerID = ctr.ID
 	return nil
 }
+
+// Stream a container's stats until ctx is canceled, returning its peak memory
+// usage and CPU time.
+func (d *Docker) collectStats(ctx context.Context) chan job.Stats {
+	result := make(chan job.Stats, 1)
+
+	go func() {
+		stats := job.Stats{}
+		defer func() {
+			result <- stats
+		}()
+
+		res, err := d.DockerConfig.docker.ContainerStats(ctx, d.ctr, true)
+		if err != nil {
+			return
+		}
+		defer res.Body.Close()
+
+		decoder := json.NewDecoder(res.Body)
+
+		for {
+			sample := types.StatsJSON{}
+			if err := decoder.Decode(&sample); err != nil {
+				return
+			}
+
+			memory := sample.MemoryStats.MaxUsage
+			if sample.MemoryStats.Usage > memory {
+				memory = sample.MemoryStats.Usage
+			}
+
+			if int64(memory) > stats.PeakMemory {
+				stats.PeakMemory = int64(memory)
+			}
+
+			if cpu := time.Duration(sample.CPUStats.CPUUsage.TotalUsage); cpu > stats.CPUTime {
+				stats.CPUTime = cpu
+			}
+		}
+	}()
+
+	return result
+}
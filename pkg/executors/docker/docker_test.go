@@ -0,0 +1,129 @@
+package docker
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/docker/docker/api/types/mount"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVolume(t *testing.T) {
+	m, err := ParseVolume("/src", "data:/data")
+	assert.Nil(t, err)
+	assert.Equal(t, mount.Mount{
+		Type:   mount.TypeBind,
+		Source: "/src/data",
+		Target: "/data",
+	}, m)
+
+	m, err = ParseVolume("/src", "/etc/ssl:/etc/ssl:ro")
+	assert.Nil(t, err)
+	assert.Equal(t, mount.Mount{
+		Type:     mount.TypeBind,
+		Source:   "/etc/ssl",
+		Target:   "/etc/ssl",
+		ReadOnly: true,
+	}, m)
+
+	_, err = ParseVolume("/src", "/etc/ssl")
+	assert.NotNil(t, err)
+
+	_, err = ParseVolume("/src", "/etc/ssl:/etc/ssl:lol")
+	assert.NotNil(t, err)
+}
+
+func TestMounts(t *testing.T) {
+	j := &job.Job{
+		Name:    "test",
+		Volumes: &job.StringSet{"/var/run/docker.sock:/var/run/docker.sock"},
+		CacheVolumes: &map[string]string{
+			"gocache": "/root/.cache/go-build",
+			"gomod":   "/go/pkg/mod",
+		},
+		Tmpfs: &job.StringSet{"/tmp"},
+	}
+
+	mounts, err := Mounts("/src", j)
+	assert.Nil(t, err)
+	assert.Equal(t, []mount.Mount{
+		{
+			Type:   mount.TypeBind,
+			Source: "/src",
+			Target: "/build",
+		},
+		{
+			Type:   mount.TypeBind,
+			Source: "/var/run/docker.sock",
+			Target: "/var/run/docker.sock",
+		},
+		{
+			Type:   mount.TypeVolume,
+			Source: "hone-cache-gocache",
+			Target: "/root/.cache/go-build",
+		},
+		{
+			Type:   mount.TypeVolume,
+			Source: "hone-cache-gomod",
+			Target: "/go/pkg/mod",
+		},
+		{
+			Type:   mount.TypeTmpfs,
+			Target: "/tmp",
+		},
+	}, mounts)
+}
+
+func TestIsStale(t *testing.T) {
+	hostname, _ := os.Hostname()
+
+	assert.False(t, IsStale(map[string]string{
+		LabelHost: hostname,
+		LabelPid:  strconv.Itoa(os.Getpid()),
+	}))
+
+	assert.True(t, IsStale(map[string]string{
+		LabelHost: hostname,
+		LabelPid:  "99999999",
+	}))
+
+	assert.False(t, IsStale(map[string]string{
+		LabelHost: "some-other-host",
+		LabelPid:  "99999999",
+	}))
+
+	assert.False(t, IsStale(map[string]string{}))
+}
+
+func TestProcessGone(t *testing.T) {
+	assert.True(t, processGone(os.ErrProcessDone))
+	assert.True(t, processGone(os.NewSyscallError("kill", syscall.ESRCH)))
+	assert.False(t, processGone(os.NewSyscallError("kill", syscall.EPERM)))
+	assert.False(t, processGone(syscall.EPERM))
+	assert.False(t, processGone(nil))
+}
+
+func TestBuildError(t *testing.T) {
+	logger.InitLogger(0, nil)
+
+	stream := `{"stream":"Step 1/2 : FROM alpine\n"}
+{"errorDetail":{"code":2,"message":"The command '/bin/sh -c false' returned a non-zero code: 2"},"error":"The command '/bin/sh -c false' returned a non-zero code: 2"}
+`
+
+	err := buildError(readJSONMessages(&job.Job{Name: "image"}, strings.NewReader(stream), nil))
+	assert.True(t, job.IsExitError(err))
+	assert.Equal(t, 2, err.(*job.ExitError).Code)
+	assert.Equal(t, "The command '/bin/sh -c false' returned a non-zero code: 2", err.Error())
+
+	err = buildError(readJSONMessages(&job.Job{Name: "image"}, strings.NewReader(`{"error":"oops"}`), nil))
+	assert.Equal(t, 1, err.(*job.ExitError).Code)
+
+	err = buildError(errors.New("Cannot connect to the Docker daemon."))
+	assert.False(t, job.IsExitError(err))
+}
@@ -3,11 +3,14 @@ package executors
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/justinbarrick/hone/pkg/config/types"
+	"github.com/justinbarrick/hone/pkg/events"
 	"github.com/justinbarrick/hone/pkg/executors/docker"
 	"github.com/justinbarrick/hone/pkg/executors/kubernetes"
 	"github.com/justinbarrick/hone/pkg/executors/local"
+	"github.com/justinbarrick/hone/pkg/graph"
 	"github.com/justinbarrick/hone/pkg/job"
 	"github.com/justinbarrick/hone/pkg/logger"
 )
@@ -19,12 +22,33 @@ type Engine interface {
 	Stop(context.Context, *job.Job) error
 }
 
-func ChooseEngine(config *types.Config, j *job.Job) (Engine, error) {
+func EngineName(config *types.Config, j *job.Job) string {
 	engine := j.GetEngine()
 	if engine == "" {
 		engine = config.GetEngine()
 	}
 
+	if engine != "kubernetes" && engine != "local" {
+		engine = "docker"
+	}
+
+	return engine
+}
+
+// Return true if any of the jobs run with Docker.
+func UsesDocker(config *types.Config, jobs []*job.Job) bool {
+	for _, j := range jobs {
+		if !j.Aggregate && EngineName(config, j) == "docker" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ChooseEngine(config *types.Config, j *job.Job) (Engine, error) {
+	engine := EngineName(config, j)
+
 	var orchestrator Engine
 
 	if engine == "kubernetes" {
@@ -63,7 +87,36 @@ func ChooseEngine(config *types.Config, j *job.Job) (Engine, error) {
 	return orchestrator, nil
 }
 
+// Resolve a Docker job's image to its digest before it is hashed. Jobs whose
+// condition is not met are skipped later on, so their images are not resolved.
+func PinImage(config *types.Config, callback func(*job.Job) error) func(*job.Job) error {
+	return func(j *job.Job) error {
+		if EngineName(config, j) != "docker" || j.Build != nil || j.Image == nil {
+			return callback(j)
+		}
+
+		run, err := events.YQLMatch(j.Condition, config.Conditions())
+		if err != nil || !run {
+			return callback(j)
+		}
+
+		d := &docker.Docker{
+			DockerConfig: config.DockerConfig,
+		}
+
+		if err := d.ResolveImage(context.TODO(), j); err != nil {
+			return err
+		}
+
+		return callback(j)
+	}
+}
+
 func Run(config *types.Config, j *job.Job) error {
+	if j.Aggregate {
+		return nil
+	}
+
 	ctx := context.TODO()
 	finished := make(chan error)
 
@@ -72,6 +125,9 @@ func Run(config *types.Config, j *job.Job) error {
 		return err
 	}
 
+	j.Stats.Engine = EngineName(config, j)
+	j.StartTime = time.Now().UTC()
+
 	err = engine.Start(ctx, j)
 	defer engine.Stop(ctx, j)
 	if err != nil {
@@ -88,8 +144,14 @@ func Run(config *types.Config, j *job.Job) error {
 
 	select {
 	case err := <-finished:
+		j.Stats.SetExitCode(err)
 		return err
 	case <-j.Stop:
+		// Services are stopped once the build is done, anything else was
+		// canceled.
+		if !j.IsService() {
+			return graph.ErrCanceled
+		}
 	}
 
 	return nil
@@ -0,0 +1,94 @@
+package executors
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/ci"
+	"github.com/justinbarrick/hone/pkg/config/types"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsesDocker(t *testing.T) {
+	local := "local"
+	docker := "docker"
+
+	config := &types.Config{Engine: &local}
+	assert.False(t, UsesDocker(config, []*job.Job{{Name: "build"}, {Name: "all", Aggregate: true}}))
+	assert.True(t, UsesDocker(config, []*job.Job{{Name: "build"}, {Name: "image", Engine: &docker}}))
+
+	assert.True(t, UsesDocker(&types.Config{}, []*job.Job{{Name: "build"}}))
+	assert.False(t, UsesDocker(&types.Config{}, []*job.Job{}))
+}
+
+func TestPinImageSkippedJob(t *testing.T) {
+	config := &types.Config{
+		Env: map[string]string{"GIT_BRANCH": "master"},
+		CI:  &ci.Build{},
+	}
+
+	image := "alpine"
+	condition := "GIT_BRANCH='release'"
+	j := &job.Job{
+		Name:      "deploy",
+		Image:     &image,
+		Condition: &condition,
+	}
+
+	called := false
+	err := PinImage(config, func(j *job.Job) error {
+		called = true
+		return nil
+	})(j)
+
+	assert.Nil(t, err)
+	assert.True(t, called)
+	assert.Equal(t, "", j.ImageDigest)
+}
+
+func TestStrictConcurrentJobs(t *testing.T) {
+	logger.InitLogger(0, nil)
+
+	dir, err := ioutil.TempDir("", "hone-test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(dir))
+	defer os.Chdir(cwd)
+
+	strict := job.StrictError
+	build := &job.Job{Name: "build", Strict: &strict, Outputs: &job.StringSet{"bin"}}
+	generate := &job.Job{Name: "generate"}
+
+	config := &types.Config{
+		Jobs: []*job.Job{build, generate},
+	}
+
+	started := make(chan bool)
+
+	callback := Strict(config, func(j *job.Job) error {
+		if j == generate {
+			started <- true
+			time.Sleep(100 * time.Millisecond)
+			return ioutil.WriteFile("generated.go", []byte("package main"), 0644)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+		return ioutil.WriteFile("bin", []byte("binary"), 0644)
+	})
+
+	generated := make(chan error)
+	go func() {
+		generated <- callback(generate)
+	}()
+	<-started
+
+	assert.Nil(t, callback(build))
+	assert.Nil(t, <-generated)
+}
@@ -125,7 +125,10 @@ func (k *Kubernetes) Wait(ctx context.Context, j *job.Job) error {
 
 	exitStatus := pod.Status.ContainerStatuses[0].State.Terminated.ExitCode
 	if exitStatus != 0 {
-		return errors.New(fmt.Sprintf("Pod exited with error: %d", exitStatus))
+		return &job.ExitError{
+			Code:    int(exitStatus),
+			Message: fmt.Sprintf("Pod exited with error: %d", exitStatus),
+		}
 	}
 
 	logger.Log(j, fmt.Sprintf("Pod exit status %d, phase %s", exitStatus, pod.Status.Phase))
@@ -308,6 +311,7 @@ func (k *Kubernetes) Start(ctx context.Context, j *job.Job) error {
 	}
 
 	k.pod = pod.Name
+	j.Stats.ContainerID = pod.Name
 
 	if _, err := k.watch(j); err != nil {
 		return err
@@ -7,8 +7,13 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
 
+	"github.com/justinbarrick/hone/pkg/cache"
+	"github.com/justinbarrick/hone/pkg/hermetic"
 	"github.com/justinbarrick/hone/pkg/job"
 	"github.com/justinbarrick/hone/pkg/logger"
 )
@@ -18,36 +23,234 @@ func ParseEnv(env []string) map[string]string {
 
 	for _, envVar := range env {
 		envSplit := strings.SplitN(envVar, "=", 2)
+		if len(envSplit) != 2 {
+			continue
+		}
 		envMap[envSplit[0]] = envSplit[1]
 	}
 
 	return envMap
 }
 
+// Return the variables from env whose names match one of the allowed patterns.
+func InheritEnv(env map[string]string, allowed []string) map[string]string {
+	inherited := map[string]string{}
+
+	for key, value := range env {
+		for _, pattern := range allowed {
+			if matched, _ := filepath.Match(pattern, key); matched {
+				inherited[key] = value
+				break
+			}
+		}
+	}
+
+	return inherited
+}
+
+func JobEnv(j *job.Job) map[string]string {
+	env := InheritEnv(ParseEnv(os.Environ()), j.GetInheritEnv())
+
+	for key, value := range j.GetEnv() {
+		env[key] = value
+	}
+
+	return env
+}
+
+func isLocalPath(path string) bool {
+	path = filepath.Clean(path)
+	return !filepath.IsAbs(path) && path != ".." && !strings.HasPrefix(path, "../")
+}
+
+func copyFile(src, dst string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
 type Local struct {
-	stdout io.Reader
-	stderr io.Reader
-	cmd    *exec.Cmd
+	Dir     string
+	sandbox string
+	trace   string
+	stdout  io.Reader
+	stderr  io.Reader
+	cmd     *exec.Cmd
 }
 
 func (l *Local) Init() error {
 	return nil
 }
 
+// Copy the job's declared inputs into a temporary directory to run the job in.
+func (l *Local) CreateSandbox(j *job.Job) error {
+	sandbox, err := ioutil.TempDir("", "hone-")
+	if err != nil {
+		return err
+	}
+
+	l.sandbox = sandbox
+
+	err = cache.WalkInputs(j.GetInputs(), func(path string) error {
+		if !isLocalPath(path) {
+			logger.LogDebug(j, fmt.Sprintf("Not copying %s into sandbox: outside of the build directory.", path))
+			return nil
+		}
+
+		return copyFile(path, filepath.Join(sandbox, path))
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.MkdirAll(filepath.Join(sandbox, j.GetWorkdir()), 0755)
+}
+
+// Copy the job's declared outputs from the sandbox back into the build directory.
+func (l *Local) CollectOutputs(j *job.Job) error {
+	return cache.WalkInputsIn(l.sandbox, j.GetOutputs(), func(path string) error {
+		if !isLocalPath(path) {
+			return nil
+		}
+
+		return copyFile(filepath.Join(l.sandbox, path), path)
+	})
+}
+
 func (l *Local) Start(ctx context.Context, j *job.Job) error {
-	return l.Exec(j.GetShell(), j.GetEnv(), j)
+	root := ""
+
+	if j.IsSandboxed() {
+		if err := l.CreateSandbox(j); err != nil {
+			return err
+		}
+
+		logger.Log(j, fmt.Sprintf("Running in sandbox %s.", l.sandbox))
+		root = l.sandbox
+	}
+
+	l.Dir = filepath.Join(root, j.GetWorkdir())
+
+	command := j.GetShell()
+
+	if j.ShouldTraceReads() {
+		if hermetic.CanTrace() {
+			trace, err := ioutil.TempFile("", "hone-trace-")
+			if err != nil {
+				return err
+			}
+			trace.Close()
+
+			l.trace = trace.Name()
+			command = hermetic.TraceCommand(command, l.trace)
+		} else {
+			logger.Log(j, "Not tracing reads: strace is not available on this host.")
+		}
+	}
+
+	return l.Exec(command, JobEnv(j), j)
+}
+
+// Report any files the job read that it did not declare as inputs.
+func (l *Local) CheckReads(j *job.Job) error {
+	trace, err := os.Open(l.trace)
+	if err != nil {
+		return err
+	}
+	defer trace.Close()
+
+	root := l.sandbox
+	if root == "" {
+		root = "."
+	}
+
+	dir := l.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	reads, _, err := hermetic.ParseTrace(trace, root, dir)
+	if err != nil {
+		return err
+	}
+
+	declared := append(j.GetInputs(), j.GetOutputs()...)
+	return hermetic.Report(j, "read", hermetic.Undeclared(reads, declared))
 }
 
 func (l *Local) Wait(ctx context.Context, j *job.Job) error {
-	return l.WaitCmd()
+	err := l.WaitCmd()
+	l.recordStats(j)
+	if err != nil {
+		return err
+	}
+
+	if l.trace != "" {
+		if err := l.CheckReads(j); err != nil {
+			return err
+		}
+	}
+
+	if l.sandbox != "" {
+		return l.CollectOutputs(j)
+	}
+
+	return nil
+}
+
+// Record the peak memory and CPU time of the job's command.
+func (l *Local) recordStats(j *job.Job) {
+	state := l.cmd.ProcessState
+	if state == nil {
+		return
+	}
+
+	j.Stats.CPUTime = state.UserTime() + state.SystemTime()
+
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		// Linux reports the maximum resident set size in kilobytes, macOS in bytes.
+		j.Stats.PeakMemory = int64(usage.Maxrss)
+		if runtime.GOOS != "darwin" {
+			j.Stats.PeakMemory *= 1024
+		}
+	}
 }
 
 func (l *Local) Stop(ctx context.Context, j *job.Job) error {
-	return nil
+	if l.trace != "" {
+		os.Remove(l.trace)
+	}
+
+	if l.sandbox == "" {
+		return nil
+	}
+
+	return os.RemoveAll(l.sandbox)
 }
 
 func (l *Local) Exec(command []string, env map[string]string, j *job.Job) error {
 	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = l.Dir
 
 	envList := []string{}
 	for k, v := range env {
@@ -87,6 +290,12 @@ func (l *Local) WaitCmd() error {
 	}
 
 	if err := l.cmd.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return &job.ExitError{
+				Code:    exitErr.ExitCode(),
+				Message: err.Error(),
+			}
+		}
 		return err
 	}
 
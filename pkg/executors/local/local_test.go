@@ -0,0 +1,109 @@
+package local
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	logger.InitLogger(0, nil)
+}
+
+func TestInheritEnv(t *testing.T) {
+	env := map[string]string{
+		"PATH":   "/bin",
+		"HOME":   "/root",
+		"GOPATH": "/go",
+		"GOOS":   "linux",
+		"SECRET": "hunter2",
+	}
+
+	assert.Equal(t, map[string]string{
+		"PATH":   "/bin",
+		"GOPATH": "/go",
+		"GOOS":   "linux",
+	}, InheritEnv(env, []string{"PATH", "GO*"}))
+
+	assert.Equal(t, map[string]string{}, InheritEnv(env, []string{}))
+}
+
+func TestSandbox(t *testing.T) {
+	cwd, err := os.Getwd()
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "hone-test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, os.Chdir(dir))
+	defer os.Chdir(cwd)
+
+	assert.Nil(t, os.MkdirAll("src", 0755))
+	assert.Nil(t, ioutil.WriteFile("src/input", []byte("hello"), 0644))
+	assert.Nil(t, ioutil.WriteFile("undeclared", []byte("secret"), 0644))
+
+	shell := "cat input > output; cat ../undeclared > leaked || true; touch stray"
+	workdir := "src"
+	sandbox := true
+
+	j := &job.Job{
+		Name:    "test",
+		Shell:   &shell,
+		Workdir: &workdir,
+		Sandbox: &sandbox,
+		Inputs:  &job.StringSet{"src/input"},
+		Outputs: &job.StringSet{"src/output", "src/leaked"},
+	}
+
+	l := &Local{}
+	assert.Nil(t, l.Start(context.TODO(), j))
+	assert.Nil(t, l.Wait(context.TODO(), j))
+	assert.Nil(t, l.Stop(context.TODO(), j))
+
+	output, err := ioutil.ReadFile("src/output")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(output))
+
+	leaked, err := ioutil.ReadFile("src/leaked")
+	assert.Nil(t, err)
+	assert.Equal(t, "", string(leaked))
+
+	_, err = os.Stat("src/stray")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(l.sandbox)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWorkdir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hone-test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	shell := "pwd > pwd"
+	workdir := dir
+
+	j := &job.Job{
+		Name:    "test",
+		Shell:   &shell,
+		Workdir: &workdir,
+	}
+
+	l := &Local{}
+	assert.Nil(t, l.Start(context.TODO(), j))
+	assert.Nil(t, l.Wait(context.TODO(), j))
+
+	pwd, err := ioutil.ReadFile(filepath.Join(dir, "pwd"))
+	assert.Nil(t, err)
+
+	resolved, err := filepath.EvalSymlinks(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, resolved+"\n", string(pwd))
+}
@@ -0,0 +1,79 @@
+package executors
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/justinbarrick/hone/pkg/config/types"
+	"github.com/justinbarrick/hone/pkg/hermetic"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+)
+
+// Paths that may change while a job runs without the job writing them: the
+// file cache and the declared outputs of every other job, since services keep
+// running while strict jobs run.
+func strictExcludes(config *types.Config, j *job.Job) []string {
+	excludes := []string{}
+
+	if config.Cache.File != nil && config.Cache.File.CacheDir != "" {
+		excludes = append(excludes, config.Cache.File.CacheDir)
+	} else {
+		excludes = append(excludes, ".hone_cache")
+	}
+
+	for _, other := range config.Jobs {
+		if other.GetName() != j.GetName() {
+			excludes = append(excludes, other.GetOutputs()...)
+		}
+	}
+
+	return excludes
+}
+
+// Snapshot the workspace before and after running a job in strict mode and
+// report any files it wrote that are not declared as outputs. Strict jobs run
+// alone so that files written by concurrent jobs are not reported, other jobs
+// (except services, which run until the build ends) hold a shared lock.
+func Strict(config *types.Config, callback func(*job.Job) error) func(*job.Job) error {
+	hasher := hermetic.NewHasher()
+	workspace := &sync.RWMutex{}
+
+	return func(j *job.Job) error {
+		engine := EngineName(config, j)
+
+		if !j.IsStrict() || j.IsService() || engine == "kubernetes" {
+			if !j.IsService() {
+				workspace.RLock()
+				defer workspace.RUnlock()
+			}
+
+			return callback(j)
+		}
+
+		workspace.Lock()
+		defer workspace.Unlock()
+
+		if j.ShouldTraceReads() && engine != "local" {
+			logger.Log(j, "Not tracing reads: only supported by the local engine.")
+		}
+
+		excludes := strictExcludes(config, j)
+
+		before, err := hasher.Take(".", excludes)
+		if err != nil {
+			return fmt.Errorf("Could not snapshot workspace: %s", err)
+		}
+
+		if err := callback(j); err != nil {
+			return err
+		}
+
+		after, err := hasher.Take(".", excludes)
+		if err != nil {
+			return fmt.Errorf("Could not snapshot workspace: %s", err)
+		}
+
+		return hermetic.Report(j, "output", hermetic.Undeclared(hermetic.Diff(before, after), j.GetOutputs()))
+	}
+}
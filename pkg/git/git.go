@@ -7,10 +7,14 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/justinbarrick/hone/pkg/ci"
 	git "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 func CleanRepoUrl(repoUrl string) string {
@@ -28,6 +32,7 @@ func CleanRepoUrl(repoUrl string) string {
 
 type Repository struct {
 	Repo *git.Repository
+	CI   *ci.Build
 }
 
 func NewRepository() (Repository, error) {
@@ -57,34 +62,134 @@ func NewRepository() (Repository, error) {
 
 	return Repository{
 		Repo: r,
+		CI:   ci.Detect(ci.Environ()),
 	}, nil
 }
 
+// Return the tag on the current commit. If there are several, the highest version
+// is returned.
 func (r Repository) Tag() (string, error) {
+	tags, err := r.Tags()
+	if err != nil || len(tags) == 0 {
+		return "", err
+	}
+
+	return tags[len(tags)-1], nil
+}
+
+// Return every tag on the current commit, including annotated tags, sorted by
+// version. A tag reported by the CI system for the current commit is included
+// even if it was not fetched.
+func (r Repository) Tags() ([]string, error) {
 	commit, err := r.Commit()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	tags, err := r.Repo.Tags()
+	tagged, err := r.taggedCommits()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	tag := ""
-	err = tags.ForEach(func(ref *plumbing.Reference) error {
-		if ref.Hash().String() != commit {
-			return nil
+	return r.tagsOf(commit, tagged), nil
+}
+
+// Return the tags on a commit from the result of taggedCommits.
+func (r Repository) tagsOf(commit string, tagged map[string][]string) []string {
+	tags := append([]string{}, tagged[commit]...)
+
+	if r.CI != nil && r.CI.Tag != "" && (r.CI.Commit == "" || r.CI.Commit == commit) {
+		found := false
+		for _, tag := range tags {
+			found = found || tag == r.CI.Tag
+		}
+
+		if !found {
+			tags = append(tags, r.CI.Tag)
+			sort.Slice(tags, func(i, j int) bool {
+				return versionLess(tags[i], tags[j])
+			})
+		}
+	}
+
+	return tags
+}
+
+// Map each tagged commit to its tags, sorted by version. Annotated tags are
+// resolved to the commit they point to.
+func (r Repository) taggedCommits() (map[string][]string, error) {
+	refs, err := r.Repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := map[string][]string{}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+
+		if tag, err := r.Repo.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				return nil
+			}
+
+			hash = commit.Hash
 		}
 
-		tag = ref.Name().Short()
+		tagged[hash.String()] = append(tagged[hash.String()], ref.Name().Short())
 		return nil
 	})
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	for _, tags := range tagged {
+		sort.Slice(tags, func(i, j int) bool {
+			return versionLess(tags[i], tags[j])
+		})
+	}
+
+	return tagged, nil
+}
+
+// Splits a tag into runs of digits and non-digits.
+var versionChunks = regexp.MustCompile("[0-9]+|[^0-9]+")
+
+// Compare two tags, treating runs of digits as numbers so that v1.10.0 sorts
+// after v1.9.0.
+func versionLess(a, b string) bool {
+	aChunks := versionChunks.FindAllString(a, -1)
+	bChunks := versionChunks.FindAllString(b, -1)
+
+	for i := 0; i < len(aChunks) && i < len(bChunks); i++ {
+		if aChunks[i] == bChunks[i] {
+			continue
+		}
+
+		aNum, aErr := strconv.Atoi(aChunks[i])
+		bNum, bErr := strconv.Atoi(bChunks[i])
+		if aErr == nil && bErr == nil && aNum != bNum {
+			return aNum < bNum
+		}
+
+		return aChunks[i] < bChunks[i]
 	}
 
-	return tag, nil
+	return len(aChunks) < len(bChunks)
+}
+
+func (r Repository) head() (*object.Commit, error) {
+	if r.Repo == nil {
+		return nil, errors.New("Repository not set.")
+	}
+
+	head, err := r.Repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Repo.CommitObject(head.Hash())
 }
 
 func (r Repository) Commit() (string, error) {
@@ -105,31 +210,236 @@ func (r Repository) Commit() (string, error) {
 	return commit.Hash.String(), nil
 }
 
+// Return the branch that is checked out. On a detached HEAD, the branch is taken
+// from the CI system or from a local or remote branch pointing at the current
+// commit.
 func (r Repository) Branch() (string, error) {
 	commit, err := r.Commit()
 	if err != nil {
 		return "", err
 	}
 
-	branches, err := r.Repo.Branches()
+	head, err := r.Repo.Head()
+	if err != nil {
+		return "", err
+	}
+
+	if head.Name().IsBranch() {
+		return head.Name().Short(), nil
+	}
+
+	if r.CI != nil && r.CI.Branch != "" {
+		return r.CI.Branch, nil
+	}
+
+	refs, err := r.Repo.References()
 	if err != nil {
 		return "", err
 	}
 
-	branch := ""
-	err = branches.ForEach(func(ref *plumbing.Reference) error {
-		if ref.Hash().String() != commit {
+	local := []string{}
+	remote := []string{}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || ref.Hash().String() != commit {
 			return nil
 		}
 
-		branch = ref.Name().Short()
+		if ref.Name().IsBranch() {
+			local = append(local, ref.Name().Short())
+		} else if ref.Name().IsRemote() {
+			parts := strings.SplitN(ref.Name().Short(), "/", 2)
+			if len(parts) == 2 && parts[1] != "HEAD" {
+				remote = append(remote, parts[1])
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
 		return "", err
 	}
 
-	return branch, nil
+	sort.Strings(local)
+	sort.Strings(remote)
+
+	if branches := append(local, remote...); len(branches) > 0 {
+		return branches[0], nil
+	}
+
+	return "", nil
+}
+
+// Describe the current commit relative to the nearest tag in its history, like
+// git describe --tags: the tag, the number of commits since it and the short
+// commit. If no tag is reachable, the short commit is returned.
+func (r Repository) Describe() (string, error) {
+	head, err := r.head()
+	if err != nil {
+		return "", err
+	}
+
+	tagged, err := r.taggedCommits()
+	if err != nil {
+		return "", err
+	}
+
+	return r.describe(head, tagged)
+}
+
+// Describe a commit with the result of taggedCommits.
+func (r Repository) describe(head *object.Commit, tagged map[string][]string) (string, error) {
+	short := head.Hash.String()[:8]
+
+	seen := map[plumbing.Hash]bool{head.Hash: true}
+	queue := []*object.Commit{head}
+
+	for distance := 0; len(queue) > 0; distance++ {
+		next := []*object.Commit{}
+
+		for _, commit := range queue {
+			if tags := tagged[commit.Hash.String()]; tags != nil {
+				tag := tags[len(tags)-1]
+				if distance == 0 {
+					return tag, nil
+				}
+
+				return fmt.Sprintf("%s-%d-g%s", tag, distance, short), nil
+			}
+
+			err := commit.Parents().ForEach(func(parent *object.Commit) error {
+				if !seen[parent.Hash] {
+					seen[parent.Hash] = true
+					next = append(next, parent)
+				}
+				return nil
+			})
+			if err != nil {
+				return "", err
+			}
+		}
+
+		queue = next
+	}
+
+	return short, nil
+}
+
+// Return the author of the current commit as "name <email>".
+func (r Repository) Author() (string, error) {
+	head, err := r.head()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s <%s>", head.Author.Name, head.Author.Email), nil
+}
+
+// Return the message of the current commit.
+func (r Repository) Message() (string, error) {
+	head, err := r.head()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(head.Message), nil
+}
+
+// Return the best common ancestor of two revisions. Both histories are walked
+// breadth first, a generation at a time, stopping at the first commit that has
+// been reached from both sides.
+func (r Repository) MergeBase(base, head string) (string, error) {
+	if r.Repo == nil {
+		return "", errors.New("Repository not set.")
+	}
+
+	baseCommit, err := r.commit(base)
+	if err != nil {
+		return "", err
+	}
+
+	headCommit, err := r.commit(head)
+	if err != nil {
+		return "", err
+	}
+
+	if baseCommit.Hash == headCommit.Hash {
+		return headCommit.Hash.String(), nil
+	}
+
+	headSeen := map[plumbing.Hash]bool{headCommit.Hash: true}
+	baseSeen := map[plumbing.Hash]bool{baseCommit.Hash: true}
+	headQueue := []*object.Commit{headCommit}
+	baseQueue := []*object.Commit{baseCommit}
+
+	// Expand one generation of a side, returning a commit the other side has
+	// already reached.
+	step := func(queue []*object.Commit, seen, other map[plumbing.Hash]bool) ([]*object.Commit, *object.Commit, error) {
+		next := []*object.Commit{}
+
+		for _, current := range queue {
+			var found *object.Commit
+
+			err := current.Parents().ForEach(func(parent *object.Commit) error {
+				if seen[parent.Hash] {
+					return nil
+				}
+				seen[parent.Hash] = true
+
+				if other[parent.Hash] && found == nil {
+					found = parent
+				}
+
+				next = append(next, parent)
+				return nil
+			})
+			if err != nil || found != nil {
+				return nil, found, err
+			}
+		}
+
+		return next, nil, nil
+	}
+
+	for len(headQueue) > 0 || len(baseQueue) > 0 {
+		var found *object.Commit
+
+		headQueue, found, err = step(headQueue, headSeen, baseSeen)
+		if err != nil {
+			return "", err
+		} else if found != nil {
+			return found.Hash.String(), nil
+		}
+
+		baseQueue, found, err = step(baseQueue, baseSeen, headSeen)
+		if err != nil {
+			return "", err
+		} else if found != nil {
+			return found.Hash.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("No common ancestor between %s and %s.", base, head)
+}
+
+// Return the revision that changes are merged into: the pull request's target
+// branch if the CI system reports one, otherwise the remote's default branch.
+func (r Repository) BaseRef() string {
+	candidates := []string{}
+
+	if r.CI != nil && r.CI.BaseBranch != "" {
+		candidates = append(candidates, fmt.Sprintf("origin/%s", r.CI.BaseBranch), r.CI.BaseBranch)
+	}
+
+	candidates = append(candidates, "origin/HEAD", "origin/master", "master")
+
+	for _, candidate := range candidates {
+		if _, err := r.Repo.ResolveRevision(plumbing.Revision(candidate)); err == nil {
+			return candidate
+		}
+	}
+
+	return ""
 }
 
 func (r Repository) RepoUrl(remoteName string) (string, error) {
@@ -184,24 +494,132 @@ func (r Repository) RepoPath(remote string) (string, error) {
 	return strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".git"), nil
 }
 
-func (r Repository) GitEnv() map[string]string {
-	tag, _ := r.Tag()
-	commit, _ := r.Commit()
-	branch, _ := r.Branch()
+// Information about the current commit, exposed to the configuration as git and
+// to conditions and jobs as GIT_* variables.
+type Metadata struct {
+	Tag         string   `cty:"tag"`
+	Tags        []string `cty:"tags"`
+	Branch      string   `cty:"branch"`
+	Commit      string   `cty:"commit"`
+	CommitShort string   `cty:"commit_short"`
+	Describe    string   `cty:"describe"`
+	Dirty       bool     `cty:"dirty"`
+	Author      string   `cty:"author"`
+	Message     string   `cty:"message"`
+	RemoteURL   string   `cty:"remote_url"`
+	MergeBase   string   `cty:"merge_base"`
+
+	// The expensive fields that were collected.
+	Collected MetadataFields
+}
+
+// The metadata fields that are expensive to collect on large repositories, which
+// are only collected when they are used.
+type MetadataFields struct {
+	Dirty     bool
+	Describe  bool
+	MergeBase bool
+}
+
+// Collect every metadata field.
+var AllMetadata = MetadataFields{
+	Dirty:     true,
+	Describe:  true,
+	MergeBase: true,
+}
+
+// Collect metadata about the current commit, fields that cannot be determined
+// or were not requested in fields are left empty.
+func (r Repository) Metadata(fields MetadataFields) Metadata {
+	m := Metadata{
+		Tags:      []string{},
+		Collected: fields,
+	}
+
+	head, err := r.head()
+	if err != nil {
+		return m
+	}
+
+	m.Commit = head.Hash.String()
+	m.CommitShort = m.Commit[:8]
+	m.Branch, _ = r.Branch()
+	m.Author, _ = r.Author()
+	m.Message, _ = r.Message()
+	m.RemoteURL, _ = r.RepoUrl("origin")
+
+	if tagged, err := r.taggedCommits(); err == nil {
+		m.Tags = r.tagsOf(m.Commit, tagged)
+		if len(m.Tags) > 0 {
+			m.Tag = m.Tags[len(m.Tags)-1]
+		}
 
-	shortCommit := ""
-	if len(commit) > 8 {
-		shortCommit = commit[:8]
+		if fields.Describe {
+			m.Describe, _ = r.describe(head, tagged)
+		}
+	}
+
+	if fields.Dirty {
+		m.Dirty, _ = r.IsDirty()
+	}
+
+	if base := r.BaseRef(); fields.MergeBase && base != "" {
+		m.MergeBase, _ = r.MergeBase(base, "HEAD")
+	}
+
+	return m
+}
+
+// Return the metadata as environment variables, GIT_TAGS is comma separated and
+// GIT_DIRTY is empty if it was not collected.
+func (m Metadata) Env() map[string]string {
+	dirty := ""
+	if m.Collected.Dirty {
+		dirty = strconv.FormatBool(m.Dirty)
 	}
 
 	return map[string]string{
-		"GIT_TAG":          tag,
-		"GIT_COMMIT":       commit,
-		"GIT_COMMIT_SHORT": shortCommit,
-		"GIT_BRANCH":       branch,
+		"GIT_TAG":          m.Tag,
+		"GIT_TAGS":         strings.Join(m.Tags, ","),
+		"GIT_COMMIT":       m.Commit,
+		"GIT_COMMIT_SHORT": m.CommitShort,
+		"GIT_BRANCH":       m.Branch,
+		"GIT_DESCRIBE":     m.Describe,
+		"GIT_DIRTY":        dirty,
+		"GIT_AUTHOR":       m.Author,
+		"GIT_MESSAGE":      m.Message,
+		"GIT_REMOTE_URL":   m.RemoteURL,
+		"GIT_MERGE_BASE":   m.MergeBase,
 	}
 }
 
+// Return the metadata as condition variables, GIT_TAGS is a list and GIT_DIRTY
+// is a boolean. Fields that were not collected are left out.
+func (m Metadata) Conditions() map[string]interface{} {
+	conditions := map[string]interface{}{}
+	for key, value := range m.Env() {
+		conditions[key] = value
+	}
+
+	conditions["GIT_TAGS"] = m.Tags
+	conditions["GIT_DIRTY"] = m.Dirty
+
+	if !m.Collected.Dirty {
+		delete(conditions, "GIT_DIRTY")
+	}
+	if !m.Collected.Describe {
+		delete(conditions, "GIT_DESCRIBE")
+	}
+	if !m.Collected.MergeBase {
+		delete(conditions, "GIT_MERGE_BASE")
+	}
+	return conditions
+}
+
+func (r Repository) GitEnv() map[string]string {
+	return r.Metadata(AllMetadata).Env()
+}
+
 func (r Repository) IsDirty() (bool, error) {
 	worktree, err := r.Repo.Worktree()
 	if err != nil {
@@ -213,5 +631,82 @@ func (r Repository) IsDirty() (bool, error) {
 		return false, errors.New(fmt.Sprintf("parsing worktree status: %s", err))
 	}
 
-	return status.IsClean(), nil
+	return !status.IsClean(), nil
+}
+
+// Return the root directory of the repository's worktree.
+func (r Repository) Root() (string, error) {
+	if r.Repo == nil {
+		return "", errors.New("Repository not set.")
+	}
+
+	worktree, err := r.Repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	return worktree.Filesystem.Root(), nil
+}
+
+func (r Repository) commit(revision string) (*object.Commit, error) {
+	hash, err := r.Repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("Could not resolve %s: %s", revision, err)
+	}
+
+	return r.Repo.CommitObject(*hash)
+}
+
+func (r Repository) tree(revision string) (*object.Tree, error) {
+	commit, err := r.commit(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return commit.Tree()
+}
+
+// Return the files changed on head since it diverged from base, relative to the
+// root of the repository. Renamed files are listed under both names.
+func (r Repository) ChangedFiles(base, head string) ([]string, error) {
+	if r.Repo == nil {
+		return nil, errors.New("Repository not set.")
+	}
+
+	base, err := r.MergeBase(base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	baseTree, err := r.tree(base)
+	if err != nil {
+		return nil, err
+	}
+
+	headTree, err := r.tree(head)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(baseTree, headTree)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	files := []string{}
+
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name == "" || seen[name] {
+				continue
+			}
+
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
 }
@@ -3,6 +3,7 @@ package git
 import (
 	"testing"
 
+	"github.com/justinbarrick/hone/pkg/ci"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/src-d/go-billy.v4/memfs"
 	git "gopkg.in/src-d/go-git.v4"
@@ -353,3 +354,284 @@ func TestGitRepoUrlRemoteFound(t *testing.T) {
 
 	}
 }
+
+func TestGitChangedFiles(t *testing.T) {
+	fs := memfs.New()
+
+	r, err := git.Init(memory.NewStorage(), fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile := func(name, contents string) {
+		f, err := fs.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(contents))
+		f.Close()
+		tree.Add(name)
+	}
+
+	writeFile("README.md", "hello")
+	writeFile("cmd/main.go", "package main")
+	writeFile("pkg/lib.go", "package lib")
+	base := doCommit(t, r)
+
+	writeFile("pkg/lib.go", "package lib\n")
+	writeFile("pkg/new.go", "package lib")
+	tree.Remove("README.md")
+	doCommit(t, r)
+
+	repo := Repository{
+		Repo: r,
+	}
+
+	files, err := repo.ChangedFiles(base.String(), "HEAD")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"README.md", "pkg/lib.go", "pkg/new.go"}, files)
+
+	files, err = repo.ChangedFiles("HEAD", "HEAD")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, files)
+
+	_, err = repo.ChangedFiles("missing", "HEAD")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, tree.Checkout(&git.CheckoutOptions{
+		Create: true,
+		Branch: plumbing.ReferenceName("refs/heads/feature"),
+	}))
+	writeFile("pkg/feature.go", "package lib")
+	doCommit(t, r)
+
+	assert.Nil(t, tree.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.ReferenceName("refs/heads/master"),
+	}))
+	writeFile("cmd/main.go", "package main\n")
+	doCommit(t, r)
+
+	// Changes made to the base since the branch diverged are not included.
+	files, err = repo.ChangedFiles("master", "feature")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"pkg/feature.go"}, files)
+}
+
+func TestGitTagsAnnotated(t *testing.T) {
+	r := taggedCommit(t)
+
+	head, err := r.Head()
+	assert.Nil(t, err)
+
+	for _, tag := range []string{"v1.10.0", "v1.9.0"} {
+		_, err = r.CreateTag(tag, head.Hash(), &git.CreateTagOptions{
+			Tagger: &object.Signature{
+				Name:  "test",
+				Email: "test@test.com",
+			},
+			Message: tag,
+		})
+		assert.Nil(t, err)
+	}
+
+	repo := Repository{
+		Repo: r,
+	}
+
+	tags, err := repo.Tags()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"my-tag", "v1.9.0", "v1.10.0"}, tags)
+
+	tag, err := repo.Tag()
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.10.0", tag)
+}
+
+func TestGitDescribe(t *testing.T) {
+	r := oneCommit(t)
+
+	repo := Repository{
+		Repo: r,
+	}
+
+	describe, err := repo.Describe()
+	assert.Nil(t, err)
+	assert.Equal(t, "1fb24341", describe)
+
+	_, err = r.CreateTag("v1.0.0", doCommit(t, r), nil)
+	assert.Nil(t, err)
+
+	describe, err = repo.Describe()
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.0.0", describe)
+
+	doCommit(t, r)
+	head := doCommit(t, r)
+
+	describe, err = repo.Describe()
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.0.0-2-g"+head.String()[:8], describe)
+}
+
+func TestGitBranchDetached(t *testing.T) {
+	r := noBranch(t)
+
+	head, err := r.Head()
+	assert.Nil(t, err)
+
+	err = r.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/feature", head.Hash()))
+	assert.Nil(t, err)
+
+	repo := Repository{
+		Repo: r,
+	}
+
+	branch, err := repo.Branch()
+	assert.Nil(t, err)
+	assert.Equal(t, "feature", branch)
+
+	repo.CI = &ci.Build{
+		Branch: "from-ci",
+	}
+
+	branch, err = repo.Branch()
+	assert.Nil(t, err)
+	assert.Equal(t, "from-ci", branch)
+}
+
+func TestGitTagsFromCI(t *testing.T) {
+	repo := Repository{
+		Repo: taggedCommit(t),
+		CI: &ci.Build{
+			Tag:    "v1.0.0",
+			Commit: "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		},
+	}
+
+	tags, err := repo.Tags()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"my-tag", "v1.0.0"}, tags)
+
+	repo.CI.Commit = "45f7c4bc1e422d450f791b1ebe844866dd6f837f"
+
+	tags, err = repo.Tags()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"my-tag"}, tags)
+}
+
+func TestGitMergeBase(t *testing.T) {
+	r := oneCommit(t)
+	base := doCommit(t, r)
+
+	tree, err := r.Worktree()
+	assert.Nil(t, err)
+
+	err = tree.Checkout(&git.CheckoutOptions{
+		Create: true,
+		Branch: plumbing.ReferenceName("refs/heads/my-branch"),
+	})
+	assert.Nil(t, err)
+	tree.Filesystem.Create("branch-file")
+	tree.Add("branch-file")
+	doCommit(t, r)
+
+	err = tree.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.ReferenceName("refs/heads/master"),
+	})
+	assert.Nil(t, err)
+	tree.Filesystem.Create("master-file")
+	tree.Add("master-file")
+	doCommit(t, r)
+
+	repo := Repository{
+		Repo: r,
+	}
+
+	mergeBase, err := repo.MergeBase("master", "my-branch")
+	assert.Nil(t, err)
+	assert.Equal(t, base.String(), mergeBase)
+
+	mergeBase, err = repo.MergeBase("master", "master")
+	assert.Nil(t, err)
+	assert.NotEqual(t, base.String(), mergeBase)
+}
+
+func TestGitIsDirty(t *testing.T) {
+	r := oneCommit(t)
+
+	repo := Repository{
+		Repo: r,
+	}
+
+	dirty, err := repo.IsDirty()
+	assert.Nil(t, err)
+	assert.False(t, dirty)
+
+	tree, err := r.Worktree()
+	assert.Nil(t, err)
+	tree.Filesystem.Create("new-file")
+
+	dirty, err = repo.IsDirty()
+	assert.Nil(t, err)
+	assert.True(t, dirty)
+}
+
+func TestGitMetadata(t *testing.T) {
+	r := taggedCommit(t)
+	r.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{"git@github.com:justinbarrick/hone.git"},
+	})
+
+	repo := Repository{
+		Repo: r,
+	}
+
+	m := repo.Metadata(AllMetadata)
+	assert.Equal(t, "my-tag", m.Tag)
+	assert.Equal(t, []string{"my-tag"}, m.Tags)
+	assert.Equal(t, "master", m.Branch)
+	assert.Equal(t, "my-tag", m.Describe)
+	assert.False(t, m.Dirty)
+	assert.Equal(t, "test <test@test.com>", m.Author)
+	assert.Equal(t, "First commit!", m.Message)
+	assert.Equal(t, "git@github.com:justinbarrick/hone.git", m.RemoteURL)
+	assert.Equal(t, m.Commit, m.MergeBase)
+
+	env := m.Env()
+	assert.Equal(t, "my-tag", env["GIT_TAGS"])
+	assert.Equal(t, "false", env["GIT_DIRTY"])
+	assert.Equal(t, "my-tag", env["GIT_DESCRIBE"])
+
+	conditions := m.Conditions()
+	assert.Equal(t, []string{"my-tag"}, conditions["GIT_TAGS"])
+	assert.Equal(t, false, conditions["GIT_DIRTY"])
+	assert.Equal(t, "master", conditions["GIT_BRANCH"])
+
+	tree, err := r.Worktree()
+	assert.Nil(t, err)
+	tree.Filesystem.Create("new-file")
+
+	m = repo.Metadata(MetadataFields{})
+	assert.Equal(t, "my-tag", m.Tag)
+	assert.Equal(t, "", m.Describe)
+	assert.False(t, m.Dirty)
+	assert.Equal(t, "", m.MergeBase)
+	assert.Equal(t, "", m.Env()["GIT_DIRTY"])
+
+	conditions = m.Conditions()
+	assert.NotContains(t, conditions, "GIT_DIRTY")
+	assert.NotContains(t, conditions, "GIT_DESCRIBE")
+	assert.NotContains(t, conditions, "GIT_MERGE_BASE")
+	assert.Equal(t, "master", conditions["GIT_BRANCH"])
+
+	m = repo.Metadata(MetadataFields{Dirty: true})
+	assert.True(t, m.Dirty)
+	assert.Equal(t, "true", m.Env()["GIT_DIRTY"])
+	assert.Equal(t, true, m.Conditions()["GIT_DIRTY"])
+}
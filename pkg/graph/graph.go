@@ -3,7 +3,10 @@ package graph
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	. "github.com/justinbarrick/hone/pkg/graph/node"
 	"github.com/justinbarrick/hone/pkg/logger"
@@ -13,13 +16,30 @@ import (
 	"gonum.org/v1/gonum/graph/topo"
 )
 
+// Set on a node that was not run because some of its dependencies failed.
+type DependencyError struct {
+	Deps []string
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("Failed dependencies: %s", e.Deps)
+}
+
+// Set on a node that was not run, or was stopped while running, because the run
+// was canceled.
+var ErrCanceled = errors.New("Canceled.")
+
 type Graph struct {
-	graph *simple.DirectedGraph
+	graph      *simple.DirectedGraph
+	canceled   chan bool
+	cancelOnce *sync.Once
 }
 
 func NewGraph(nodes []Node) Graph {
 	graph := Graph{
-		graph: simple.NewDirectedGraph(),
+		graph:      simple.NewDirectedGraph(),
+		canceled:   make(chan bool),
+		cancelOnce: &sync.Once{},
 	}
 
 	graph.BuildGraph(nodes)
@@ -31,14 +51,170 @@ func (g *Graph) setEdges() error {
 
 	for _, node := range graph.NodesOf(nodes) {
 		for _, dep := range node.(Node).GetDeps() {
+			depNode := g.graph.Node(utils.Crc(dep))
+			if depNode == nil || depNode.ID() == node.ID() {
+				continue
+			}
+
 			g.graph.SetEdge(simple.Edge{
 				T: node,
-				F: g.graph.Node(utils.Crc(dep)),
+				F: depNode,
 			})
 		}
 	}
 
-	return nil
+	missing := g.MissingDeps()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	names := []string{}
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := []string{}
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s depends on %s", name, strings.Join(missing[name], ", ")))
+	}
+
+	return fmt.Errorf("Missing dependencies: %s.", strings.Join(msgs, "; "))
+}
+
+// Return the dependencies of each node that are not in the graph.
+func (g *Graph) MissingDeps() map[string][]string {
+	missing := map[string][]string{}
+
+	for _, node := range graph.NodesOf(g.graph.Nodes()) {
+		for _, dep := range node.(Node).GetDeps() {
+			if g.graph.Node(utils.Crc(dep)) == nil {
+				name := node.(Node).GetName()
+				missing[name] = append(missing[name], dep)
+			}
+		}
+	}
+
+	return missing
+}
+
+// Return the names of the nodes in each dependency cycle.
+func (g *Graph) Cycles() [][]string {
+	g.setEdges()
+
+	_, err := topo.Sort(g.graph)
+	unorderable, ok := err.(topo.Unorderable)
+	if !ok {
+		return nil
+	}
+
+	cycles := [][]string{}
+	for _, component := range unorderable {
+		names := []string{}
+		for _, node := range component {
+			names = append(names, node.(Node).GetName())
+		}
+		sort.Strings(names)
+		cycles = append(cycles, names)
+	}
+
+	return cycles
+}
+
+// Return the names of the nodes that none of the targets depend on.
+func (g *Graph) Unreachable(targets []string) ([]string, error) {
+	selected, err := g.Select(targets, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	unreachable := []string{}
+	for _, node := range graph.NodesOf(g.graph.Nodes()) {
+		if !selected[node.ID()] {
+			unreachable = append(unreachable, node.(Node).GetName())
+		}
+	}
+
+	sort.Strings(unreachable)
+	return unreachable, nil
+}
+
+// Return the IDs of the targets and the nodes that they depend on. Excluded
+// nodes are left out along with any dependencies that only they need.
+func (g *Graph) Select(targets []string, excludes []string) (map[int64]bool, error) {
+	excluded := map[int64]bool{}
+	for _, exclude := range excludes {
+		if g.graph.Node(utils.Crc(exclude)) == nil {
+			return nil, fmt.Errorf("Excluded job %s not found.", exclude)
+		}
+
+		excluded[utils.Crc(exclude)] = true
+	}
+
+	queue := []graph.Node{}
+	for _, target := range targets {
+		targetNode := g.graph.Node(utils.Crc(target))
+		if targetNode == nil {
+			return nil, fmt.Errorf("Target %s not found.", target)
+		}
+
+		queue = append(queue, targetNode)
+	}
+
+	if err := g.setEdges(); err != nil {
+		return nil, err
+	}
+
+	selected := map[int64]bool{}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		if selected[node.ID()] || excluded[node.ID()] {
+			continue
+		}
+
+		selected[node.ID()] = true
+		queue = append(queue, graph.NodesOf(g.graph.To(node.ID()))...)
+	}
+
+	return selected, nil
+}
+
+// Return the named nodes and every node that depends on them, sorted by name.
+func (g *Graph) Dependents(names []string) ([]string, error) {
+	queue := []graph.Node{}
+	for _, name := range names {
+		node := g.graph.Node(utils.Crc(name))
+		if node == nil {
+			return nil, fmt.Errorf("Job %s not found.", name)
+		}
+
+		queue = append(queue, node)
+	}
+
+	if err := g.setEdges(); err != nil {
+		return nil, err
+	}
+
+	seen := map[int64]bool{}
+	dependents := []string{}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		if seen[node.ID()] {
+			continue
+		}
+
+		seen[node.ID()] = true
+		dependents = append(dependents, node.(Node).GetName())
+		queue = append(queue, graph.NodesOf(g.graph.From(node.ID()))...)
+	}
+
+	sort.Strings(dependents)
+	return dependents, nil
 }
 
 func (g *Graph) AddDep(targetNode Node, dep string) error {
@@ -65,13 +241,19 @@ func (g *Graph) BuildGraph(nodes []Node) {
 	}
 }
 
-func (g *Graph) WaitForDeps(callback func(Node) error, servicesWg *sync.WaitGroup) func(Node) error {
+// Wrap callback to wait for a node's dependencies before running it, only
+// dependencies in selected are waited for.
+func (g *Graph) WaitForDeps(callback func(Node) error, servicesWg *sync.WaitGroup, selected map[int64]bool) func(Node) error {
 	return func(n Node) error {
 		defer close(n.GetDone())
 
 		failedDeps := []string{}
 
 		for _, node := range graph.NodesOf(g.graph.To(n.ID())) {
+			if !selected[node.ID()] {
+				continue
+			}
+
 			d := node.(Node)
 			_ = <-d.GetDone()
 			if d.GetError() != nil {
@@ -79,8 +261,15 @@ func (g *Graph) WaitForDeps(callback func(Node) error, servicesWg *sync.WaitGrou
 			}
 		}
 
+		select {
+		case <-g.canceled:
+			n.SetError(ErrCanceled)
+			return n.GetError()
+		default:
+		}
+
 		if len(failedDeps) > 0 {
-			n.SetError(fmt.Errorf("Failed dependencies: %s", failedDeps))
+			n.SetError(&DependencyError{Deps: failedDeps})
 			logger.LogError(n, n.GetError().Error())
 		}
 
@@ -88,6 +277,9 @@ func (g *Graph) WaitForDeps(callback func(Node) error, servicesWg *sync.WaitGrou
 			return n.GetError()
 		}
 
+		// The node is ready to run once its dependencies are done.
+		n.SetQueued(time.Now().UTC())
+
 		servicesWg.Add(1)
 		detach := make(chan bool)
 		n.SetDetach(detach)
@@ -105,7 +297,9 @@ func (g *Graph) WaitForDeps(callback func(Node) error, servicesWg *sync.WaitGrou
 }
 
 func (g *Graph) IterSorted(callback func(Node) error) []error {
-	g.setEdges()
+	if err := g.setEdges(); err != nil {
+		return []error{err}
+	}
 
 	sorted, err := topo.Sort(g.graph)
 	if err != nil {
@@ -122,15 +316,20 @@ func (g *Graph) IterSorted(callback func(Node) error) []error {
 	return errors
 }
 
-func (g *Graph) IterTarget(target string, callback func(Node) error) []error {
-	targetId := utils.Crc(target)
-	targetNode := g.graph.Node(targetId)
-	if targetNode == nil {
-		return []error{errors.New(fmt.Sprintf("Target %s not found.", target))}
+// Call callback on the targets and their dependencies in dependency order,
+// excluded nodes are pruned with their dependencies.
+func (g *Graph) IterTargets(targets []string, excludes []string, callback func(Node) error) []error {
+	selected, err := g.Select(targets, excludes)
+	if err != nil {
+		return []error{err}
 	}
 
+	return g.iterSelected(selected, callback)
+}
+
+func (g *Graph) iterSelected(selected map[int64]bool, callback func(Node) error) []error {
 	return g.IterSorted(func(node Node) error {
-		if !topo.PathExistsIn(g.graph, g.graph.Node(node.ID()), targetNode) {
+		if !selected[node.ID()] {
 			return nil
 		}
 
@@ -138,16 +337,39 @@ func (g *Graph) IterTarget(target string, callback func(Node) error) []error {
 	})
 }
 
-func (g *Graph) ResolveTarget(target string, callback func(Node) error) []error {
+// Run the targets and their dependencies, running each node as soon as its
+// dependencies have completed.
+func (g *Graph) ResolveTargets(targets []string, excludes []string, callback func(Node) error) []error {
+	selected, err := g.Select(targets, excludes)
+	if err != nil {
+		return []error{err}
+	}
+
 	stopCh := make(chan bool)
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() {
+			close(stopCh)
+		})
+	}
+
+	// Stop running nodes as soon as the run is canceled.
+	go func() {
+		select {
+		case <-g.canceled:
+			stop()
+		case <-stopCh:
+		}
+	}()
 
 	var wg sync.WaitGroup
 	var servicesWg sync.WaitGroup
+	var lock sync.Mutex
 
-	callback = g.WaitForDeps(callback, &servicesWg)
+	callback = g.WaitForDeps(callback, &servicesWg, selected)
 	errors := []error{}
 
-	iterErrors := g.IterTarget(target, func(node Node) error {
+	iterErrors := g.iterSelected(selected, func(node Node) error {
 		wg.Add(1)
 
 		go func(n Node) {
@@ -155,26 +377,36 @@ func (g *Graph) ResolveTarget(target string, callback func(Node) error) []error
 			n.SetStop(stopCh)
 			err := callback(n)
 			if err != nil {
+				lock.Lock()
 				errors = append(errors, err)
+				lock.Unlock()
 			}
 		}(node)
 
 		return nil
 	})
 
+	wg.Wait()
 	errors = append(errors, iterErrors...)
 
-	wg.Wait()
-	close(stopCh)
+	stop()
 	servicesWg.Wait()
 	return errors
 }
 
-func (g *Graph) LongestTarget(target string) (int, []error) {
+// Cancel the run: nodes that have not started are not run and running nodes are
+// stopped, both with ErrCanceled. ResolveTargets returns once they are done.
+func (g *Graph) Cancel() {
+	g.cancelOnce.Do(func() {
+		close(g.canceled)
+	})
+}
+
+func (g *Graph) LongestTargets(targets []string, excludes []string) (int, []error) {
 	longestName := 0
 	lock := sync.Mutex{}
 
-	errors := g.IterTarget(target, func(n Node) error {
+	errors := g.IterTargets(targets, excludes, func(n Node) error {
 		lock.Lock()
 
 		name := n.GetName()
@@ -0,0 +1,136 @@
+package graph
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/graph/node"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	logger.InitLogger(0, nil)
+}
+
+func newJob(name string, deps ...string) *job.Job {
+	j := &job.Job{
+		Name: name,
+	}
+
+	for _, dep := range deps {
+		j.AddDep(dep)
+	}
+
+	return j
+}
+
+func testGraph() Graph {
+	return NewGraph([]node.Node{
+		newJob("generate"),
+		newJob("build", "generate"),
+		newJob("test", "generate"),
+		newJob("lint"),
+		newJob("vendor"),
+		newJob("release", "build", "vendor"),
+	})
+}
+
+func resolve(t *testing.T, g Graph, targets, excludes []string) []string {
+	lock := sync.Mutex{}
+	ran := []string{}
+
+	errs := g.ResolveTargets(targets, excludes, func(n node.Node) error {
+		lock.Lock()
+		ran = append(ran, n.GetName())
+		lock.Unlock()
+		return nil
+	})
+	assert.Equal(t, 0, len(errs))
+
+	sort.Strings(ran)
+	return ran
+}
+
+func TestResolveTargets(t *testing.T) {
+	assert.Equal(t, []string{"build", "generate", "lint", "test"}, resolve(t, testGraph(), []string{"build", "test", "lint"}, nil))
+}
+
+func TestResolveTargetsQueued(t *testing.T) {
+	generate := newJob("generate")
+	build := newJob("build", "generate")
+	g := NewGraph([]node.Node{generate, build})
+
+	finished := time.Time{}
+	errs := g.ResolveTargets([]string{"build"}, nil, func(n node.Node) error {
+		if n == generate {
+			time.Sleep(10 * time.Millisecond)
+			finished = time.Now().UTC()
+		}
+		return nil
+	})
+	assert.Equal(t, 0, len(errs))
+
+	assert.False(t, build.QueuedTime.IsZero())
+	assert.False(t, build.QueuedTime.Before(finished))
+}
+
+func TestResolveTargetsCancel(t *testing.T) {
+	generate := newJob("generate")
+	build := newJob("build", "generate")
+	g := NewGraph([]node.Node{generate, build})
+
+	ran := []string{}
+	errs := g.ResolveTargets([]string{"build"}, nil, func(n node.Node) error {
+		ran = append(ran, n.GetName())
+		g.Cancel()
+		<-n.(*job.Job).Stop
+		return ErrCanceled
+	})
+
+	assert.Equal(t, []error{ErrCanceled, ErrCanceled}, errs)
+	assert.Equal(t, []string{"generate"}, ran)
+	assert.Equal(t, ErrCanceled, build.GetError())
+}
+
+func TestResolveTargetsExclude(t *testing.T) {
+	assert.Equal(t, []string{"release", "vendor"}, resolve(t, testGraph(), []string{"release"}, []string{"build"}))
+	assert.Equal(t, []string{"build", "release", "test", "vendor"}, resolve(t, testGraph(), []string{"release", "test"}, []string{"generate"}))
+	assert.Equal(t, []string{"lint"}, resolve(t, testGraph(), []string{"lint", "test"}, []string{"test"}))
+}
+
+func TestSelectMissing(t *testing.T) {
+	g := testGraph()
+
+	_, err := g.Select([]string{"missing"}, nil)
+	assert.Equal(t, "Target missing not found.", err.Error())
+
+	_, err = g.Select([]string{"build"}, []string{"missing"})
+	assert.Equal(t, "Excluded job missing not found.", err.Error())
+}
+
+func TestUnreachable(t *testing.T) {
+	g := testGraph()
+
+	unreachable, err := g.Unreachable([]string{"release", "lint"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test"}, unreachable)
+}
+
+func TestDependents(t *testing.T) {
+	g := testGraph()
+
+	dependents, err := g.Dependents([]string{"generate", "lint"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"build", "generate", "lint", "release", "test"}, dependents)
+
+	dependents, err = g.Dependents([]string{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, dependents)
+
+	_, err = g.Dependents([]string{"missing"})
+	assert.NotNil(t, err)
+}
@@ -1,6 +1,8 @@
 package node
 
 import (
+	"time"
+
 	"github.com/justinbarrick/hone/pkg/utils"
 )
 
@@ -12,6 +14,7 @@ type Node interface {
 	SetError(error)
 	SetDetach(chan bool)
 	SetStop(chan bool)
+	SetQueued(time.Time)
 	GetDone() chan bool
 	ID() int64
 }
@@ -0,0 +1,200 @@
+package hermetic
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bmatcuk/doublestar"
+	"github.com/justinbarrick/hone/pkg/cache"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+)
+
+type FileState struct {
+	ModTime time.Time
+	Size    int64
+	Hash    string
+}
+
+// Snapshot maps paths relative to the workspace root to their state.
+type Snapshot map[string]FileState
+
+// Hasher hashes files for snapshots, reusing the previous hash of a file if its
+// mtime and size have not changed. Use one per build.
+type Hasher struct {
+	lock   sync.Mutex
+	hashes map[string]FileState
+}
+
+func NewHasher() *Hasher {
+	return &Hasher{
+		hashes: map[string]FileState{},
+	}
+}
+
+func (h *Hasher) hashFile(path string, info os.FileInfo) (FileState, error) {
+	state := FileState{
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+	}
+
+	h.lock.Lock()
+	cached, ok := h.hashes[path]
+	h.lock.Unlock()
+
+	if ok && cached.ModTime.Equal(state.ModTime) && cached.Size == state.Size {
+		return cached, nil
+	}
+
+	hash, err := cache.HashFile(path)
+	if err != nil {
+		return state, err
+	}
+
+	state.Hash = hash
+
+	h.lock.Lock()
+	h.hashes[path] = state
+	h.lock.Unlock()
+
+	return state, nil
+}
+
+func Clean(path string) string {
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// Return true if path is one of patterns, is inside of a directory in patterns
+// or matches one of the glob patterns.
+func Match(patterns []string, path string) bool {
+	path = Clean(path)
+
+	for _, pattern := range patterns {
+		pattern = Clean(pattern)
+
+		if pattern == "." || path == pattern || strings.HasPrefix(path, pattern+"/") {
+			return true
+		}
+
+		if matched, _ := doublestar.Match(pattern, path); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Take a snapshot of every file under root without reusing any hashes.
+func Take(root string, exclude []string) (Snapshot, error) {
+	return NewHasher().Take(root, exclude)
+}
+
+// Take a snapshot of every file under root, skipping the .git directory and
+// anything matching exclude.
+func (h *Hasher) Take(root string, exclude []string) (Snapshot, error) {
+	snapshot := Snapshot{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" || Match(exclude, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.Mode().IsRegular() || Match(exclude, rel) {
+			return nil
+		}
+
+		state, err := h.hashFile(path, info)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		snapshot[Clean(rel)] = state
+		return nil
+	})
+
+	return snapshot, err
+}
+
+// Return the paths that were created, modified or deleted between two snapshots.
+func Diff(before, after Snapshot) []string {
+	changed := []string{}
+
+	for path, state := range after {
+		previous, ok := before[path]
+		if !ok || previous.Hash != state.Hash {
+			changed = append(changed, path)
+		}
+	}
+
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+// Return the paths that do not match any of the declared patterns.
+func Undeclared(paths []string, declared []string) []string {
+	undeclared := []string{}
+
+	for _, path := range paths {
+		if !Match(declared, path) {
+			undeclared = append(undeclared, path)
+		}
+	}
+
+	return undeclared
+}
+
+// Log undeclared paths and return an error if the job is in strict error mode.
+func Report(j *job.Job, kind string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	log := logger.LogError
+	if j.GetStrict() != job.StrictError {
+		log = logger.Log
+	}
+
+	for _, path := range paths {
+		log(j, fmt.Sprintf("Undeclared %s: %s", kind, path))
+	}
+
+	if j.GetStrict() == job.StrictError {
+		return errors.New(fmt.Sprintf("Job has %d undeclared %ss.", len(paths), kind))
+	}
+
+	return nil
+}
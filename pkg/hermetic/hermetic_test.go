@@ -0,0 +1,79 @@
+package hermetic
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	assert.True(t, Match([]string{"./bin/"}, "bin/hone"))
+	assert.True(t, Match([]string{"bin/hone"}, "./bin/hone"))
+	assert.True(t, Match([]string{"**/*.go"}, "pkg/job/job.go"))
+	assert.False(t, Match([]string{"bin"}, "binary"))
+	assert.False(t, Match([]string{}, "bin/hone"))
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hone-test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(path, contents string) {
+		path = filepath.Join(dir, path)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+
+	write("src/main.go", "package main")
+	write("src/deleted.go", "package main")
+	write("src/same.go", "package main")
+	write(".git/HEAD", "ref: refs/heads/master")
+	write(".hone_cache/out/abc", "cached")
+
+	before, err := Take(dir, []string{".hone_cache"})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(before))
+
+	write("src/main.go", "package other")
+	write("bin/hone", "binary")
+	write(".git/HEAD", "ref: refs/heads/other")
+	write(".hone_cache/out/def", "cached")
+	assert.Nil(t, os.Remove(filepath.Join(dir, "src/deleted.go")))
+
+	after, err := Take(dir, []string{".hone_cache"})
+	assert.Nil(t, err)
+
+	changed := Diff(before, after)
+	assert.Equal(t, []string{"bin/hone", "src/deleted.go", "src/main.go"}, changed)
+	assert.Equal(t, []string{"src/deleted.go", "src/main.go"}, Undeclared(changed, []string{"./bin"}))
+}
+
+func TestParseTrace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hone-test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "src"), 0755))
+
+	trace := strings.Join([]string{
+		`101 openat(AT_FDCWD, "/etc/ld.so.cache", O_RDONLY|O_CLOEXEC) = 3`,
+		`101 openat(AT_FDCWD, "main.go", O_RDONLY|O_CLOEXEC) = 3`,
+		`101 openat(AT_FDCWD, "` + dir + `/go.mod", O_RDONLY) = 3`,
+		`101 openat(AT_FDCWD, "missing.go", O_RDONLY) = -1 ENOENT (No such file or directory)`,
+		`102 openat(AT_FDCWD, "out", O_WRONLY|O_CREAT|O_TRUNC, 0644) = 4`,
+		`102 open("out", O_RDONLY) = 4`,
+		`102 openat(AT_FDCWD, ".", O_RDONLY|O_NONBLOCK|O_CLOEXEC|O_DIRECTORY) = 5`,
+		`102 openat(AT_FDCWD, "../../outside", O_RDONLY) = 5`,
+		`+++ exited with 0 +++`,
+	}, "\n")
+
+	reads, writes, err := ParseTrace(strings.NewReader(trace), dir, filepath.Join(dir, "src"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"go.mod", "src/main.go"}, reads)
+	assert.Equal(t, []string{"src/out"}, writes)
+}
@@ -0,0 +1,102 @@
+package hermetic
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+var traceLine = regexp.MustCompile(`(open|openat|creat)\((?:AT_FDCWD, |\d+, )?"((?:[^"\\]|\\.)*)"(?:, ([A-Z_|]+))?.*\) = (-?\d+)`)
+
+// Return true if reads can be traced on this host.
+func CanTrace() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+
+	_, err := exec.LookPath("strace")
+	return err == nil
+}
+
+// Wrap a command so that the files it opens are written to output.
+func TraceCommand(command []string, output string) []string {
+	return append([]string{
+		"strace", "-f", "-qq", "-e", "trace=open,openat,creat", "-o", output,
+	}, command...)
+}
+
+// Parse strace output, returning the files read and written relative to root.
+// Relative paths are resolved from dir and paths outside of root are dropped.
+func ParseTrace(reader io.Reader, root, dir string) (reads []string, writes []string, err error) {
+	readSet := map[string]bool{}
+	writeSet := map[string]bool{}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		match := traceLine.FindStringSubmatch(scanner.Text())
+		if match == nil || strings.HasPrefix(match[4], "-") {
+			continue
+		}
+
+		path := match[2]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(absDir, path)
+		}
+
+		rel, err := filepath.Rel(absRoot, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+
+		flags := match[3]
+		if strings.Contains(flags, "O_DIRECTORY") {
+			continue
+		}
+
+		if match[1] == "creat" || strings.Contains(flags, "O_WRONLY") || strings.Contains(flags, "O_RDWR") || strings.Contains(flags, "O_CREAT") {
+			writeSet[Clean(rel)] = true
+		} else {
+			readSet[Clean(rel)] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for path := range readSet {
+		if writeSet[path] {
+			continue
+		}
+
+		if fi, err := os.Stat(filepath.Join(absRoot, path)); err == nil && fi.IsDir() {
+			continue
+		}
+
+		reads = append(reads, path)
+	}
+
+	for path := range writeSet {
+		writes = append(writes, path)
+	}
+
+	sort.Strings(reads)
+	sort.Strings(writes)
+	return reads, writes, nil
+}
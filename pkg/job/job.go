@@ -4,14 +4,61 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/justinbarrick/hone/pkg/junit"
 	"github.com/justinbarrick/hone/pkg/utils"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/gocty"
 )
 
+// Returned when a job's command ran and exited unsuccessfully, as opposed to a
+// job that could not be run at all.
+type ExitError struct {
+	Code    int
+	Message string
+}
+
+func (e *ExitError) Error() string {
+	return e.Message
+}
+
+// Return true if err is a job's command exiting unsuccessfully.
+func IsExitError(err error) bool {
+	_, ok := err.(*ExitError)
+	return ok
+}
+
+// Timing and resource usage of a job's run, recorded in the build report.
+// PeakMemory and CPUTime are only recorded on the Docker and local engines.
+type Stats struct {
+	Engine          string
+	ContainerID     string
+	ExitCode        *int
+	CacheRestore    time.Duration
+	CacheUpload     time.Duration
+	BytesDownloaded int64
+	BytesUploaded   int64
+	PeakMemory      int64
+	CPUTime         time.Duration
+}
+
+// Record the exit code of the job's command from the error it returned, if it
+// ran.
+func (s *Stats) SetExitCode(err error) {
+	code := 0
+	if exitErr, ok := err.(*ExitError); ok {
+		code = exitErr.Code
+	} else if err != nil {
+		return
+	}
+
+	s.ExitCode = &code
+}
+
 type StringSet []string
 
 func (s StringSet) Strings() []string {
@@ -31,9 +78,104 @@ func (s StringSet) Strings() []string {
 	return set
 }
 
+const (
+	PullAlways  = "always"
+	PullMissing = "missing"
+	PullNever   = "never"
+)
+
+const (
+	StrictOff   = "off"
+	StrictWarn  = "warn"
+	StrictError = "error"
+)
+
+type Build struct {
+	Dockerfile *string            `hcl:"dockerfile" json:"dockerfile"`
+	Context    *string            `hcl:"context" json:"context"`
+	Tags       *StringSet         `hcl:"tags" json:"tags" hash:"method:Strings"`
+	Args       *map[string]string `hcl:"args" json:"args"`
+	Target     *string            `hcl:"target" json:"target"`
+	Push       *bool              `hcl:"push" json:"push"`
+	Username   *string            `hcl:"username" json:"-" hash:"-"`
+	Password   *string            `hcl:"password" json:"-" hash:"-"`
+}
+
+func (b Build) GetDockerfile() string {
+	if b.Dockerfile == nil {
+		return filepath.Join(b.GetContext(), "Dockerfile")
+	}
+
+	return *b.Dockerfile
+}
+
+func (b Build) GetContext() string {
+	if b.Context == nil {
+		return "."
+	}
+
+	return *b.Context
+}
+
+func (b Build) GetTags() []string {
+	if b.Tags == nil {
+		return []string{}
+	}
+
+	return b.Tags.Strings()
+}
+
+func (b Build) GetArgs() map[string]*string {
+	args := map[string]*string{}
+
+	if b.Args == nil {
+		return args
+	}
+
+	for key, value := range *b.Args {
+		value := value
+		args[key] = &value
+	}
+
+	return args
+}
+
+func (b Build) GetTarget() string {
+	if b.Target == nil {
+		return ""
+	}
+
+	return *b.Target
+}
+
+func (b Build) ShouldPush() bool {
+	if b.Push == nil {
+		return false
+	}
+
+	return *b.Push
+}
+
+func (b Build) GetUsername() string {
+	if b.Username == nil {
+		return ""
+	}
+
+	return *b.Username
+}
+
+func (b Build) GetPassword() string {
+	if b.Password == nil {
+		return ""
+	}
+
+	return *b.Password
+}
+
 type Job struct {
 	Name         string             `hcl:"name,label" json:"name"`
 	Template     *string            `hcl:"template" hash:"-" json:"-"`
+	Description  *string            `hcl:"description" json:"description" hash:"-"`
 	Image        *string            `hcl:"image" json:"image"`
 	Shell        *string            `hcl:"shell" json:"shell"`
 	Exec         *StringSet         `hcl:"exec" json:"exec" hash:"method:Strings"`
@@ -46,9 +188,33 @@ type Job struct {
 	Privileged   *bool              `hcl:"privileged" json:"privileged"`
 	Workdir      *string            `hcl:"workdir" json:"workdir"`
 	Service      *bool              `hcl:"service" json:"service" hash:"-"`
+	Volumes      *StringSet         `hcl:"volumes" json:"volumes" hash:"method:Strings"`
+	CacheVolumes *map[string]string `hcl:"cache_volumes" json:"cacheVolumes" hash:"-"`
+	Tmpfs        *StringSet         `hcl:"tmpfs" json:"tmpfs" hash:"-"`
+	User         *string            `hcl:"user" json:"user"`
+	NetworkMode  *string            `hcl:"network_mode" json:"networkMode" hash:"-"`
+	DNS          *[]string          `hcl:"dns" json:"dns" hash:"-"`
+	ExtraHosts   *StringSet         `hcl:"extra_hosts" json:"extraHosts" hash:"-"`
+	Pull         *string            `hcl:"pull" json:"pull" hash:"-"`
+	Interpreter  *[]string          `hcl:"interpreter" json:"interpreter"`
+	InheritEnv   *StringSet         `hcl:"inherit_env" json:"inheritEnv" hash:"method:Strings"`
+	Sandbox      *bool              `hcl:"sandbox" json:"sandbox"`
+	Strict       *string            `hcl:"strict" json:"strict" hash:"-"`
+	TraceReads   *bool              `hcl:"trace_reads" json:"traceReads" hash:"-"`
+	TestReports  *StringSet         `hcl:"test_reports" json:"testReports" hash:"-"`
+	Build        *Build             `hcl:"build,block" json:"build"`
 	Cached       bool               `hash:"-" json:"cached"`
+	Skipped      bool               `hash:"-" json:"skipped"`
+	QueuedTime   time.Time          `hash:"-" json:"queuedTime"`
+	StartTime    time.Time          `hash:"-" json:"startTime"`
+	EndTime      time.Time          `hash:"-" json:"endTime"`
 	Hash         string             `hash:"-" json:"hash"`
+	ImageDigest  string             `hash:"-" json:"imageDigest"`
+	Matrix       map[string]string  `hash:"-" json:"matrix"`
+	Aggregate    bool               `hash:"-" json:"aggregate"`
 	OutputHashes map[string]string  `hash:"-" json:"outputHashes"`
+	Stats        Stats              `hash:"-" json:"stats"`
+	Tests        []junit.TestSuite  `hash:"-" json:"tests"`
 	Detach       chan bool          `hash:"-" json:"-"`
 	Stop         chan bool          `hash:"-" json:"-"`
 	Error        error              `hash:"-" json:"error"`
@@ -84,6 +250,66 @@ func (j *Job) Default(def Job) {
 		j.Workdir = def.Workdir
 	}
 
+	if j.Build == nil {
+		j.Build = def.Build
+	}
+
+	if j.Pull == nil {
+		j.Pull = def.Pull
+	}
+
+	if j.Interpreter == nil {
+		j.Interpreter = def.Interpreter
+	}
+
+	if j.InheritEnv == nil {
+		j.InheritEnv = def.InheritEnv
+	}
+
+	if j.Sandbox == nil {
+		j.Sandbox = def.Sandbox
+	}
+
+	if j.Strict == nil {
+		j.Strict = def.Strict
+	}
+
+	if j.TraceReads == nil {
+		j.TraceReads = def.TraceReads
+	}
+
+	if j.TestReports == nil {
+		j.TestReports = def.TestReports
+	}
+
+	if j.Volumes == nil {
+		j.Volumes = def.Volumes
+	}
+
+	if j.CacheVolumes == nil {
+		j.CacheVolumes = def.CacheVolumes
+	}
+
+	if j.Tmpfs == nil {
+		j.Tmpfs = def.Tmpfs
+	}
+
+	if j.User == nil {
+		j.User = def.User
+	}
+
+	if j.NetworkMode == nil {
+		j.NetworkMode = def.NetworkMode
+	}
+
+	if j.DNS == nil {
+		j.DNS = def.DNS
+	}
+
+	if j.ExtraHosts == nil {
+		j.ExtraHosts = def.ExtraHosts
+	}
+
 	for _, dep := range def.GetDeps() {
 		j.AddDep(dep)
 	}
@@ -107,12 +333,50 @@ func (j *Job) Default(def Job) {
 	}
 }
 
+// Check the fields that only accept a fixed set of values, which is done as soon
+// as the job is decoded.
+func (j Job) ValidateOptions() error {
+	switch j.GetPull() {
+	case PullAlways, PullMissing, PullNever:
+	default:
+		return fmt.Errorf("Pull must be one of %s, %s, or %s.", PullAlways, PullMissing, PullNever)
+	}
+
+	switch j.GetStrict() {
+	case StrictOff, StrictWarn, StrictError:
+	default:
+		return fmt.Errorf("Strict must be one of %s, %s, or %s.", StrictOff, StrictWarn, StrictError)
+	}
+
+	return nil
+}
+
 func (j Job) Validate(engine string) error {
+	if j.Aggregate {
+		return nil
+	}
+
 	myEngine := j.GetEngine()
 	if myEngine == "" {
 		myEngine = engine
 	}
 
+	if err := j.ValidateOptions(); err != nil {
+		return err
+	}
+
+	if j.Build != nil {
+		if myEngine != "" && myEngine != "docker" {
+			return errors.New("Build is only supported by the docker engine.")
+		}
+
+		if j.Shell != nil || j.Exec != nil {
+			return errors.New("Build and shell or exec are mutually exclusive.")
+		}
+
+		return nil
+	}
+
 	if j.Image == nil && myEngine != "local" {
 		return errors.New("Image is required when engine is not local.")
 	}
@@ -128,11 +392,71 @@ func (j Job) Validate(engine string) error {
 	return nil
 }
 
+// Return a copy of the job with fn applied to its input, output, workdir and
+// build paths.
+func (j Job) MapPaths(fn func(string) string) *Job {
+	mapSet := func(set *StringSet) *StringSet {
+		if set == nil {
+			return nil
+		}
+
+		mapped := StringSet{}
+		for _, path := range *set {
+			mapped = append(mapped, fn(path))
+		}
+
+		return &mapped
+	}
+
+	mapString := func(path *string) *string {
+		if path == nil {
+			return nil
+		}
+
+		mapped := fn(*path)
+		return &mapped
+	}
+
+	j.Inputs = mapSet(j.Inputs)
+	j.Outputs = mapSet(j.Outputs)
+	j.TestReports = mapSet(j.TestReports)
+	j.Workdir = mapString(j.Workdir)
+
+	if j.Build != nil {
+		build := *j.Build
+		build.Context = mapString(build.Context)
+		build.Dockerfile = mapString(build.Dockerfile)
+		j.Build = &build
+	}
+
+	return &j
+}
+
 func (j Job) GetName() string {
 	return j.Name
 }
 
+func (j Job) GetDescription() string {
+	if j.Description == nil {
+		return ""
+	}
+
+	return *j.Description
+}
+
+func (j Job) GetTemplate() string {
+	if j.Template == nil {
+		return ""
+	}
+
+	return *j.Template
+}
+
 func (j Job) GetImage() string {
+	if j.Image == nil {
+		return ""
+	}
+
 	image := *j.Image
 
 	if !strings.Contains(image, ":") {
@@ -142,6 +466,14 @@ func (j Job) GetImage() string {
 	return image
 }
 
+func (j Job) GetPull() string {
+	if j.Pull == nil {
+		return PullMissing
+	}
+
+	return *j.Pull
+}
+
 func (j Job) GetOutputs() []string {
 	outputs := []string{}
 
@@ -152,6 +484,14 @@ func (j Job) GetOutputs() []string {
 	return outputs
 }
 
+func (j Job) GetTestReports() []string {
+	if j.TestReports == nil {
+		return []string{}
+	}
+
+	return j.TestReports.Strings()
+}
+
 func (j Job) GetInputs() []string {
 	inputs := []string{}
 
@@ -166,14 +506,56 @@ func (j Job) GetShell() []string {
 	if j.Exec != nil {
 		return *j.Exec
 	} else if j.Shell != nil {
-		return []string{
-			"/bin/sh", "-cex", *j.Shell,
-		}
+		return append(j.GetInterpreter(), *j.Shell)
 	} else {
 		return nil
 	}
 }
 
+func (j Job) GetInterpreter() []string {
+	if j.Interpreter == nil || len(*j.Interpreter) == 0 {
+		return []string{"/bin/sh", "-cex"}
+	}
+
+	return append([]string{}, *j.Interpreter...)
+}
+
+func (j Job) GetInheritEnv() []string {
+	if j.InheritEnv == nil {
+		return []string{"PATH", "HOME"}
+	}
+
+	return j.InheritEnv.Strings()
+}
+
+func (j Job) GetStrict() string {
+	if j.Strict == nil {
+		return StrictOff
+	}
+
+	return *j.Strict
+}
+
+func (j Job) IsStrict() bool {
+	return j.GetStrict() != StrictOff
+}
+
+func (j Job) ShouldTraceReads() bool {
+	if j.TraceReads == nil {
+		return false
+	}
+
+	return *j.TraceReads
+}
+
+func (j Job) IsSandboxed() bool {
+	if j.Sandbox == nil {
+		return false
+	}
+
+	return *j.Sandbox
+}
+
 func (j Job) GetEngine() string {
 	if j.Engine != nil {
 		return *j.Engine
@@ -196,6 +578,55 @@ func (j Job) GetWorkdir() string {
 	return *j.Workdir
 }
 
+func (j Job) GetVolumes() []string {
+	if j.Volumes == nil {
+		return []string{}
+	}
+	return j.Volumes.Strings()
+}
+
+func (j Job) GetCacheVolumes() map[string]string {
+	if j.CacheVolumes == nil {
+		return map[string]string{}
+	}
+	return *j.CacheVolumes
+}
+
+func (j Job) GetTmpfs() []string {
+	if j.Tmpfs == nil {
+		return []string{}
+	}
+	return j.Tmpfs.Strings()
+}
+
+func (j Job) GetUser() string {
+	if j.User == nil {
+		return ""
+	}
+	return *j.User
+}
+
+func (j Job) GetNetworkMode() string {
+	if j.NetworkMode == nil {
+		return ""
+	}
+	return *j.NetworkMode
+}
+
+func (j Job) GetDNS() []string {
+	if j.DNS == nil {
+		return []string{}
+	}
+	return append([]string{}, *j.DNS...)
+}
+
+func (j Job) GetExtraHosts() []string {
+	if j.ExtraHosts == nil {
+		return []string{}
+	}
+	return j.ExtraHosts.Strings()
+}
+
 func (j Job) GetError() error {
 	return j.Error
 }
@@ -216,6 +647,11 @@ func (j *Job) SetStop(stopCh chan bool) {
 	j.Stop = stopCh
 }
 
+// Record when the job was ready to run, once its dependencies finished.
+func (j *Job) SetQueued(queued time.Time) {
+	j.QueuedTime = queued
+}
+
 func (j *Job) SetDetach(detachCh chan bool) {
 	j.Detach = detachCh
 }
@@ -266,6 +702,7 @@ func (j Job) MarshalJSON() ([]byte, error) {
 
 	return json.Marshal(struct {
 		Name         string
+		Description  string
 		Image        string
 		Shell        []string
 		Inputs       []string
@@ -275,13 +712,25 @@ func (j Job) MarshalJSON() ([]byte, error) {
 		Condition    string
 		Privileged   bool
 		Service      bool
+		Build        *Build
 		Successful   bool
 		Error        string
 		Cached       bool
+		Skipped      bool
 		Hash         string
+		ImageDigest  string
 		OutputHashes map[string]string
+		Matrix       map[string]string
+		Aggregate    bool
+		QueuedTime   time.Time
+		StartTime    time.Time
+		EndTime      time.Time
+		Stats        Stats
+		TestReports  []string
+		Tests        []junit.TestSuite
 	}{
 		Name:         j.GetName(),
+		Description:  j.GetDescription(),
 		Image:        j.GetImage(),
 		Shell:        j.GetShell(),
 		Inputs:       j.GetInputs(),
@@ -291,11 +740,22 @@ func (j Job) MarshalJSON() ([]byte, error) {
 		Condition:    condition,
 		Privileged:   privileged,
 		Service:      j.IsService(),
+		Build:        j.Build,
 		Successful:   (j.Error == nil),
 		Error:        errMsg,
 		Cached:       j.Cached,
+		Skipped:      j.Skipped,
 		Hash:         j.Hash,
+		ImageDigest:  j.ImageDigest,
 		OutputHashes: j.OutputHashes,
+		Matrix:       j.Matrix,
+		Aggregate:    j.Aggregate,
+		QueuedTime:   j.QueuedTime,
+		StartTime:    j.StartTime,
+		EndTime:      j.EndTime,
+		Stats:        j.Stats,
+		TestReports:  j.GetTestReports(),
+		Tests:        j.Tests,
 	})
 }
 
@@ -341,6 +801,21 @@ func (j Job) setMapStringList(objMap map[string]cty.Value, key string, value *St
 	return nil
 }
 
+// Set a list whose order matters, unlike setMapStringList.
+func (j Job) setMapList(objMap map[string]cty.Value, key string, value *[]string) error {
+	if value != nil {
+		valueEncoded, err := gocty.ToCtyValue(*value, cty.List(cty.String))
+		if err != nil {
+			return err
+		}
+		objMap[key] = valueEncoded
+	} else {
+		objMap[key] = cty.NullVal(cty.List(cty.String))
+	}
+
+	return nil
+}
+
 func (j Job) setMapStringMap(objMap map[string]cty.Value, key string, value *map[string]string) error {
 	if value != nil {
 		valueEncoded, err := gocty.ToCtyValue(value, cty.Map(cty.String))
@@ -355,17 +830,58 @@ func (j Job) setMapStringMap(objMap map[string]cty.Value, key string, value *map
 	return nil
 }
 
+var buildType = cty.Object(map[string]cty.Type{
+	"dockerfile": cty.String,
+	"context":    cty.String,
+	"tags":       cty.List(cty.String),
+	"args":       cty.Map(cty.String),
+	"target":     cty.String,
+	"push":       cty.Bool,
+})
+
+// Return the build block as a cty object, without its credentials.
+func (j Job) buildToCty() (cty.Value, error) {
+	b := j.Build
+	if b == nil {
+		return cty.NullVal(buildType), nil
+	}
+
+	objMap := map[string]cty.Value{}
+
+	j.setMapString(objMap, "dockerfile", b.Dockerfile)
+	j.setMapString(objMap, "context", b.Context)
+	j.setMapString(objMap, "target", b.Target)
+	j.setMapBool(objMap, "push", b.Push)
+
+	if err := j.setMapStringList(objMap, "tags", b.Tags); err != nil {
+		return cty.NilVal, err
+	}
+
+	if err := j.setMapStringMap(objMap, "args", b.Args); err != nil {
+		return cty.NilVal, err
+	}
+
+	return cty.ObjectVal(objMap), nil
+}
+
 func (j *Job) ToCty() (cty.Value, error) {
 	objMap := map[string]cty.Value{
 		"name": cty.StringVal(j.Name),
 	}
 
+	j.setMapString(objMap, "description", j.Description)
 	j.setMapString(objMap, "image", j.Image)
 	j.setMapString(objMap, "shell", j.Shell)
 	j.setMapString(objMap, "workdir", j.Workdir)
 	j.setMapString(objMap, "condition", j.Condition)
 	j.setMapString(objMap, "engine", j.Engine)
+	j.setMapString(objMap, "user", j.User)
+	j.setMapString(objMap, "network_mode", j.NetworkMode)
+	j.setMapString(objMap, "pull", j.Pull)
 	j.setMapBool(objMap, "privileged", j.Privileged)
+	j.setMapBool(objMap, "sandbox", j.Sandbox)
+	j.setMapBool(objMap, "trace_reads", j.TraceReads)
+	j.setMapString(objMap, "strict", j.Strict)
 
 	if err := j.setMapStringList(objMap, "exec", j.Exec); err != nil {
 		return cty.NilVal, err
@@ -383,9 +899,47 @@ func (j *Job) ToCty() (cty.Value, error) {
 		return cty.NilVal, err
 	}
 
+	if err := j.setMapStringList(objMap, "test_reports", j.TestReports); err != nil {
+		return cty.NilVal, err
+	}
+
+	if err := j.setMapStringList(objMap, "volumes", j.Volumes); err != nil {
+		return cty.NilVal, err
+	}
+
+	if err := j.setMapList(objMap, "interpreter", j.Interpreter); err != nil {
+		return cty.NilVal, err
+	}
+
+	if err := j.setMapStringList(objMap, "inherit_env", j.InheritEnv); err != nil {
+		return cty.NilVal, err
+	}
+
+	if err := j.setMapStringList(objMap, "tmpfs", j.Tmpfs); err != nil {
+		return cty.NilVal, err
+	}
+
+	if err := j.setMapList(objMap, "dns", j.DNS); err != nil {
+		return cty.NilVal, err
+	}
+
+	if err := j.setMapStringList(objMap, "extra_hosts", j.ExtraHosts); err != nil {
+		return cty.NilVal, err
+	}
+
 	if err := j.setMapStringMap(objMap, "env", j.Env); err != nil {
 		return cty.NilVal, err
 	}
 
+	if err := j.setMapStringMap(objMap, "cache_volumes", j.CacheVolumes); err != nil {
+		return cty.NilVal, err
+	}
+
+	build, err := j.buildToCty()
+	if err != nil {
+		return cty.NilVal, err
+	}
+	objMap["build"] = build
+
 	return cty.ObjectVal(objMap), nil
 }
@@ -1,10 +1,12 @@
 package job
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 )
 
 func TestSetMapString(t *testing.T) {
@@ -15,3 +17,73 @@ func TestSetMapString(t *testing.T) {
 
 	assert.Equal(t, true, objMap["test"].IsNull())
 }
+
+func TestStatsSetExitCode(t *testing.T) {
+	stats := Stats{}
+	stats.SetExitCode(errors.New("Cannot connect to the Docker daemon"))
+	assert.Nil(t, stats.ExitCode)
+
+	stats.SetExitCode(&ExitError{Code: 2, Message: "exit status 2"})
+	assert.Equal(t, 2, *stats.ExitCode)
+
+	stats.SetExitCode(nil)
+	assert.Equal(t, 0, *stats.ExitCode)
+}
+
+func TestToCtyBuild(t *testing.T) {
+	context := "images/app"
+	password := "hunter2"
+	push := true
+
+	j := &Job{
+		Name: "image",
+		Build: &Build{
+			Context:  &context,
+			Tags:     &StringSet{"app:latest"},
+			Push:     &push,
+			Password: &password,
+		},
+	}
+
+	value, err := j.ToCty()
+	assert.Nil(t, err)
+
+	build := value.GetAttr("build")
+	assert.Equal(t, cty.StringVal("images/app"), build.GetAttr("context"))
+	assert.Equal(t, cty.ListVal([]cty.Value{cty.StringVal("app:latest")}), build.GetAttr("tags"))
+	assert.Equal(t, cty.True, build.GetAttr("push"))
+	assert.True(t, build.GetAttr("dockerfile").IsNull())
+	assert.False(t, build.Type().HasAttribute("password"))
+
+	encoded, err := ctyjson.Marshal(value, value.Type())
+	assert.Nil(t, err)
+	assert.Contains(t, string(encoded), `"build":{"args":null,"context":"images/app"`)
+	assert.NotContains(t, string(encoded), password)
+
+	value, err = (&Job{Name: "test"}).ToCty()
+	assert.Nil(t, err)
+	assert.True(t, value.GetAttr("build").IsNull())
+}
+
+func TestToCtyInterpreter(t *testing.T) {
+	j := &Job{
+		Name:        "test",
+		Interpreter: &[]string{"python3", "-c"},
+	}
+
+	value, err := j.ToCty()
+	assert.Nil(t, err)
+	assert.Equal(t, cty.ListVal([]cty.Value{cty.StringVal("python3"), cty.StringVal("-c")}), value.GetAttr("interpreter"))
+}
+
+func TestGetDNS(t *testing.T) {
+	j := &Job{Name: "test"}
+	assert.Equal(t, []string{}, j.GetDNS())
+
+	j.DNS = &[]string{"8.8.8.8", "1.1.1.1"}
+	assert.Equal(t, []string{"8.8.8.8", "1.1.1.1"}, j.GetDNS())
+
+	value, err := j.ToCty()
+	assert.Nil(t, err)
+	assert.Equal(t, cty.ListVal([]cty.Value{cty.StringVal("8.8.8.8"), cty.StringVal("1.1.1.1")}), value.GetAttr("dns"))
+}
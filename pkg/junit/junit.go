@@ -0,0 +1,176 @@
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// The root of a JUnit XML report.
+type TestSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr,omitempty"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     float64     `xml:"time,attr"`
+	Suites   []TestSuite `xml:"testsuite"`
+}
+
+type TestSuite struct {
+	Name      string     `xml:"name,attr"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Errors    int        `xml:"errors,attr"`
+	Skipped   int        `xml:"skipped,attr"`
+	Time      float64    `xml:"time,attr"`
+	Timestamp string     `xml:"timestamp,attr,omitempty"`
+	Cases     []TestCase `xml:"testcase"`
+	SystemOut string     `xml:"system-out,omitempty"`
+	SystemErr string     `xml:"system-err,omitempty"`
+}
+
+type TestCase struct {
+	Name      string  `xml:"name,attr"`
+	Classname string  `xml:"classname,attr"`
+	Time      float64 `xml:"time,attr"`
+	Failure   *Result `xml:"failure,omitempty"`
+	Error     *Result `xml:"error,omitempty"`
+	Skipped   *Result `xml:"skipped,omitempty"`
+	SystemOut string  `xml:"system-out,omitempty"`
+	SystemErr string  `xml:"system-err,omitempty"`
+}
+
+// A failure, error or skipped result of a test case.
+type Result struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// Return true if the test case failed or errored.
+func (c TestCase) Failed() bool {
+	return c.Failure != nil || c.Error != nil
+}
+
+// Return the failure or error of a test case, or nil if it passed.
+func (c TestCase) Result() *Result {
+	if c.Failure != nil {
+		return c.Failure
+	}
+
+	return c.Error
+}
+
+// Set the suite's counts from its test cases.
+func (s *TestSuite) Count() {
+	s.Tests = len(s.Cases)
+	s.Failures = 0
+	s.Errors = 0
+	s.Skipped = 0
+
+	for _, c := range s.Cases {
+		switch {
+		case c.Failure != nil:
+			s.Failures++
+		case c.Error != nil:
+			s.Errors++
+		case c.Skipped != nil:
+			s.Skipped++
+		}
+	}
+}
+
+// Set the counts and time of the report from its suites.
+func (t *TestSuites) Count() {
+	t.Tests = 0
+	t.Failures = 0
+	t.Errors = 0
+	t.Skipped = 0
+	t.Time = 0
+
+	for i := range t.Suites {
+		t.Suites[i].Count()
+		t.Tests += t.Suites[i].Tests
+		t.Failures += t.Suites[i].Failures
+		t.Errors += t.Suites[i].Errors
+		t.Skipped += t.Suites[i].Skipped
+		t.Time += t.Suites[i].Time
+	}
+}
+
+// Return the names of the failed test cases in suites.
+func Failed(suites []TestSuite) []string {
+	failed := []string{}
+
+	for _, s := range suites {
+		for _, c := range s.Cases {
+			if c.Failed() {
+				failed = append(failed, c.Name)
+			}
+		}
+	}
+
+	return failed
+}
+
+// Parse a JUnit XML report, the root element can either be <testsuites> or a
+// single <testsuite>.
+func Parse(reader io.Reader) ([]TestSuite, error) {
+	decoder := xml.NewDecoder(reader)
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "testsuites":
+			suites := TestSuites{}
+			if err := decoder.DecodeElement(&suites, &start); err != nil {
+				return nil, err
+			}
+
+			for i := range suites.Suites {
+				suites.Suites[i].Count()
+			}
+
+			return suites.Suites, nil
+		case "testsuite":
+			suite := TestSuite{}
+			if err := decoder.DecodeElement(&suite, &start); err != nil {
+				return nil, err
+			}
+
+			suite.Count()
+			return []TestSuite{suite}, nil
+		default:
+			return nil, fmt.Errorf("Expected <testsuites> or <testsuite>, found <%s>.", start.Name.Local)
+		}
+	}
+}
+
+// Write a JUnit XML report.
+func Write(writer io.Writer, suites TestSuites) error {
+	suites.Count()
+
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(writer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(writer, "\n")
+	return err
+}
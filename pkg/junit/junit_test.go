@@ -0,0 +1,80 @@
+package junit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const goReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite tests="3" failures="1" time="0.015" name="github.com/justinbarrick/hone/pkg/scm">
+    <testcase classname="scm" name="TestSCM" time="0.010"></testcase>
+    <testcase classname="scm" name="TestPostComment" time="0.005">
+      <failure message="Failed" type="">comments_test.go:90: expected true</failure>
+    </testcase>
+    <testcase classname="scm" name="TestCheckRuns" time="0.000">
+      <skipped message="no token"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>`
+
+func TestParse(t *testing.T) {
+	suites, err := Parse(strings.NewReader(goReport))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(suites))
+
+	suite := suites[0]
+	assert.Equal(t, "github.com/justinbarrick/hone/pkg/scm", suite.Name)
+	assert.Equal(t, 3, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, 1, suite.Skipped)
+	assert.Equal(t, "comments_test.go:90: expected true", suite.Cases[1].Failure.Text)
+	assert.Equal(t, []string{"TestPostComment"}, Failed(suites))
+}
+
+func TestParseSingleSuite(t *testing.T) {
+	suites, err := Parse(strings.NewReader(`<testsuite name="pytest">
+  <testcase classname="test_app" name="test_index"><error message="ImportError"/></testcase>
+</testsuite>`))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, suites[0].Tests)
+	assert.Equal(t, 1, suites[0].Errors)
+	assert.Equal(t, "ImportError", suites[0].Cases[0].Result().Message)
+
+	_, err = Parse(strings.NewReader(`<html></html>`))
+	assert.NotNil(t, err)
+
+	_, err = Parse(strings.NewReader(``))
+	assert.NotNil(t, err)
+}
+
+func TestWrite(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+
+	err := Write(out, TestSuites{
+		Name: "hone",
+		Suites: []TestSuite{
+			{
+				Name: "hone",
+				Time: 1.5,
+				Cases: []TestCase{
+					{Name: "build", Classname: "hone", Time: 1.5},
+					{Name: "test", Classname: "hone", Failure: &Result{Message: "exit status 1", Text: "FAIL <nil>"}, SystemErr: "oops"},
+				},
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	assert.Contains(t, out.String(), `<?xml version="1.0" encoding="UTF-8"?>`)
+	assert.Contains(t, out.String(), `<testsuites name="hone" tests="2" failures="1" errors="0" skipped="0" time="1.5">`)
+	assert.Contains(t, out.String(), `<failure message="exit status 1">FAIL &lt;nil&gt;</failure>`)
+	assert.Contains(t, out.String(), `<system-err>oops</system-err>`)
+
+	suites, err := Parse(out)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test"}, Failed(suites))
+}
@@ -91,6 +91,37 @@ func (h *LogHandler) HandleLog(e *log.Entry) error {
 
 var logger = &log.Logger{}
 
+// The number of output lines kept for each job.
+const MaxOutputLines = 200
+
+var outputs = struct {
+	sync.Mutex
+	lines map[string][]string
+}{
+	lines: map[string][]string{},
+}
+
+// Record a line of a job's output, keeping the last MaxOutputLines.
+func recordOutput(name string, line string) {
+	outputs.Lock()
+	defer outputs.Unlock()
+
+	lines := append(outputs.lines[name], line)
+	if len(lines) > MaxOutputLines {
+		lines = lines[len(lines)-MaxOutputLines:]
+	}
+
+	outputs.lines[name] = lines
+}
+
+// Return the last lines of a job's stdout and stderr.
+func Output(name string) []string {
+	outputs.Lock()
+	defer outputs.Unlock()
+
+	return append([]string{}, outputs.lines[name]...)
+}
+
 func InitLogger(longestJob int, remoteLog io.WriteCloser) {
 	handler := multi.New(&LogHandler{
 		LongestJob: longestJob,
@@ -106,20 +137,30 @@ func InitLogger(longestJob int, remoteLog io.WriteCloser) {
 	}
 }
 func LogWriter(job node.Node) io.Writer {
+	entry := logger.WithFields(log.Fields{
+		"job":    job.GetName(),
+		"stdout": true,
+	})
+
 	return &LogIOWriter{
-		Logger: logger.WithFields(log.Fields{
-			"job":    job.GetName(),
-			"stdout": true,
-		}).Info,
+		Logger: func(line string) {
+			recordOutput(job.GetName(), line)
+			entry.Info(line)
+		},
 	}
 }
 
 func LogWriterError(job node.Node) io.Writer {
+	entry := logger.WithFields(log.Fields{
+		"job":    job.GetName(),
+		"stderr": true,
+	})
+
 	return &LogIOWriter{
-		Logger: logger.WithFields(log.Fields{
-			"job":    job.GetName(),
-			"stderr": true,
-		}).Warn,
+		Logger: func(line string) {
+			recordOutput(job.GetName(), line)
+			entry.Warn(line)
+		},
 	}
 }
 
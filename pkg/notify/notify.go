@@ -0,0 +1,231 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/events"
+)
+
+const (
+	DriverWebhook = "webhook"
+	DriverSlack   = "slack"
+	DriverSMTP    = "smtp"
+)
+
+type EventType string
+
+const (
+	EventStarted   EventType = "started"
+	EventFinished  EventType = "finished"
+	EventJobFailed EventType = "job_failed"
+)
+
+// The default message for each event.
+var DefaultTemplates = map[EventType]string{
+	EventStarted:   `Build of {{.Target}} started on {{.Branch}} ({{.Commit}}).`,
+	EventFinished:  `Build of {{.Target}} on {{.Branch}} ({{.Commit}}) finished with {{.State}} in {{.Duration}}.{{if .Failed}} Failed jobs: {{join .Failed ", "}}.{{end}}{{if .ReportURL}} Report: {{.ReportURL}}{{end}}`,
+	EventJobFailed: `Job {{.Job}} of {{.Target}} on {{.Branch}} ({{.Commit}}) failed: {{.Error}}{{if .ReportURL}} Report: {{.ReportURL}}{{end}}`,
+}
+
+const DefaultSubject = `[hone] {{.Target}} {{.State}}{{if .Job}}: {{.Job}}{{end}} on {{.Branch}}`
+
+// Requests to webhooks time out after this long.
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Something that happened in a build. State is the build's state, or the job's
+// for job_failed events.
+type Event struct {
+	Type      EventType     `json:"type"`
+	BuildID   string        `json:"buildId"`
+	Target    string        `json:"target"`
+	Branch    string        `json:"branch"`
+	Commit    string        `json:"commit"`
+	State     string        `json:"state"`
+	Duration  time.Duration `json:"duration"`
+	Job       string        `json:"job,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Failed    []string      `json:"failed,omitempty"`
+	ReportURL string        `json:"reportUrl,omitempty"`
+	LogURL    string        `json:"logUrl,omitempty"`
+}
+
+// Return the variables available to notification conditions.
+func (e Event) Conditions() map[string]interface{} {
+	return map[string]interface{}{
+		"BUILD_EVENT":   string(e.Type),
+		"BUILD_STATE":   e.State,
+		"BUILD_SUCCESS": e.State == "success",
+		"BUILD_FAILED":  len(e.Failed),
+		"JOB":           e.Job,
+	}
+}
+
+type Notifier struct {
+	Driver    string    `hcl:"driver,label"`
+	URL       *string   `hcl:"url"`
+	Condition *string   `hcl:"condition"`
+	Template  *string   `hcl:"template"`
+	Events    *[]string `hcl:"events"`
+	Address   *string   `hcl:"address"`
+	Username  *string   `hcl:"username"`
+	Password  *string   `hcl:"password"`
+	From      *string   `hcl:"from"`
+	To        *[]string `hcl:"to"`
+	Subject   *string   `hcl:"subject"`
+	env       map[string]interface{}
+}
+
+func (n *Notifier) GetURL() string {
+	if n.URL == nil {
+		return ""
+	}
+
+	return *n.URL
+}
+
+// Return the events to notify about, defaults to finished builds and failed
+// jobs.
+func (n *Notifier) GetEvents() []EventType {
+	if n.Events == nil {
+		return []EventType{EventFinished, EventJobFailed}
+	}
+
+	eventTypes := []EventType{}
+	for _, event := range *n.Events {
+		eventTypes = append(eventTypes, EventType(event))
+	}
+
+	return eventTypes
+}
+
+func (n *Notifier) Validate() error {
+	for _, event := range n.GetEvents() {
+		if DefaultTemplates[event] == "" {
+			return fmt.Errorf("Unknown event %s, must be one of started, finished or job_failed.", event)
+		}
+	}
+
+	switch n.Driver {
+	case DriverWebhook, DriverSlack:
+		if n.GetURL() == "" {
+			return fmt.Errorf("The %s driver requires a url.", n.Driver)
+		}
+	case DriverSMTP:
+		if n.Address == nil || n.From == nil || n.To == nil || len(*n.To) == 0 {
+			return errors.New("The smtp driver requires an address, from and to.")
+		}
+	default:
+		return fmt.Errorf("Unknown notification driver %s, must be one of webhook, slack or smtp.", n.Driver)
+	}
+
+	return nil
+}
+
+// Render a template with an event.
+func render(name string, text string, event Event) (string, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing %s template: %s", name, err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	if err := tmpl.Execute(out, event); err != nil {
+		return "", fmt.Errorf("Error rendering %s template: %s", name, err)
+	}
+
+	return out.String(), nil
+}
+
+// Render the message for an event with the configured template.
+func (n *Notifier) Message(event Event) (string, error) {
+	text := DefaultTemplates[event.Type]
+	if n.Template != nil {
+		text = *n.Template
+	}
+
+	return render("message", text, event)
+}
+
+// Return true if the notifier is subscribed to the event and its condition is
+// met.
+func (n *Notifier) Match(event Event) (bool, error) {
+	subscribed := false
+	for _, eventType := range n.GetEvents() {
+		if eventType == event.Type {
+			subscribed = true
+		}
+	}
+
+	if !subscribed {
+		return false, nil
+	}
+
+	env := map[string]interface{}{}
+	for key, value := range n.env {
+		env[key] = value
+	}
+	for key, value := range event.Conditions() {
+		env[key] = value
+	}
+
+	return events.YQLMatch(n.Condition, env)
+}
+
+// Send an event if the notifier is subscribed to it and its condition is met.
+func (n *Notifier) Notify(event Event) error {
+	match, err := n.Match(event)
+	if err != nil || !match {
+		return err
+	}
+
+	message, err := n.Message(event)
+	if err != nil {
+		return err
+	}
+
+	switch n.Driver {
+	case DriverWebhook:
+		return n.postWebhook(event, message)
+	case DriverSlack:
+		return n.postSlack(message)
+	case DriverSMTP:
+		return n.sendMail(event, message)
+	}
+
+	return fmt.Errorf("Unknown notification driver %s.", n.Driver)
+}
+
+// Validate the notifiers and set the variables available to their conditions.
+func Init(notifiers []*Notifier, env map[string]interface{}) ([]*Notifier, error) {
+	for _, n := range notifiers {
+		if err := n.Validate(); err != nil {
+			return nil, err
+		}
+
+		n.env = env
+	}
+
+	return notifiers, nil
+}
+
+// Send an event to every notifier, returning the first error. A failing
+// notifier does not stop the others.
+func Notify(notifiers []*Notifier, event Event) error {
+	var firstErr error
+
+	for _, n := range notifiers {
+		if err := n.Notify(event); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("Error sending %s notification: %s", n.Driver, err)
+		}
+	}
+
+	return firstErr
+}
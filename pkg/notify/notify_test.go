@@ -0,0 +1,231 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func str(s string) *string {
+	return &s
+}
+
+var failed = Event{
+	Type:     EventFinished,
+	BuildID:  "build-1",
+	Target:   "all",
+	Branch:   "master",
+	Commit:   "729ffe88",
+	State:    "failure",
+	Duration: 90 * time.Second,
+	Failed:   []string{"test", "lint"},
+}
+
+// Start an HTTP server that records request bodies.
+func recordRequests(status int) (*httptest.Server, chan []byte) {
+	bodies := make(chan []byte, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- body
+		w.WriteHeader(status)
+	}))
+
+	return server, bodies
+}
+
+func TestMessage(t *testing.T) {
+	n := &Notifier{Driver: DriverSlack}
+
+	message, err := n.Message(failed)
+	assert.Nil(t, err)
+	assert.Equal(t, "Build of all on master (729ffe88) finished with failure in 1m30s. Failed jobs: test, lint.", message)
+
+	n.Template = str(`{{.Type}}: {{.State}}`)
+	message, err = n.Message(failed)
+	assert.Nil(t, err)
+	assert.Equal(t, "finished: failure", message)
+
+	n.Template = str(`{{.Nope}}`)
+	_, err = n.Message(failed)
+	assert.NotNil(t, err)
+}
+
+func TestMatch(t *testing.T) {
+	n := &Notifier{
+		Driver:    DriverWebhook,
+		URL:       str("http://localhost"),
+		Condition: str("BUILD_SUCCESS=false and GIT_BRANCH='master'"),
+	}
+	_, err := Init([]*Notifier{n}, map[string]interface{}{"GIT_BRANCH": "master"})
+	assert.Nil(t, err)
+
+	match, err := n.Match(failed)
+	assert.Nil(t, err)
+	assert.True(t, match)
+
+	succeeded := failed
+	succeeded.State = "success"
+	match, err = n.Match(succeeded)
+	assert.Nil(t, err)
+	assert.False(t, match)
+
+	started := failed
+	started.Type = EventStarted
+	match, err = n.Match(started)
+	assert.Nil(t, err)
+	assert.False(t, match)
+
+	n.Events = &[]string{"started"}
+	match, err = n.Match(started)
+	assert.Nil(t, err)
+	assert.True(t, match)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NotNil(t, (&Notifier{Driver: DriverWebhook}).Validate())
+	assert.NotNil(t, (&Notifier{Driver: DriverSMTP, Address: str("localhost:25")}).Validate())
+	assert.NotNil(t, (&Notifier{Driver: "irc"}).Validate())
+	assert.NotNil(t, (&Notifier{Driver: DriverSlack, URL: str("http://localhost"), Events: &[]string{"merged"}}).Validate())
+	assert.Nil(t, (&Notifier{Driver: DriverSMTP, Address: str("localhost:25"), From: str("hone@example.com"), To: &[]string{"dev@example.com"}}).Validate())
+
+	_, err := Init([]*Notifier{{Driver: "irc"}}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestWebhook(t *testing.T) {
+	server, bodies := recordRequests(http.StatusOK)
+	defer server.Close()
+
+	n := &Notifier{Driver: DriverWebhook, URL: str(server.URL)}
+	assert.Nil(t, n.Notify(failed))
+
+	payload := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(<-bodies, &payload))
+	assert.Equal(t, "finished", payload["type"])
+	assert.Equal(t, "failure", payload["state"])
+	assert.Equal(t, []interface{}{"test", "lint"}, payload["failed"])
+	assert.Contains(t, payload["message"], "Failed jobs: test, lint.")
+}
+
+func TestSlack(t *testing.T) {
+	server, bodies := recordRequests(http.StatusOK)
+	defer server.Close()
+
+	jobFailed := failed
+	jobFailed.Type = EventJobFailed
+	jobFailed.Job = "test"
+	jobFailed.Error = "exit status 1"
+
+	n := &Notifier{Driver: DriverSlack, URL: str(server.URL)}
+	assert.Nil(t, Notify([]*Notifier{n}, jobFailed))
+	assert.Equal(t, `{"text":"Job test of all on master (729ffe88) failed: exit status 1"}`, string(<-bodies))
+}
+
+func TestWebhookError(t *testing.T) {
+	server, _ := recordRequests(http.StatusNotFound)
+	defer server.Close()
+
+	skipped := &Notifier{Driver: DriverSlack, URL: str(server.URL), Events: &[]string{"started"}}
+	n := &Notifier{Driver: DriverSlack, URL: str(server.URL)}
+
+	err := Notify([]*Notifier{skipped, n}, failed)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Error sending slack notification")
+	assert.Contains(t, err.Error(), "404 Not Found")
+	assert.NotContains(t, err.Error(), server.URL)
+
+	secret := "http://127.0.0.1:1/services/T000/B000/SECRET"
+	err = (&Notifier{Driver: DriverSlack, URL: &secret}).Notify(failed)
+	assert.NotNil(t, err)
+	assert.NotContains(t, err.Error(), "SECRET")
+}
+
+// A minimal SMTP server that accepts a single message and sends its data on the
+// returned channel.
+func smtpServer(t *testing.T) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	messages := make(chan string, 1)
+
+	go func() {
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) {
+			conn.Write([]byte(line + "\r\n"))
+		}
+
+		reply("220 localhost ESMTP")
+
+		data := []string{}
+		inData := false
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+
+			if inData {
+				if line == "." {
+					inData = false
+					messages <- strings.Join(data, "\n")
+					reply("250 OK")
+				} else {
+					data = append(data, line)
+				}
+				continue
+			}
+
+			switch strings.ToUpper(strings.SplitN(line, " ", 2)[0]) {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "DATA":
+				inData = true
+				reply("354 Go ahead")
+			case "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), messages
+}
+
+func TestSMTP(t *testing.T) {
+	address, messages := smtpServer(t)
+
+	n := &Notifier{
+		Driver:  DriverSMTP,
+		Address: str(address),
+		From:    str("hone@example.com"),
+		To:      &[]string{"dev@example.com", "ops@example.com"},
+	}
+	assert.Nil(t, n.Notify(failed))
+
+	message := <-messages
+	assert.Contains(t, message, "From: hone@example.com")
+	assert.Contains(t, message, "To: dev@example.com, ops@example.com")
+	assert.Contains(t, message, "Subject: [hone] all failure on master")
+	assert.Contains(t, message, "Build of all on master (729ffe88) finished with failure in 1m30s.")
+}
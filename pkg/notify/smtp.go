@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Send the message by email. If a username is set, the server must support
+// PLAIN authentication over TLS, or be on localhost.
+func (n *Notifier) sendMail(event Event, message string) error {
+	subjectTemplate := DefaultSubject
+	if n.Subject != nil {
+		subjectTemplate = *n.Subject
+	}
+
+	subject, err := render("subject", subjectTemplate, event)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if n.Username != nil && *n.Username != "" {
+		host, _, err := net.SplitHostPort(*n.Address)
+		if err != nil {
+			return err
+		}
+
+		password := ""
+		if n.Password != nil {
+			password = *n.Password
+		}
+
+		auth = smtp.PlainAuth("", *n.Username, password, host)
+	}
+
+	body := bytes.NewBuffer(nil)
+	fmt.Fprintf(body, "From: %s\r\n", *n.From)
+	fmt.Fprintf(body, "To: %s\r\n", strings.Join(*n.To, ", "))
+	fmt.Fprintf(body, "Subject: %s\r\n", strings.Replace(subject, "\n", " ", -1))
+	fmt.Fprintf(body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(body, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(body, "%s\r\n", strings.Replace(message, "\n", "\r\n", -1))
+
+	return smtp.SendMail(*n.Address, auth, *n.From, *n.To, body.Bytes())
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+)
+
+// The body posted to generic webhooks: the event along with its message.
+type webhookPayload struct {
+	Event
+	Message string `json:"message"`
+}
+
+// The body posted to Slack-compatible incoming webhooks.
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// POST a JSON body to address, any status other than 2xx is an error. Webhook
+// URLs often contain a secret, so they are left out of errors.
+func postJSON(address string, body interface{}) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Post(address, "application/json", bytes.NewReader(encoded))
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			return fmt.Errorf("Webhook request failed: %s", urlErr.Err)
+		}
+		return fmt.Errorf("Webhook request failed: %s", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("Webhook returned %s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+
+	return nil
+}
+
+func (n *Notifier) postWebhook(event Event, message string) error {
+	return postJSON(n.GetURL(), webhookPayload{
+		Event:   event,
+		Message: message,
+	})
+}
+
+func (n *Notifier) postSlack(message string) error {
+	return postJSON(n.GetURL(), slackPayload{
+		Text: message,
+	})
+}
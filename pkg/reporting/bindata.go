@@ -35,7 +35,7 @@ func bindata_read(data, name string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-var _templates_index_html = "\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xac\x54\xc1\x6e\xdb\x30\x0c\xbd\xf7\x2b\x88\x5c\xea\x00\xab\x85\x5d\x7a\x68\x65\xa3\x5d\x37\x0c\x1b\xba\x15\x48\xd1\x7b\x1d\x89\x8b\xd5\xd9\xa2\x20\xd1\x41\x83\xc0\xff\x3e\xd8\x72\x52\x2b\xd8\xa1\x87\x9e\x2c\xf2\x3d\x3d\x3e\x51\x94\x65\xcd\x6d\x53\x9e\x01\xc8\x1a\x2b\x3d\x2c\x00\x24\x1b\x6e\xb0\x5c\xa1\x23\xcf\x52\xc4\x28\x22\x41\x79\xe3\x18\x82\x57\xc5\xa2\x66\x76\xe1\x4a\x88\xce\xba\xbf\x9b\x5c\x51\x2b\x3c\x56\x8a\x6f\x3e\x5f\x8a\xae\xd5\x31\xc8\x35\x6e\xb1\x21\xd7\xa2\xe5\xfc\x25\x2c\x40\x79\x0a\x81\xbc\xd9\x18\x5b\x4a\x11\xe5\xde\xaf\x7d\xa1\xa9\x4d\xf4\x87\xc4\xc7\xd5\x58\x57\x6b\x6c\x2e\x02\x57\x56\x57\x0d\x59\xbc\xb9\x8c\xa9\xbc\x35\x76\x50\x9e\xa9\x25\x72\xbc\x73\x58\x2c\x18\x5f\x39\xf2\x17\xb1\x1a\x80\x6a\xaa\x10\xe0\xd6\x39\xc0\x57\x46\xab\x03\xac\xc6\xae\xdc\x51\xeb\xc8\xa2\x65\xd8\x4f\x4c\x00\x45\x36\xb0\xef\x14\x93\xcf\x9c\x27\x17\x96\x33\x10\x20\x74\x0e\x0f\xc0\xf5\xd9\x0c\xe0\xda\x84\xbc\xa1\xcd\x93\x6f\xa0\x80\xe7\xfd\x1e\xf2\x7b\xda\x3c\xad\xee\xa1\xef\x9f\x4f\x79\x7e\xbc\xd3\x19\x35\x5e\xf2\xcf\xc7\x87\xdf\x23\xfd\x94\x1f\xb8\x62\x84\x02\xf6\x7d\x52\xf3\x0f\xb2\xaa\xb3\x54\x71\x39\xc3\x73\xae\xd1\x66\x90\x79\x0c\x8e\x6c\xc0\x25\x14\x65\x72\x1a\x00\x8f\xdc\x79\x0b\x07\x46\xfe\x12\xc8\x66\x73\x8d\x7e\x79\x50\x19\xa0\xff\x28\x44\x83\xc8\x8f\x83\xc7\x48\x4a\xb6\x5f\x1f\xa3\xfe\xcd\xbb\x47\xab\xd1\x67\x69\x6f\x27\x2f\x59\x22\x2f\xb5\xd9\x96\x49\x06\x40\xba\xf2\x8e\xda\xd6\xf0\x15\xec\xdf\xfa\x93\x7f\x37\x1c\xd3\xbd\x14\x2e\xdd\x23\xc5\x89\x4c\x62\x6b\xfe\x9d\x4f\xaa\x14\xf1\x35\x0e\xcb\x35\xe9\xdd\x34\xbd\xda\x6c\xc1\xe8\xe2\xdc\x13\xf1\x79\x39\x49\xbf\x6f\x12\xc7\xb9\xfb\xfa\xf0\x2b\x9f\x1a\x70\x34\x21\x6f\x9d\x13\xe5\xa7\x63\xac\x49\x75\xe3\x4b\xda\x20\x7f\x6b\x70\x58\x7e\xd9\xfd\xd0\x59\x2c\x7a\xe8\xf0\x74\x8a\xd4\x73\x74\x2a\x45\xfc\xa5\xfc\x0b\x00\x00\xff\xff\xcb\x85\xb8\x21\x5a\x04\x00\x00"
+var _templates_index_html = "\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x58\xdd\x73\xa3\x38\x12\x7f\xdf\xbf\xa2\x8f\xcc\xbe\x19\xfc\x91\x64\x26\x21\x98\xaa\xdb\x64\x3e\x32\x35\x1f\x5b\x93\x6c\xdd\xcd\xa3\x0c\x8d\x61\x47\x20\x4e\x92\x1d\xe7\x28\xff\xef\x57\x12\x12\x08\x1b\x67\xe7\xee\x5e\x12\x24\x75\xff\xba\xfb\xa7\x56\xb7\xe4\xe8\x6f\x77\x5f\x6f\x1f\xbf\xff\xfe\x16\x72\x59\xd2\xf8\x97\xa8\xfd\x07\x10\xe5\x48\x52\xf5\x01\x10\x95\x28\x09\x24\x39\xe1\x02\xe5\xd2\xdb\xc8\xcc\xbf\xf2\xcc\x92\x2c\x24\xc5\xb8\x69\xa0\xc8\x20\x78\x24\x7c\x8d\x12\xf6\xfb\xa6\x71\x06\xe0\x43\xd3\x00\x56\xa9\x59\x78\x5f\xc8\x5b\x56\x96\x85\x12\x8c\xa6\x2d\x40\x0b\x26\xe4\xb3\xfd\x06\x58\xb1\xf4\x19\x1a\xc8\x58\x25\xfd\x8c\x94\x05\x7d\x0e\xc1\x27\x75\x4d\xd1\x17\xcf\x42\x62\x39\x01\xef\x01\xd7\x0c\xe1\x8f\x7b\x6f\x02\x1f\x90\x6e\x51\x16\x09\x99\xc0\xdf\x79\x41\xe8\x04\x04\xa9\x84\x2f\x90\x17\xd9\x0d\x94\x84\xaf\x8b\x2a\x84\x05\x96\x37\x90\x30\xca\x78\x08\x67\x8b\x8b\xc5\xf5\x02\x6f\x60\x6f\x2c\xe6\x73\x6b\x4f\x14\xff\xc6\x10\xe6\xc1\x25\x96\xce\xf2\xe2\x70\x59\xa3\xb5\xd0\xbe\x64\xb5\x81\x5f\x31\x9e\x22\xf7\x57\x4c\x4a\x56\x86\x30\xaf\x77\x20\x18\x2d\x52\x38\xc3\x39\x5e\xe0\x55\x8f\x28\xc9\x8a\x22\x34\x56\x23\x61\x94\x92\x5a\x60\x08\xf6\xeb\x06\x9e\x8a\x54\xe6\x21\xcc\x67\xb3\x5f\x1d\xbd\x7c\x02\x32\x85\x06\x24\xee\xa4\x4f\x68\xb1\xae\x42\xa0\x98\xc9\x1b\xa8\x49\x9a\x16\xd5\x3a\x84\x8b\x7a\x07\x57\xf5\xee\x67\xdc\xd9\x22\x57\xc4\x51\x8b\x24\x59\xdd\xdb\x4a\x58\x8a\x13\xa8\x39\x1e\xee\xc5\xc3\xbb\xcf\xac\x62\xfe\x37\x5c\x6f\x28\xe1\x13\xb8\x65\x95\x60\x94\x88\x09\x7c\xc6\x8a\xb2\x09\x94\xac\x62\xa2\x26\x09\xde\x0c\x58\x5b\xd4\xbb\x1e\xbd\xc5\x5d\x91\xe4\xc7\x9a\xb3\x4d\x95\x86\x70\x96\xbd\xce\xae\x32\xe2\x44\xa2\xa3\x60\x5b\xe4\x19\x65\x4f\xfe\x2e\x04\xb2\x91\xac\xdf\xd2\x59\x8f\x16\x70\x52\x4d\x20\x10\x9b\x24\x41\x21\xa0\xe9\x77\x7a\x71\xf5\xfa\x9c\x38\x82\x09\x49\x72\x4c\x95\xec\x8f\xa2\xae\x31\x75\x64\x5f\x93\x37\xe7\x6f\x52\x47\x36\x23\x05\xd5\xb2\xea\x63\xc3\x71\x02\x01\x72\xce\xf8\x04\x82\x84\x54\x09\xd2\x81\x7a\xb2\x5a\x5c\x9c\xcf\x1d\xf5\x35\xa9\xa4\x84\x06\x6a\x26\x0a\x59\xb0\x2a\x04\x8e\x94\xc8\x62\x8b\x37\x90\x63\xb1\xce\x65\x08\x73\x1d\xe3\x28\x0d\x07\x38\x69\xb1\x1d\x60\x91\x95\x60\x74\x23\x5d\x2c\x9d\x2a\x65\x51\xf9\x26\x77\x06\x84\x07\x2b\xc2\x7d\x4e\xaa\x43\xd6\xcf\x2f\xd2\xd9\xa5\x93\x9a\x5a\xae\x63\x49\x0d\x7a\xa6\x06\x8a\xd7\x97\xd7\x29\xb9\x3c\x50\x54\x4c\x1d\x8b\xa6\x6f\xce\xc9\xc5\x75\x2f\x2a\xb6\x6b\xe0\x98\x28\x72\x84\xe4\xec\x07\x5a\x8f\x1d\xfa\x94\x4c\x50\xb1\x14\x8d\xd3\x59\x41\x69\x08\x67\x69\x92\x65\x78\x71\x63\xf4\x46\xfc\xef\xf5\x6c\x10\xfd\x4c\x1f\x89\x41\xb3\x5c\x77\x68\x87\x41\xf5\xba\x5d\x64\x56\x35\x4b\x13\x9c\x39\xaa\x63\x41\xd2\xa2\x42\x68\x8e\xe1\x5f\x8c\x5a\x9d\x6d\x68\x4e\x1f\x9d\x40\xc8\x14\x39\x77\x52\x6f\x75\x3e\x4f\x17\xee\x1e\x52\xdc\x22\xf5\x75\xb2\x8e\x64\xa8\x3e\x94\x4f\x26\x6b\x56\x8c\x3a\x39\x9f\xa2\x24\x05\x55\x27\xc8\x9e\x32\x55\x4e\x66\x47\x02\x81\x44\x21\x3b\x29\x5f\x95\xa0\x10\xe6\x6e\xd1\x14\x9b\xb2\x24\x5c\x15\xf2\x64\xc3\x85\xb2\x5f\xb3\xa2\x92\xc8\xad\x48\x34\xed\xaa\x7e\x34\x6d\x7b\x8e\xfa\x54\xd5\xdf\x74\x85\x7c\x6e\x5b\xc2\x6f\x9b\x82\xa6\x10\x89\x9a\x54\x90\x50\x22\xc4\xd2\x53\x9d\xe6\x41\x12\x89\xb0\xdf\x7b\xb6\x0f\xd9\x09\x77\x51\xb5\x20\x2a\x50\xf7\xa9\x07\x53\x20\xf6\x7b\x53\x2a\xec\xe2\x7e\x6f\xce\x78\xd7\xb0\xa2\xa9\xb2\x76\xd4\xe0\x20\x63\x1c\x22\x55\x1b\xe3\x41\xaf\x8b\xa6\x76\xae\xed\x77\x26\x44\x15\x82\xe9\x97\x64\xd5\xb7\xb8\x48\xf2\x38\x92\x79\xdc\xb6\xc3\x68\x2a\xf3\x38\x92\x69\xdc\xe3\x0e\x5b\xa5\x9e\x8e\xa6\x4a\x62\x2a\xb9\x05\x69\x1a\x5f\x07\xf5\xbe\x90\xbf\x71\x52\x25\xb9\x35\xdb\xe3\xb7\xf3\x1d\x7e\xd3\x0c\xa5\x47\x21\x1d\xff\x07\x36\x1e\xc9\xfa\xd8\xc0\x23\x59\x1f\xa2\xb7\x72\x7f\x0d\xdd\xf9\xa8\xf6\x76\x84\x02\xbd\xe7\xf7\x77\x2f\x10\x60\x11\x1e\x24\xe1\x12\x7b\x0c\xb3\xfb\x5c\x3e\x16\x25\x06\xef\x18\x2f\x89\x04\x6f\x31\x9b\xbd\xf6\x67\x73\x7f\xb6\x80\xf9\x65\x38\xbb\x08\x67\x97\xf0\xf9\xe1\xd1\x1b\x73\xd6\x22\xdf\x6d\x38\x51\xa5\xdb\x85\xd6\x45\x0d\x02\xbb\x74\x2a\x56\xc5\xda\xed\x7d\x7b\xf3\x69\xbf\xdb\x88\xfe\xf8\xf6\xe9\x98\x83\xdb\xfb\xce\x42\x44\x20\xe7\x98\xb5\x09\x3e\x54\xd2\x69\x1e\xdc\xde\x07\xbf\x73\xb6\x2d\x52\xe4\xda\x36\x89\x5f\xe0\xba\x69\xc6\xf7\xf3\x13\x5b\x8f\x3a\xf2\x89\xad\xc5\xb8\x2b\x9d\x86\x17\x0f\x86\x7f\xe1\x80\x33\xa1\xec\x7e\xc3\x9a\x71\xf9\xf1\xe1\xeb\x97\x63\xdb\x77\x44\x92\x71\xdb\x03\x2d\x2f\xe6\x7a\x18\xfc\x29\x58\xf5\x33\xd6\xa3\xa9\xbe\x6e\xd9\x83\x98\x2f\xe2\x8f\x6c\x25\xa2\x69\xbe\x78\xf1\x6c\x7e\x64\x2b\xe3\x8d\xce\x30\xb9\xb1\xc4\xe4\xf1\xad\xee\x2c\xdd\xf0\x20\x4b\xf2\xf8\x11\x85\xec\xa5\x3f\x10\x61\x8f\x60\x1e\x7f\xdd\xc8\x7a\x63\x17\x0f\x7c\xe6\xa4\x5a\x23\x04\xca\xbb\x21\x3b\xe6\x13\x60\xc0\xcc\xd9\x9f\x6c\xe5\x2b\x7a\xbe\x90\xb2\xab\x82\x76\xd0\x11\xe3\xea\x1e\xd6\xce\x8d\xe8\xd4\xba\xe1\x91\x92\x2d\x81\x6d\xd0\xb0\xdf\x3f\xa3\x53\x36\x2b\xd6\xa5\xd8\xa8\xe6\x89\xd3\x32\x66\x40\xb3\x16\x3c\x6c\x0a\x89\xc2\xd4\xf0\x76\x4a\xff\x55\xb5\x57\x35\x1d\x61\xa4\xdf\xe9\x7e\x6c\x97\x26\xc3\xe6\xa0\x8a\x39\xa6\x9a\x10\x8a\xd5\xa1\x30\xb4\xcb\x7d\x85\xef\xce\x8a\xe3\x86\xb9\x2a\x28\xe8\xde\x93\x7e\x16\xcc\x5d\xc2\xd5\x36\x94\xf8\x2f\x30\xd2\x97\x37\x95\x15\x07\xb5\xed\x90\x94\x36\x1f\x5e\x65\x05\xc5\x09\xbc\xca\x95\x42\xb8\x84\xa0\x4d\x21\xa5\xaf\x79\xea\x20\xb5\x60\x0f\x19\xf6\x6d\xea\x55\x3e\x34\xb6\xe2\xf1\xa8\x8f\xff\xd5\x21\x52\xc5\x55\xdd\x6f\x4e\x1e\xa4\x9f\x4e\x69\xd0\x17\x81\xa5\x67\xaf\x43\x97\xbf\x7a\xff\x4f\x96\xf7\x03\x80\x48\x5d\x9b\x4d\x52\xe8\xfb\xb8\x17\xbb\x53\xea\x7a\x3b\x3c\x0c\xd6\x97\xf6\x46\xd3\x34\x50\x23\x4f\xb0\x92\x10\x7c\xcd\x32\xa1\xaf\x00\xdd\xdb\xcc\x5d\xfd\x87\xf2\x5d\x03\xe8\x77\xed\xd2\x73\xbc\x0c\x61\xf4\x20\x78\x71\x34\x4d\x8b\xad\xf9\xdb\x47\xf0\xbf\x6e\xc8\x1d\xd6\x58\xa5\x58\x25\x05\x0e\xaa\x9b\xba\x88\x6a\x8f\x5b\xa7\xde\x73\x52\xe7\x8e\xbf\xed\x53\xc4\x5d\xfb\xa0\x67\xf4\xe2\xae\xa4\x95\x58\x7a\xb9\x94\x75\x38\x9d\x3e\x3d\x3d\x05\x4f\xe7\x01\xe3\xeb\xe9\x62\x36\x9b\x4d\xc5\x76\xed\x8d\xec\x76\x8b\xf2\x36\x5d\xa3\xbb\xe9\x2a\x57\x60\x37\x6f\x0d\xfd\x73\xae\xe1\x9f\xcd\xf0\x7b\x3b\xdc\x2d\xcc\xea\x42\x0f\x9f\xcd\xf0\xfb\xc2\xb0\xa5\x20\x4e\xd0\x31\xe2\xc0\x17\x96\x0e\x1c\x38\x9d\x51\x46\x02\x20\xd2\xaf\x12\x93\x1c\xfa\xf1\x71\x90\x1d\x3b\xe3\xa0\x1e\x3c\x1b\xf7\xf4\xa0\x67\xf8\x95\x63\x7f\x94\x66\x57\xc0\xe1\x9a\xef\x96\xde\x85\x0a\x53\x39\xe1\xf8\xa4\xdf\x03\xa7\x0d\xa7\xbb\xa5\x77\xe5\x41\xfa\xbc\xf4\xe6\xd7\x07\xa7\x43\xa9\x5a\x24\xd5\x11\xc6\xb9\x8b\xd4\x46\xda\x6c\xc9\x17\xe6\x12\xd0\x25\x90\x43\xac\xdb\x9a\x22\xfb\x56\x28\xd2\xa5\x77\x48\x69\x5b\x4b\xf1\x5f\x1d\x79\xb6\x24\xab\xd2\xc9\x6a\xac\xba\xfa\xd3\xb9\x67\x1e\x0c\xf1\xe8\x2d\xdf\xe9\x54\x5d\x70\x4a\x2e\x0e\x61\x20\x62\x6a\xf8\x5b\xfd\xf2\xb1\xbf\x40\x75\x43\xa7\xe8\x59\x6b\xc7\xc9\x33\xec\x15\xd6\x3b\x1b\xac\xf1\x4b\x75\x22\x4f\x07\x62\x11\x4e\x45\xe0\xb4\xa2\x7e\x5f\x6c\xe3\x79\x2a\x64\xae\xae\x37\x62\x43\xcd\xaf\x67\xaa\x03\x7d\x46\x21\xc8\xba\xab\x1c\xce\xb8\x0b\xe0\x74\x24\x00\x51\xcd\x71\x1c\x3c\x78\x54\xa9\x34\xd0\x56\xb2\x46\x33\x9a\x9a\x20\x4f\xa4\x49\x8f\x6c\x98\x52\x79\xa2\x40\x8e\x37\x8c\x23\x29\xd5\x4e\xb7\xef\x50\x35\xf7\x49\x7d\x75\x7b\xd8\x47\x64\xb8\xf8\xa5\x6f\x9f\x5f\x18\x30\xdd\xe1\x82\x31\x37\x0f\x9c\x1c\xb8\x18\x4d\xdb\xc7\x63\x34\xcd\x65\x49\xe3\x5f\xfe\x33\x00\xdd\x62\x72\xb5\xe3\x14\x00\x00"
 
 func templates_index_html() ([]byte, error) {
 	return bindata_read(
@@ -0,0 +1,292 @@
+package reporting
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/cache"
+)
+
+// A build in a branch's history index.
+type HistoryEntry struct {
+	BuildID   string
+	GitBranch string
+	GitCommit string
+	Target    string
+	StartTime time.Time
+	EndTime   time.Time
+	State     string
+	ReportURL string
+	Jobs      []HistoryJob
+}
+
+// A job in a branch's history index, Status is one of failed, skipped, cached
+// or ran.
+type HistoryJob struct {
+	Name     string
+	Hash     string
+	Status   string
+	Duration time.Duration
+}
+
+func (h HistoryEntry) Duration() time.Duration {
+	return h.EndTime.Sub(h.StartTime)
+}
+
+// The number of builds kept in a branch's history index, older builds are
+// dropped so that the index does not grow without bound.
+var maxHistory = 200
+
+// Return the path of a branch's history index in the history namespace.
+func historyPath(branch string) string {
+	if branch == "" {
+		branch = "HEAD"
+	}
+
+	return fmt.Sprintf("%s.jsonl", branch)
+}
+
+// Load the history index of a branch, oldest build first. A branch without an
+// index has no history.
+func LoadHistory(c cache.Cache, branch string) ([]HistoryEntry, error) {
+	reader, err := c.Reader("history", historyPath(branch))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []HistoryEntry{}, nil
+		}
+		return nil, err
+	}
+	defer reader.Close()
+
+	entries := []HistoryEntry{}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		entry := HistoryEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("Error decoding history of %s: %s", branch, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Append a build to the history index of its branch, returning the history
+// including the new build. Existing entries are never modified, but since
+// caches cannot append to files the index is rewritten, so concurrent builds on
+// the same branch can lose an entry. Only the latest maxHistory builds are kept.
+func AppendHistory(c cache.Cache, entry HistoryEntry) ([]HistoryEntry, error) {
+	entries, err := LoadHistory(c, entry.GitBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = append(entries, entry)
+	if len(entries) > maxHistory {
+		entries = entries[len(entries)-maxHistory:]
+	}
+
+	writer, _, err := c.Writer("history", historyPath(entry.GitBranch))
+	if err != nil {
+		return nil, err
+	}
+
+	encoder := json.NewEncoder(writer)
+	for _, e := range entries {
+		if err := encoder.Encode(e); err != nil {
+			writer.Close()
+			return nil, err
+		}
+	}
+
+	return entries, writer.Close()
+}
+
+// Return the build's history index entry, reportUrl is the location of its
+// report.json.
+func (r *Report) HistoryEntry(reportUrl string) HistoryEntry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry := HistoryEntry{
+		BuildID:   r.BuildID,
+		GitBranch: r.GitBranch,
+		GitCommit: r.GitCommit,
+		Target:    r.Target,
+		StartTime: r.StartTime,
+		EndTime:   r.EndTime,
+		State:     r.State,
+		ReportURL: reportUrl,
+		Jobs:      []HistoryJob{},
+	}
+
+	for _, j := range r.Jobs {
+		if j.Aggregate {
+			continue
+		}
+
+		entry.Jobs = append(entry.Jobs, HistoryJob{
+			Name:     j.GetName(),
+			Hash:     j.Hash,
+			Status:   jobStatus(j),
+			Duration: j.EndTime.Sub(j.StartTime),
+		})
+	}
+
+	return entry
+}
+
+// Return the entry of a build in a history by its ID or a unique prefix of it.
+func FindBuild(entries []HistoryEntry, id string) (HistoryEntry, error) {
+	found := []HistoryEntry{}
+
+	for _, entry := range entries {
+		if entry.BuildID == id {
+			return entry, nil
+		}
+
+		if strings.HasPrefix(entry.BuildID, id) {
+			found = append(found, entry)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return HistoryEntry{}, fmt.Errorf("Build %s not found.", id)
+	case 1:
+		return found[0], nil
+	}
+
+	return HistoryEntry{}, fmt.Errorf("Build ID %s is ambiguous.", id)
+}
+
+// A job that has both passed and failed with the same hash.
+type FlakyJob struct {
+	Name   string
+	Hash   string
+	Passed int
+	Failed int
+}
+
+// Return the jobs in a history that have both passed and failed with the same
+// hash, that is with the same inputs and configuration. Cached and skipped runs
+// are not counted.
+func FlakyJobs(entries []HistoryEntry) []FlakyJob {
+	byKey := map[string]*FlakyJob{}
+	keys := []string{}
+
+	for _, entry := range entries {
+		for _, j := range entry.Jobs {
+			if j.Hash == "" || (j.Status != "ran" && j.Status != "failed") {
+				continue
+			}
+
+			key := j.Name + "/" + j.Hash
+			flaky := byKey[key]
+			if flaky == nil {
+				flaky = &FlakyJob{Name: j.Name, Hash: j.Hash}
+				byKey[key] = flaky
+				keys = append(keys, key)
+			}
+
+			if j.Status == "failed" {
+				flaky.Failed++
+			} else {
+				flaky.Passed++
+			}
+		}
+	}
+
+	flaky := []FlakyJob{}
+	for _, key := range keys {
+		if byKey[key].Passed > 0 && byKey[key].Failed > 0 {
+			flaky = append(flaky, *byKey[key])
+		}
+	}
+
+	sort.SliceStable(flaky, func(i, j int) bool {
+		return flaky[i].Name < flaky[j].Name
+	})
+
+	return flaky
+}
+
+// A job's outcome in two builds, Before or After is nil if the job was not in
+// that build.
+type JobDiff struct {
+	Name   string
+	Before *JobRecord
+	After  *JobRecord
+}
+
+// Return true if the job's status differs between the builds, including whether
+// it was cached.
+func (d JobDiff) Changed() bool {
+	if d.Before == nil || d.After == nil {
+		return true
+	}
+
+	return d.Before.Status() != d.After.Status()
+}
+
+// Return the change in the job's duration.
+func (d JobDiff) Delta() time.Duration {
+	var before, after time.Duration
+
+	if d.Before != nil {
+		before = d.Before.Duration()
+	}
+
+	if d.After != nil {
+		after = d.After.Duration()
+	}
+
+	return after - before
+}
+
+// Compare the jobs of two builds, in the order they ran in the second build
+// followed by jobs that were only in the first.
+func DiffBuilds(before, after BuildRecord) []JobDiff {
+	diffs := []JobDiff{}
+	seen := map[string]bool{}
+
+	find := func(build BuildRecord, name string) *JobRecord {
+		for i := range build.Jobs {
+			if build.Jobs[i].Name == name {
+				return &build.Jobs[i]
+			}
+		}
+		return nil
+	}
+
+	for _, build := range []BuildRecord{after, before} {
+		for _, j := range build.Jobs {
+			if j.Aggregate || seen[j.Name] {
+				continue
+			}
+			seen[j.Name] = true
+
+			diffs = append(diffs, JobDiff{
+				Name:   j.Name,
+				Before: find(before, j.Name),
+				After:  find(after, j.Name),
+			})
+		}
+	}
+
+	return diffs
+}
@@ -0,0 +1,163 @@
+package reporting
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/cache/file"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	logger.InitLogger(0, nil)
+
+	dir, err := ioutil.TempDir("", "hone-history")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fileCache := &filecache.FileCache{CacheDir: dir}
+	assert.Nil(t, fileCache.Init())
+
+	entries, err := LoadHistory(fileCache, "feature/history")
+	assert.Nil(t, err)
+	assert.Equal(t, []HistoryEntry{}, entries)
+
+	for i, state := range []string{"failure", "success"} {
+		report := &Report{
+			BuildID:   []string{"build-1", "build-2"}[i],
+			GitBranch: "feature/history",
+			GitCommit: "729ffe88",
+			Target:    "test",
+			StartTime: at(i * 10),
+			EndTime:   at(i*10 + 5),
+			State:     state,
+			Jobs: []*job.Job{
+				{Name: "test", Hash: "abc123", StartTime: at(i * 10), EndTime: at(i*10 + 5)},
+				{Name: "all", Aggregate: true},
+			},
+		}
+
+		if state == "failure" {
+			report.Jobs[0].Error = errors.New("exit status 1")
+		}
+
+		_, err := AppendHistory(fileCache, report.HistoryEntry("report.json"))
+		assert.Nil(t, err)
+	}
+
+	entries, err = LoadHistory(fileCache, "feature/history")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "build-1", entries[0].BuildID)
+	assert.Equal(t, "report.json", entries[0].ReportURL)
+	assert.Equal(t, []HistoryJob{{Name: "test", Hash: "abc123", Status: "failed", Duration: 5 * time.Second}}, entries[0].Jobs)
+	assert.Equal(t, "success", entries[1].State)
+	assert.Equal(t, 5*time.Second, entries[1].Duration())
+
+	entries, err = LoadHistory(fileCache, "master")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(entries))
+
+	defer func(max int) {
+		maxHistory = max
+	}(maxHistory)
+	maxHistory = 2
+
+	entries, err = AppendHistory(fileCache, HistoryEntry{BuildID: "build-3", GitBranch: "feature/history"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "build-2", entries[0].BuildID)
+	assert.Equal(t, "build-3", entries[1].BuildID)
+
+	entries, err = LoadHistory(fileCache, "feature/history")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "build-2", entries[0].BuildID)
+}
+
+func TestFindBuild(t *testing.T) {
+	entries := []HistoryEntry{{BuildID: "abc123"}, {BuildID: "abd456"}}
+
+	entry, err := FindBuild(entries, "abd")
+	assert.Nil(t, err)
+	assert.Equal(t, "abd456", entry.BuildID)
+
+	_, err = FindBuild(entries, "ab")
+	assert.NotNil(t, err)
+
+	_, err = FindBuild(entries, "xyz")
+	assert.NotNil(t, err)
+}
+
+func TestFlakyJobs(t *testing.T) {
+	entries := []HistoryEntry{
+		{
+			Jobs: []HistoryJob{
+				{Name: "test", Hash: "abc", Status: "failed"},
+				{Name: "lint", Hash: "def", Status: "failed"},
+				{Name: "build", Hash: "ghi", Status: "ran"},
+			},
+		},
+		{
+			Jobs: []HistoryJob{
+				{Name: "test", Hash: "abc", Status: "ran"},
+				{Name: "lint", Hash: "xyz", Status: "ran"},
+				{Name: "build", Hash: "ghi", Status: "cached"},
+			},
+		},
+		{
+			Jobs: []HistoryJob{
+				{Name: "test", Hash: "abc", Status: "ran"},
+				{Name: "deploy", Status: "failed"},
+				{Name: "deploy", Status: "ran"},
+			},
+		},
+	}
+
+	assert.Equal(t, []FlakyJob{{Name: "test", Hash: "abc", Passed: 2, Failed: 1}}, FlakyJobs(entries))
+}
+
+func TestDiffBuilds(t *testing.T) {
+	before := BuildRecord{
+		Jobs: []JobRecord{
+			{Name: "build", Successful: true, StartTime: at(0), EndTime: at(4)},
+			{Name: "test", Successful: true, StartTime: at(4), EndTime: at(5)},
+			{Name: "docs", Successful: true, StartTime: at(4), EndTime: at(6)},
+		},
+	}
+
+	after := BuildRecord{
+		Jobs: []JobRecord{
+			{Name: "build", Successful: true, Cached: true, StartTime: at(0), EndTime: at(0)},
+			{Name: "test", StartTime: at(0), EndTime: at(2)},
+			{Name: "lint", Successful: true, StartTime: at(0), EndTime: at(1)},
+			{Name: "all", Successful: true, Aggregate: true},
+		},
+	}
+
+	diffs := DiffBuilds(before, after)
+
+	names := []string{}
+	for _, d := range diffs {
+		names = append(names, d.Name)
+	}
+	assert.Equal(t, []string{"build", "test", "lint", "docs"}, names)
+
+	assert.True(t, diffs[0].Changed())
+	assert.Equal(t, "ran", diffs[0].Before.Status())
+	assert.Equal(t, "cached", diffs[0].After.Status())
+	assert.Equal(t, -4*time.Second, diffs[0].Delta())
+
+	assert.Equal(t, "failed", diffs[1].After.Status())
+	assert.Equal(t, time.Second, diffs[1].Delta())
+
+	assert.Nil(t, diffs[2].Before)
+	assert.True(t, diffs[2].Changed())
+	assert.Nil(t, diffs[3].After)
+	assert.Equal(t, -2*time.Second, diffs[3].Delta())
+}
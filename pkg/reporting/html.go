@@ -0,0 +1,304 @@
+package reporting
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/junit"
+)
+
+// Dimensions of the dependency graph, in pixels.
+const (
+	nodeWidth    = 160
+	nodeHeight   = 30
+	columnWidth  = 200
+	rowHeight    = 50
+	graphPadding = 10
+)
+
+// A line of a job's log, decoded from the JSON log stream.
+type logLine struct {
+	Level   string
+	Stream  string
+	Message string
+}
+
+// A job as shown in the HTML report.
+type htmlJob struct {
+	Name         string
+	Status       string
+	Cached       bool
+	Duration     time.Duration
+	Hash         string
+	OutputHashes map[string]string
+	Error        string
+	Offset       float64
+	Width        float64
+	Logs         []logLine
+	Tests        junit.TestSuites
+	FailedTests  []junit.TestCase
+}
+
+type graphNode struct {
+	Name   string
+	Status string
+	X      int
+	Y      int
+}
+
+type graphEdge struct {
+	X1 int
+	Y1 int
+	X2 int
+	Y2 int
+}
+
+// A layered layout of the job dependency graph, each job is placed in the column
+// after its deepest dependency.
+type graphLayout struct {
+	Width      int
+	Height     int
+	NodeWidth  int
+	NodeHeight int
+	Nodes      []graphNode
+	Edges      []graphEdge
+}
+
+type htmlReport struct {
+	*Report
+	Duration   time.Duration
+	ReportJSON string
+	Jobs       []htmlJob
+	Graph      graphLayout
+}
+
+// Return a job's status: failed, skipped, cached or ran.
+func jobStatus(j *job.Job) string {
+	switch {
+	case j.Error != nil:
+		return "failed"
+	case j.Skipped:
+		return "skipped"
+	case j.Cached:
+		return "cached"
+	}
+
+	return "ran"
+}
+
+// Group the lines of a JSON log stream by job.
+func jobLogs(stream io.Reader) map[string][]logLine {
+	logs := map[string][]logLine{}
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		entry := struct {
+			Fields  map[string]interface{} `json:"fields"`
+			Level   string                 `json:"level"`
+			Message string                 `json:"message"`
+		}{}
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+
+		name, ok := entry.Fields["job"].(string)
+		if !ok {
+			continue
+		}
+
+		line := logLine{
+			Level:   entry.Level,
+			Message: entry.Message,
+		}
+
+		if entry.Fields["stdout"] == true {
+			line.Stream = "stdout"
+		} else if entry.Fields["stderr"] == true {
+			line.Stream = "stderr"
+		}
+
+		logs[name] = append(logs[name], line)
+	}
+
+	return logs
+}
+
+// Lay out the dependency graph of the jobs in the report.
+func layoutGraph(jobs []*job.Job) graphLayout {
+	byName := map[string]*job.Job{}
+	for _, j := range jobs {
+		byName[j.GetName()] = j
+	}
+
+	depths := map[string]int{}
+
+	var depth func(*job.Job) int
+	depth = func(j *job.Job) int {
+		if d, ok := depths[j.GetName()]; ok {
+			return d
+		}
+
+		d := 0
+		for _, dep := range j.GetDeps() {
+			if depJob := byName[dep]; depJob != nil && depth(depJob)+1 > d {
+				d = depth(depJob) + 1
+			}
+		}
+
+		depths[j.GetName()] = d
+		return d
+	}
+
+	layout := graphLayout{
+		NodeWidth:  nodeWidth,
+		NodeHeight: nodeHeight,
+	}
+	rows := map[int]int{}
+	positions := map[string]graphNode{}
+
+	for _, j := range jobs {
+		column := depth(j)
+
+		node := graphNode{
+			Name:   j.GetName(),
+			Status: jobStatus(j),
+			X:      graphPadding + column*columnWidth,
+			Y:      graphPadding + rows[column]*rowHeight,
+		}
+		rows[column]++
+
+		positions[node.Name] = node
+		layout.Nodes = append(layout.Nodes, node)
+
+		if node.X+nodeWidth+graphPadding > layout.Width {
+			layout.Width = node.X + nodeWidth + graphPadding
+		}
+
+		if node.Y+nodeHeight+graphPadding > layout.Height {
+			layout.Height = node.Y + nodeHeight + graphPadding
+		}
+	}
+
+	for _, j := range jobs {
+		to := positions[j.GetName()]
+
+		for _, dep := range j.GetDeps() {
+			from, ok := positions[dep]
+			if !ok {
+				continue
+			}
+
+			layout.Edges = append(layout.Edges, graphEdge{
+				X1: from.X + nodeWidth,
+				Y1: from.Y + nodeHeight/2,
+				X2: to.X,
+				Y2: to.Y + nodeHeight/2,
+			})
+		}
+	}
+
+	return layout
+}
+
+// Return the path to target relative to the directory of base, if both are local
+// paths, so that the file cache's reports can link to other files in it.
+func relativeURL(base, target string) string {
+	if strings.Contains(base, "://") || strings.Contains(target, "://") {
+		return target
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(base), target)
+	if err != nil {
+		return target
+	}
+
+	return rel
+}
+
+// Render the report as a self-contained HTML page.
+func (r *Report) RenderHTML(w io.Writer, reportUrl, reportJsonUrl string) error {
+	data, err := Asset("templates/index.html")
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"round": func(d time.Duration) time.Duration {
+			return d.Round(100 * time.Millisecond)
+		},
+		"percent": func(f float64) string {
+			return fmt.Sprintf("%.2f%%", f)
+		},
+	}).Parse(string(data))
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	jobs := []*job.Job{}
+	for _, j := range r.Jobs {
+		if !j.Aggregate {
+			jobs = append(jobs, j)
+		}
+	}
+	logs := jobLogs(bytes.NewReader(r.logs.Bytes()))
+	r.lock.Unlock()
+
+	sort.SliceStable(jobs, func(i, k int) bool {
+		return jobs[i].StartTime.Before(jobs[k].StartTime)
+	})
+
+	report := htmlReport{
+		Report:     r,
+		Duration:   r.EndTime.Sub(r.StartTime),
+		ReportJSON: relativeURL(reportUrl, reportJsonUrl),
+		Graph:      layoutGraph(jobs),
+	}
+
+	for _, j := range jobs {
+		htmlJob := htmlJob{
+			Name:         j.GetName(),
+			Status:       jobStatus(j),
+			Cached:       j.Cached,
+			Duration:     j.EndTime.Sub(j.StartTime),
+			Hash:         j.Hash,
+			OutputHashes: j.OutputHashes,
+			Logs:         logs[j.GetName()],
+		}
+
+		if j.Error != nil {
+			htmlJob.Error = j.Error.Error()
+		}
+
+		htmlJob.Tests.Suites = j.Tests
+		htmlJob.Tests.Count()
+		for _, suite := range j.Tests {
+			for _, c := range suite.Cases {
+				if c.Failed() {
+					htmlJob.FailedTests = append(htmlJob.FailedTests, c)
+				}
+			}
+		}
+
+		if report.Duration > 0 {
+			htmlJob.Offset = float64(j.StartTime.Sub(r.StartTime)) / float64(report.Duration) * 100
+			htmlJob.Width = float64(htmlJob.Duration) / float64(report.Duration) * 100
+		}
+
+		report.Jobs = append(report.Jobs, htmlJob)
+	}
+
+	return tmpl.Execute(w, report)
+}
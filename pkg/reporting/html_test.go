@@ -0,0 +1,106 @@
+package reporting
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/cache/file"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadReportFileCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hone-report")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	start := time.Now().UTC().Add(-4 * time.Second)
+	deps := job.StringSet{"build"}
+
+	report := &Report{
+		GitCommit: "729ffe8860eacb4aa5aaff19e9a05ab6d8cc5ede",
+		Target:    "test",
+		StartTime: start,
+		Jobs: []*job.Job{
+			{
+				Name:         "build",
+				Hash:         "abc123",
+				OutputHashes: map[string]string{"bin/hone": "def456"},
+				StartTime:    start,
+				EndTime:      start.Add(time.Second),
+			},
+			{
+				Name:      "test",
+				Deps:      &deps,
+				Error:     errors.New("exit status 1"),
+				StartTime: start.Add(time.Second),
+				EndTime:   start.Add(2 * time.Second),
+			},
+		},
+	}
+	logger.InitLogger(0, report.LogStream(nil))
+
+	fileCache := &filecache.FileCache{CacheDir: dir}
+	assert.Nil(t, fileCache.Init())
+	report.SetCache(fileCache)
+
+	logger.LogWriter(report.Jobs[1]).Write([]byte("--- FAIL: TestThing <nil>\n"))
+	logger.Printf("not a job")
+
+	url, err := report.UploadReport()
+	assert.Nil(t, err)
+	assert.Equal(t, report.URL(), url)
+	assert.True(t, strings.HasPrefix(url, dir))
+	assert.Equal(t, "", report.JobURL("test"))
+
+	html, err := ioutil.ReadFile(url)
+	assert.Nil(t, err)
+
+	body := string(html)
+	assert.NotContains(t, body, "<script")
+	assert.Contains(t, body, `<a href="#job-build">build</a>`)
+	assert.Contains(t, body, "<code>bin/hone</code>: <code>def456</code>")
+	assert.Contains(t, body, `<details id="job-test" open>`)
+	assert.Contains(t, body, "--- FAIL: TestThing &lt;nil&gt;")
+	assert.NotContains(t, body, "not a job")
+	assert.Regexp(t, `<div class="bar-failed" style="left: 2\d\.\d\d%; width: 2\d\.\d\d%"`, body)
+	assert.Contains(t, body, `<line x1="170" y1="25" x2="210" y2="25">`)
+
+	jsonPath := filepath.Join(dir, "report-blobs", report.basePath(), "report.json")
+	assert.Contains(t, body, `<a href="../../../report-blobs/`+report.basePath()+`/report.json">`)
+	_, err = os.Stat(jsonPath)
+	assert.Nil(t, err)
+
+	history, err := LoadHistory(fileCache, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, jsonPath, history[0].ReportURL)
+}
+
+func TestLayoutGraph(t *testing.T) {
+	buildDeps := job.StringSet{"deps"}
+	testDeps := job.StringSet{"deps", "build"}
+
+	layout := layoutGraph([]*job.Job{
+		{Name: "deps"},
+		{Name: "build", Deps: &buildDeps},
+		{Name: "test", Deps: &testDeps},
+		{Name: "lint", Deps: &buildDeps},
+	})
+
+	assert.Equal(t, []graphNode{
+		{Name: "deps", Status: "ran", X: 10, Y: 10},
+		{Name: "build", Status: "ran", X: 210, Y: 10},
+		{Name: "test", Status: "ran", X: 410, Y: 10},
+		{Name: "lint", Status: "ran", X: 210, Y: 60},
+	}, layout.Nodes)
+	assert.Equal(t, 4, len(layout.Edges))
+	assert.Equal(t, 580, layout.Width)
+	assert.Equal(t, 100, layout.Height)
+}
@@ -0,0 +1,138 @@
+package reporting
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/justinbarrick/hone/pkg/cache"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/junit"
+	"github.com/justinbarrick/hone/pkg/logger"
+)
+
+// Wrap a job callback to parse the job's test_reports once it has run, whether
+// or not it succeeded. Missing or invalid reports are logged, but do not fail the
+// job. Cached jobs did not run, so their reports are not parsed.
+func CollectTests(callback func(*job.Job) error) func(*job.Job) error {
+	return func(j *job.Job) error {
+		err := callback(j)
+		if j.Aggregate || j.Skipped || j.Cached {
+			return err
+		}
+
+		j.Tests = nil
+
+		walkErr := cache.WalkInputs(j.GetTestReports(), func(path string) error {
+			file, err := os.Open(path)
+			if err != nil {
+				logger.Log(j, fmt.Sprintf("Could not read test report %s: %s", path, err))
+				return nil
+			}
+			defer file.Close()
+
+			suites, err := junit.Parse(file)
+			if err != nil {
+				logger.Log(j, fmt.Sprintf("Could not parse test report %s: %s", path, err))
+				return nil
+			}
+
+			j.Tests = append(j.Tests, suites...)
+			return nil
+		})
+		if walkErr != nil {
+			logger.Log(j, fmt.Sprintf("Could not find test reports: %s", walkErr))
+		}
+
+		if failed := junit.Failed(j.Tests); len(failed) > 0 {
+			logger.LogError(j, fmt.Sprintf("Failed tests: %s", strings.Join(failed, ", ")))
+		}
+
+		return err
+	}
+}
+
+// Return the build as a JUnit report: a suite with a test case for each job,
+// followed by the suites from the jobs' test reports.
+func (r *Report) JUnit() junit.TestSuites {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	logs := jobLogs(bytes.NewReader(r.logs.Bytes()))
+
+	hone := junit.TestSuite{
+		Name:      "hone",
+		Timestamp: r.StartTime.Format("2006-01-02T15:04:05"),
+		Time:      r.EndTime.Sub(r.StartTime).Seconds(),
+	}
+	suites := []junit.TestSuite{}
+
+	for _, j := range r.Jobs {
+		if j.Aggregate {
+			continue
+		}
+
+		testCase := junit.TestCase{
+			Name:      j.GetName(),
+			Classname: "hone",
+			Time:      j.EndTime.Sub(j.StartTime).Seconds(),
+		}
+
+		stderr := []string{}
+		for _, line := range logs[j.GetName()] {
+			if line.Stream == "stderr" {
+				stderr = append(stderr, line.Message)
+			}
+		}
+		testCase.SystemErr = strings.Join(stderr, "\n")
+
+		switch {
+		case j.Error != nil:
+			failure := &junit.Result{
+				Message: j.Error.Error(),
+				Text:    strings.Join(logger.Output(j.GetName()), "\n"),
+			}
+
+			if failed := junit.Failed(j.Tests); len(failed) > 0 {
+				failure.Message = fmt.Sprintf("%s, failed tests: %s", failure.Message, strings.Join(failed, ", "))
+			}
+
+			if job.IsExitError(j.Error) {
+				testCase.Failure = failure
+			} else {
+				testCase.Error = failure
+			}
+		case j.Skipped:
+			testCase.Skipped = &junit.Result{
+				Message: "Condition not met.",
+			}
+		}
+
+		hone.Cases = append(hone.Cases, testCase)
+		suites = append(suites, j.Tests...)
+	}
+
+	report := junit.TestSuites{
+		Name:   r.Target,
+		Suites: append([]junit.TestSuite{hone}, suites...),
+	}
+	report.Count()
+
+	return report
+}
+
+// Write the build's JUnit report to a file.
+func (r *Report) WriteJUnit(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := junit.Write(file, r.JUnit()); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
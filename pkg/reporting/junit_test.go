@@ -0,0 +1,104 @@
+package reporting
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+const testReport = `<testsuite name="pkg">
+  <testcase classname="pkg" name="TestOk"></testcase>
+  <testcase classname="pkg" name="TestBroken"><failure message="Failed">main_test.go:10: broken</failure></testcase>
+</testsuite>`
+
+func TestCollectTests(t *testing.T) {
+	logger.InitLogger(0, nil)
+
+	dir, err := ioutil.TempDir("", "hone-junit")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "report.xml"), []byte(testReport), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "invalid.xml"), []byte("not xml"), 0644))
+
+	reports := job.StringSet{filepath.Join(dir, "*.xml"), filepath.Join(dir, "missing.xml")}
+	j := &job.Job{Name: "test", TestReports: &reports}
+
+	err = CollectTests(func(j *job.Job) error {
+		return &job.ExitError{Code: 1, Message: "exit status 1"}
+	})(j)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, 1, len(j.Tests))
+	assert.Equal(t, 2, j.Tests[0].Tests)
+	assert.Equal(t, 1, j.Tests[0].Failures)
+
+	cached := &job.Job{Name: "test", TestReports: &reports}
+	assert.Nil(t, CollectTests(func(j *job.Job) error {
+		j.Cached = true
+		return nil
+	})(cached))
+	assert.Nil(t, cached.Tests)
+}
+
+func TestJUnit(t *testing.T) {
+	start := time.Now().UTC()
+
+	report := &Report{
+		Target:    "test",
+		StartTime: start,
+		EndTime:   start.Add(3 * time.Second),
+	}
+	logger.InitLogger(0, report.LogStream(nil))
+
+	build := &job.Job{Name: "build", StartTime: start, EndTime: start.Add(time.Second)}
+	lint := &job.Job{Name: "lint", Skipped: true}
+	test := &job.Job{
+		Name:      "test",
+		Error:     &job.ExitError{Code: 1, Message: "exit status 1"},
+		StartTime: start.Add(time.Second),
+		EndTime:   start.Add(3 * time.Second),
+	}
+	report.Jobs = []*job.Job{build, lint, test, {Name: "all", Aggregate: true}}
+
+	logger.LogWriterError(test).Write([]byte("panic: oops\n"))
+	logger.LogWriter(test).Write([]byte("--- FAIL: TestBroken\n"))
+
+	dir, err := ioutil.TempDir("", "hone-junit")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "report.xml"), []byte(testReport), 0644))
+	test.TestReports = &job.StringSet{filepath.Join(dir, "report.xml")}
+
+	CollectTests(func(j *job.Job) error {
+		return j.Error
+	})(test)
+
+	suites := report.JUnit()
+	assert.Equal(t, "test", suites.Name)
+	assert.Equal(t, 5, suites.Tests)
+	assert.Equal(t, 2, suites.Failures)
+	assert.Equal(t, 1, suites.Skipped)
+	assert.Equal(t, 2, len(suites.Suites))
+
+	hone := suites.Suites[0]
+	assert.Equal(t, "hone", hone.Name)
+	assert.Equal(t, []string{"build", "lint", "test"}, []string{hone.Cases[0].Name, hone.Cases[1].Name, hone.Cases[2].Name})
+	assert.Equal(t, 1.0, hone.Cases[0].Time)
+	assert.NotNil(t, hone.Cases[1].Skipped)
+	assert.Equal(t, "exit status 1, failed tests: TestBroken", hone.Cases[2].Failure.Message)
+	assert.Equal(t, "panic: oops", hone.Cases[2].SystemErr)
+
+	html := bytes.NewBuffer(nil)
+	assert.Nil(t, report.RenderHTML(html, "", ""))
+	assert.Contains(t, html.String(), `2 tests, <span class="failed">1 failed</span>`)
+	assert.Contains(t, html.String(), `<summary><span class="failed">TestBroken</span>: Failed</summary>`)
+}
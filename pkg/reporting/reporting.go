@@ -1,41 +1,83 @@
 package reporting
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"html/template"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/justinbarrick/hone/pkg/cache"
+	"github.com/justinbarrick/hone/pkg/ci"
 	"github.com/justinbarrick/hone/pkg/git"
+	"github.com/justinbarrick/hone/pkg/graph"
 	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/junit"
 	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/justinbarrick/hone/pkg/notify"
 	"github.com/justinbarrick/hone/pkg/scm"
+	"github.com/justinbarrick/hone/pkg/utils"
 )
 
 //go:generate go-bindata -pkg reporting -nomemcopy templates/...
 
 type Report struct {
+	BuildID string
+
 	GitBranch string
 	GitCommit string
 	GitTag    string
 
+	CI *ci.Build
+
 	Target string
 
 	StartTime time.Time
 	EndTime   time.Time
 
 	Success bool
+	State   string
 	Jobs    []*job.Job
 
 	LogURL string
 
-	scms  []*scm.SCM
-	cache cache.Cache
-	lock  sync.Mutex
+	scms      []*scm.SCM
+	notifiers []*notify.Notifier
+	cache     cache.Cache
+	logs      bytes.Buffer
+	junitPath string
+	lock      sync.Mutex
+}
+
+// Copies the JSON log stream into the report, so that each job's logs can be
+// included in the HTML report.
+type logStream struct {
+	report *Report
+	remote io.WriteCloser
+}
+
+func (l logStream) Write(b []byte) (int, error) {
+	l.report.lock.Lock()
+	l.report.logs.Write(b)
+	l.report.lock.Unlock()
+
+	if l.remote == nil {
+		return len(b), nil
+	}
+
+	return l.remote.Write(b)
+}
+
+func (l logStream) Close() error {
+	if l.remote == nil {
+		return nil
+	}
+
+	return l.remote.Close()
 }
 
 func New(target string, scms []*scm.SCM, cache cache.Cache) (Report, error) {
@@ -46,9 +88,11 @@ func New(target string, scms []*scm.SCM, cache cache.Cache) (Report, error) {
 	tag, _ := repo.Tag()
 
 	return Report{
+		BuildID:   utils.NewBuildID(),
 		GitBranch: branch,
 		GitCommit: commit,
 		GitTag:    tag,
+		CI:        repo.CI,
 		Target:    target,
 		StartTime: time.Now().UTC(),
 		cache:     cache,
@@ -60,93 +104,315 @@ func (r *Report) SetLogURL(url string) {
 	r.LogURL = url
 }
 
+// Also write the build's JUnit report to path when the build finishes.
+func (r *Report) SetJUnitPath(path string) {
+	r.junitPath = path
+}
+
+// Send build and job events to notifiers.
+func (r *Report) SetNotifiers(notifiers []*notify.Notifier) {
+	r.notifiers = notifiers
+}
+
+// Return an event for the build.
+func (r *Report) event(eventType notify.EventType, state scm.State) notify.Event {
+	end := r.EndTime
+	if end.IsZero() {
+		end = time.Now().UTC()
+	}
+
+	return notify.Event{
+		Type:     eventType,
+		BuildID:  r.BuildID,
+		Target:   r.Target,
+		Branch:   r.GitBranch,
+		Commit:   r.GitCommit,
+		State:    state.String(),
+		Duration: end.Sub(r.StartTime).Round(100 * time.Millisecond),
+		LogURL:   r.LogURL,
+	}
+}
+
+// Send an event to the notifiers, errors are logged but do not fail the build.
+func (r *Report) notify(event notify.Event) {
+	if err := notify.Notify(r.notifiers, event); err != nil {
+		logger.Errorf("%s", err)
+	}
+}
+
+// Notify that the build has started.
+func (r *Report) Started() {
+	r.notify(r.event(notify.EventStarted, scm.StateRunning))
+}
+
+// Notify that a job failed, jobs that did not run because a dependency failed
+// or the build was canceled are not notified about.
+func (r *Report) jobFailed(j *job.Job, err error) {
+	if _, ok := err.(*graph.DependencyError); ok || err == graph.ErrCanceled {
+		return
+	}
+
+	state := scm.StateError
+	if job.IsExitError(err) {
+		state = scm.StateFailure
+	}
+
+	event := r.event(notify.EventJobFailed, state)
+	event.Job = j.GetName()
+	event.Error = err.Error()
+	event.Duration = j.EndTime.Sub(j.StartTime).Round(100 * time.Millisecond)
+	event.ReportURL = r.JobURL(j.GetName())
+	r.notify(event)
+}
+
+// Return a log writer for logger.InitLogger that records the logs in the report
+// and also writes them to remote, if it is not nil.
+func (r *Report) LogStream(remote io.WriteCloser) io.WriteCloser {
+	return logStream{
+		report: r,
+		remote: remote,
+	}
+}
+
 func (r *Report) ReportJob(callback func(*job.Job) error) func(*job.Job) error {
 	return func(j *job.Job) error {
 		r.lock.Lock()
 		r.Jobs = append(r.Jobs, j)
 		r.lock.Unlock()
 
-		return callback(j)
+		j.StartTime = time.Now().UTC()
+		err := callback(j)
+		j.EndTime = time.Now().UTC()
+
+		if err != nil {
+			r.jobFailed(j, err)
+		}
+
+		return err
 	}
 }
 
+// The number of log lines included for each failed job in a build summary.
+const excerptLines = 20
+
+// Summarize the build for pull request comments.
+func (r *Report) Summary(reportUrl string) scm.BuildSummary {
+	summary := scm.BuildSummary{
+		Success:   r.Success,
+		Target:    r.Target,
+		Commit:    r.GitCommit,
+		ReportURL: reportUrl,
+		LogURL:    r.LogURL,
+		Jobs:      []scm.JobSummary{},
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, j := range r.Jobs {
+		if j.Aggregate {
+			continue
+		}
+
+		jobSummary := scm.JobSummary{
+			Name:     j.GetName(),
+			Status:   jobStatus(j),
+			Duration: j.EndTime.Sub(j.StartTime).Round(100 * time.Millisecond),
+		}
+
+		if j.Error != nil {
+			jobSummary.Error = j.Error.Error()
+			jobSummary.Excerpt = logger.Output(j.GetName())
+			if len(jobSummary.Excerpt) > excerptLines {
+				jobSummary.Excerpt = jobSummary.Excerpt[len(jobSummary.Excerpt)-excerptLines:]
+			}
+		}
+
+		summary.Jobs = append(summary.Jobs, jobSummary)
+	}
+
+	return summary
+}
+
 func (r *Report) SetCache(cache cache.Cache) {
 	r.cache = cache
 }
 
+func (r *Report) basePath() string {
+	return filepath.Join(r.GitCommit, fmt.Sprintf("%d", r.StartTime.Unix()))
+}
+
+// Return the URL the HTML report will be uploaded to, or an empty string if
+// there is no cache to upload it to.
+func (r *Report) URL() string {
+	if r.cache == nil || !r.cache.Enabled() {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(r.cache.BaseURL(), "/"), filepath.Join("reports", r.basePath(), "report.html"))
+}
+
+// Return the URL of a job's section of the HTML report, or an empty string if
+// the report is not served over HTTP, like reports in the file cache.
+func (r *Report) JobURL(name string) string {
+	url := webURL(r.URL())
+	if url == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s#job-%s", url, name)
+}
+
+// Return url if it can be linked to from an SCM, otherwise an empty string.
+func webURL(url string) string {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return url
+	}
+
+	return ""
+}
+
 func (r *Report) UploadReport() (string, error) {
 	if r.cache == nil || !r.cache.Enabled() {
 		return "", nil
 	}
 
-	r.EndTime = time.Now().UTC()
-
-	base := filepath.Join(r.GitCommit, fmt.Sprintf("%d", r.StartTime.Unix()))
+	base := r.basePath()
 
 	reportJson, reportJsonUrl, err := r.cache.Writer("report-blobs", filepath.Join(base, "report.json"))
 	if err != nil {
 		return "", err
 	}
 
+	r.lock.Lock()
+	r.EndTime = time.Now().UTC()
 	err = json.NewEncoder(reportJson).Encode(r)
+	r.lock.Unlock()
 	if err != nil {
 		return "", err
 	}
 
 	reportJson.Close()
 
-	reportWriter, reportUrl, err := r.cache.Writer("reports", filepath.Join(base, "report.html"))
+	junitWriter, _, err := r.cache.Writer("report-blobs", filepath.Join(base, "junit.xml"))
 	if err != nil {
 		return "", err
 	}
 
-	data, err := Asset("templates/index.html")
+	err = junit.Write(junitWriter, r.JUnit())
+	junitWriter.Close()
 	if err != nil {
 		return "", err
 	}
 
-	template.Must(template.New("").Parse(string(data))).Execute(reportWriter, struct {
-		ReportJSON string
-		LogURL     string
-	}{
-		ReportJSON: reportJsonUrl,
-		LogURL:     r.LogURL,
-	})
+	reportWriter, reportUrl, err := r.cache.Writer("reports", filepath.Join(base, "report.html"))
+	if err != nil {
+		return "", err
+	}
 
+	err = r.RenderHTML(reportWriter, reportUrl, reportJsonUrl)
 	reportWriter.Close()
+	if err != nil {
+		return "", err
+	}
 
 	logger.Printf("Report uploaded to: %s", reportUrl)
+
+	r.recordHistory(reportJsonUrl)
 	return reportUrl, nil
 }
 
+// Append the build to its branch's history index and warn about jobs in the
+// build that are flaky. Errors are logged, but do not fail the build.
+func (r *Report) recordHistory(reportJsonUrl string) {
+	entry := r.HistoryEntry(reportJsonUrl)
+
+	history, err := AppendHistory(r.cache, entry)
+	if err != nil {
+		logger.Errorf("Error updating build history: %s", err)
+		return
+	}
+
+	ran := map[string]bool{}
+	for _, j := range entry.Jobs {
+		if j.Status == "ran" || j.Status == "failed" {
+			ran[j.Name+"/"+j.Hash] = true
+		}
+	}
+
+	for _, flaky := range FlakyJobs(history) {
+		if ran[flaky.Name+"/"+flaky.Hash] {
+			logger.Printf("Job %s is flaky: with the same hash it has passed %d times and failed %d times.", flaky.Name, flaky.Passed, flaky.Failed)
+		}
+	}
+}
+
 func (r *Report) Final(errs ...error) {
-	r.Success = len(errs) == 0
+	r.finish(scm.BuildState(errs), errs...)
+}
+
+// Report the build as canceled, for example when hone is interrupted.
+func (r *Report) Cancel(reason error) {
+	r.finish(scm.StateCanceled, reason)
+}
+
+func (r *Report) finish(state scm.State, errs ...error) {
+	r.lock.Lock()
+	r.Success = state == scm.StateSuccess
+	r.State = state.String()
+	r.lock.Unlock()
 
 	reportUrl, err := r.UploadReport()
 	if err != nil {
 		logger.Errorf("Error uploading report to cache: %s", err)
 		errs = append(errs, err)
 	}
+	reportUrl = webURL(reportUrl)
+
+	if r.junitPath != "" {
+		if err := r.WriteJUnit(r.junitPath); err != nil {
+			logger.Errorf("Error writing JUnit report: %s", err)
+			errs = append(errs, err)
+		}
+	}
 
 	if r.LogURL != "" {
 		logger.Printf("Logs available: %s", r.LogURL)
 	}
 
 	if len(errs) > 0 {
-		if errs[0].Error() == fmt.Sprintf("Target %s not found.", r.Target) {
-			logger.Printf("Error: Target %s not found in configuration!", r.Target)
+		msg := errs[0].Error()
+		if strings.HasPrefix(msg, "Target ") && strings.HasSuffix(msg, " not found.") {
+			logger.Printf("Error: %s not found in configuration!", strings.TrimSuffix(msg, " not found."))
 		}
 
-		logger.Errorf("Exiting with failure.")
+		if state == scm.StateCanceled {
+			logger.Errorf("Build canceled: %s", errs[0])
+		} else {
+			logger.Errorf("Exiting with failure.")
+		}
 	} else {
 		logger.Successf("Build completed successfully!")
 	}
 
-	err = scm.ReportBuild(r.scms, r.Success, reportUrl)
+	err = scm.ReportBuild(r.scms, state, reportUrl)
 	if err != nil {
 		logger.Errorf("Error reporting build to SCM: %s", err)
 		errs = append(errs, err)
 	}
+
+	if err = scm.CommentBuild(r.scms, r.Summary(reportUrl)); err != nil {
+		logger.Errorf("Error commenting on pull request: %s", err)
+	}
+
+	event := r.event(notify.EventFinished, state)
+	event.ReportURL = reportUrl
+	for _, j := range r.Summary(reportUrl).Jobs {
+		if j.Status == "failed" {
+			event.Failed = append(event.Failed, j.Name)
+		}
+	}
+	r.notify(event)
 }
 
 func (r *Report) Exit(errs ...error) {
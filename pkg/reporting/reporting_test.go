@@ -0,0 +1,80 @@
+package reporting
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/justinbarrick/hone/pkg/graph"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/justinbarrick/hone/pkg/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(t *testing.T) {
+	logger.InitLogger(0, nil)
+
+	events := []notify.Event{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		event := notify.Event{}
+		assert.Nil(t, json.Unmarshal(body, &event))
+		events = append(events, event)
+	}))
+	defer server.Close()
+
+	url := server.URL
+	notifiers, err := notify.Init([]*notify.Notifier{
+		{
+			Driver: notify.DriverWebhook,
+			URL:    &url,
+			Events: &[]string{"started", "finished", "job_failed"},
+		},
+	}, nil)
+	assert.Nil(t, err)
+
+	report := &Report{
+		BuildID:   "build-1",
+		GitBranch: "master",
+		GitCommit: "729ffe88",
+		Target:    "all",
+		StartTime: at(0),
+	}
+	report.SetNotifiers(notifiers)
+
+	report.Started()
+
+	testErr := &job.ExitError{Code: 1, Message: "exit status 1"}
+	depErr := &graph.DependencyError{Deps: []string{"test"}}
+
+	report.ReportJob(func(j *job.Job) error { return nil })(&job.Job{Name: "build"})
+	report.ReportJob(func(j *job.Job) error {
+		j.Error = testErr
+		return testErr
+	})(&job.Job{Name: "test"})
+	report.ReportJob(func(j *job.Job) error {
+		j.Error = depErr
+		return depErr
+	})(&job.Job{Name: "deploy"})
+
+	report.Final(testErr, depErr)
+
+	assert.Equal(t, 3, len(events))
+
+	assert.Equal(t, notify.EventStarted, events[0].Type)
+	assert.Equal(t, "running", events[0].State)
+	assert.Equal(t, "master", events[0].Branch)
+
+	assert.Equal(t, notify.EventJobFailed, events[1].Type)
+	assert.Equal(t, "test", events[1].Job)
+	assert.Equal(t, "failure", events[1].State)
+	assert.Equal(t, "exit status 1", events[1].Error)
+
+	assert.Equal(t, notify.EventFinished, events[2].Type)
+	assert.Equal(t, "failure", events[2].State)
+	assert.Equal(t, []string{"test", "deploy"}, events[2].Failed)
+}
@@ -0,0 +1,213 @@
+package reporting
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/job"
+)
+
+// A build as recorded in report.json.
+type BuildRecord struct {
+	BuildID   string
+	GitBranch string
+	GitCommit string
+	Target    string
+	StartTime time.Time
+	EndTime   time.Time
+	Success   bool
+	State     string
+	Jobs      []JobRecord
+}
+
+// A job as recorded in report.json, see job.Job.MarshalJSON.
+type JobRecord struct {
+	Name       string
+	Deps       []string
+	Hash       string
+	Successful bool
+	Error      string
+	Cached     bool
+	Skipped    bool
+	Aggregate  bool
+	QueuedTime time.Time
+	StartTime  time.Time
+	EndTime    time.Time
+	Stats      job.Stats
+}
+
+func (j JobRecord) Duration() time.Duration {
+	return j.EndTime.Sub(j.StartTime)
+}
+
+// Return the job's status as in the HTML report: failed, skipped, cached or ran.
+func (j JobRecord) Status() string {
+	switch {
+	case !j.Successful:
+		return "failed"
+	case j.Skipped:
+		return "skipped"
+	case j.Cached:
+		return "cached"
+	}
+
+	return "ran"
+}
+
+// Load a report.json from a file or an HTTP URL.
+func LoadBuild(path string) (BuildRecord, error) {
+	build := BuildRecord{}
+
+	var reader io.ReadCloser
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		res, err := http.Get(path)
+		if err != nil {
+			return build, err
+		}
+
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return build, fmt.Errorf("Error fetching %s: %s", path, res.Status)
+		}
+
+		reader = res.Body
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return build, err
+		}
+
+		reader = file
+	}
+	defer reader.Close()
+
+	if err := json.NewDecoder(reader).Decode(&build); err != nil {
+		return build, fmt.Errorf("Error decoding %s: %s", path, err)
+	}
+
+	return build, nil
+}
+
+// Return the jobs on the build's critical path in the order they ran: starting
+// from the job that finished last, each job is preceded by its dependency that
+// finished last.
+func (b BuildRecord) CriticalPath() []JobRecord {
+	byName := map[string]JobRecord{}
+	var last *JobRecord
+
+	for i, j := range b.Jobs {
+		if j.EndTime.IsZero() {
+			continue
+		}
+
+		byName[j.Name] = j
+		if last == nil || j.EndTime.After(last.EndTime) {
+			last = &b.Jobs[i]
+		}
+	}
+
+	path := []JobRecord{}
+	seen := map[string]bool{}
+
+	for last != nil && !seen[last.Name] {
+		seen[last.Name] = true
+		if !last.Aggregate {
+			path = append([]JobRecord{*last}, path...)
+		}
+
+		current := *last
+		last = nil
+
+		for _, name := range current.Deps {
+			dep, ok := byName[name]
+			if !ok {
+				continue
+			}
+
+			if last == nil || dep.EndTime.After(last.EndTime) {
+				d := dep
+				last = &d
+			}
+		}
+	}
+
+	return path
+}
+
+// A job's timing across builds.
+type JobStats struct {
+	Name       string
+	Runs       int
+	Cached     int
+	Failed     int
+	Total      time.Duration
+	Max        time.Duration
+	PeakMemory int64
+}
+
+// The average duration of the runs that were not cached or skipped.
+func (s JobStats) Average() time.Duration {
+	if s.Runs == 0 {
+		return 0
+	}
+
+	return s.Total / time.Duration(s.Runs)
+}
+
+// Aggregate the jobs of builds, slowest first by average duration. Cached and
+// skipped jobs are counted, but not included in durations.
+func SlowestJobs(builds []BuildRecord) []JobStats {
+	byName := map[string]*JobStats{}
+	stats := []*JobStats{}
+
+	for _, build := range builds {
+		for _, j := range build.Jobs {
+			if j.Aggregate || j.EndTime.IsZero() {
+				continue
+			}
+
+			s := byName[j.Name]
+			if s == nil {
+				s = &JobStats{Name: j.Name}
+				byName[j.Name] = s
+				stats = append(stats, s)
+			}
+
+			if !j.Successful {
+				s.Failed++
+			}
+
+			if j.Cached || j.Skipped {
+				s.Cached++
+				continue
+			}
+
+			s.Runs++
+			s.Total += j.Duration()
+			if j.Duration() > s.Max {
+				s.Max = j.Duration()
+			}
+
+			if j.Stats.PeakMemory > s.PeakMemory {
+				s.PeakMemory = j.Stats.PeakMemory
+			}
+		}
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Average() > stats[j].Average()
+	})
+
+	slowest := []JobStats{}
+	for _, s := range stats {
+		slowest = append(slowest, *s)
+	}
+
+	return slowest
+}
@@ -0,0 +1,114 @@
+package reporting
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/stretchr/testify/assert"
+)
+
+func at(seconds int) time.Time {
+	return time.Date(2019, 1, 1, 0, 0, seconds, 0, time.UTC)
+}
+
+func TestLoadBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hone-stats")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	exitCode := 1
+	report := &Report{
+		BuildID:   "build-1",
+		GitCommit: "729ffe88",
+		StartTime: at(0),
+		EndTime:   at(10),
+		Jobs: []*job.Job{
+			{
+				Name:       "test",
+				Error:      errors.New("exit status 1"),
+				QueuedTime: at(1),
+				StartTime:  at(2),
+				EndTime:    at(5),
+				Stats: job.Stats{
+					Engine:          "docker",
+					ContainerID:     "abc123",
+					ExitCode:        &exitCode,
+					CacheRestore:    time.Second,
+					BytesDownloaded: 2048,
+					PeakMemory:      1 << 20,
+					CPUTime:         2 * time.Second,
+				},
+			},
+		},
+	}
+
+	path := filepath.Join(dir, "report.json")
+	data, err := json.Marshal(report)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(path, data, 0644))
+
+	build, err := LoadBuild(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "build-1", build.BuildID)
+	assert.Equal(t, at(10), build.EndTime)
+	assert.Equal(t, []JobRecord{
+		{
+			Name:       "test",
+			Deps:       []string{},
+			Error:      "exit status 1",
+			QueuedTime: at(1),
+			StartTime:  at(2),
+			EndTime:    at(5),
+			Stats:      report.Jobs[0].Stats,
+		},
+	}, build.Jobs)
+	assert.Equal(t, 3*time.Second, build.Jobs[0].Duration())
+}
+
+func TestCriticalPath(t *testing.T) {
+	build := BuildRecord{
+		Jobs: []JobRecord{
+			{Name: "deps", StartTime: at(0), EndTime: at(2)},
+			{Name: "lint", Deps: []string{"deps"}, StartTime: at(2), EndTime: at(3)},
+			{Name: "build", Deps: []string{"deps"}, StartTime: at(2), EndTime: at(8)},
+			{Name: "test", Deps: []string{"build", "lint"}, StartTime: at(8), EndTime: at(9)},
+			{Name: "all", Deps: []string{"test", "lint"}, Aggregate: true, StartTime: at(9), EndTime: at(9)},
+			{Name: "docs"},
+		},
+	}
+
+	names := []string{}
+	for _, j := range build.CriticalPath() {
+		names = append(names, j.Name)
+	}
+
+	assert.Equal(t, []string{"deps", "build", "test"}, names)
+}
+
+func TestSlowestJobs(t *testing.T) {
+	builds := []BuildRecord{
+		{
+			Jobs: []JobRecord{
+				{Name: "build", Successful: true, StartTime: at(0), EndTime: at(4)},
+				{Name: "test", Successful: true, StartTime: at(4), EndTime: at(5), Stats: job.Stats{PeakMemory: 100}},
+			},
+		},
+		{
+			Jobs: []JobRecord{
+				{Name: "build", Successful: true, Cached: true, StartTime: at(0), EndTime: at(0)},
+				{Name: "test", StartTime: at(0), EndTime: at(3), Stats: job.Stats{PeakMemory: 50}},
+			},
+		},
+	}
+
+	assert.Equal(t, []JobStats{
+		{Name: "build", Runs: 1, Cached: 1, Total: 4 * time.Second, Max: 4 * time.Second},
+		{Name: "test", Runs: 2, Failed: 1, Total: 4 * time.Second, Max: 3 * time.Second, PeakMemory: 100},
+	}, SlowestJobs(builds))
+}
@@ -0,0 +1,234 @@
+package scm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/drone/go-scm/scm"
+	"github.com/justinbarrick/hone/pkg/events"
+)
+
+// Hidden marker used to find hone's comment on a pull request so that it can be
+// updated on the next build.
+const commentMarker = "<!-- hone -->"
+
+const DefaultCommentTemplate = `{{if .Success}}**Build succeeded** for {{.Commit}}{{else}}**Build failed** for {{.Commit}}{{end}}
+
+| Job | Status | Duration |
+| --- | ------ | -------- |
+{{range .Jobs}}| {{.Name}} | {{.Status}} | {{.Duration}} |
+{{end}}{{range .Jobs}}{{if .Excerpt}}
+<details><summary>{{.Name}}: {{.Error}}</summary>
+
+` + "```" + `
+{{join .Excerpt "\n"}}
+` + "```" + `
+</details>
+{{end}}{{end}}{{if .ReportURL}}
+[Report]({{.ReportURL}}){{end}}{{if .LogURL}}
+[Logs]({{.LogURL}}){{end}}
+`
+
+type JobSummary struct {
+	Name     string
+	Status   string
+	Duration time.Duration
+	Error    string
+	Excerpt  []string
+}
+
+// The outcome of a build, used to render pull request comments.
+type BuildSummary struct {
+	Success   bool
+	Target    string
+	Commit    string
+	ReportURL string
+	LogURL    string
+	Jobs      []JobSummary
+}
+
+// Return the variables available to comment conditions.
+func (b BuildSummary) Conditions() map[string]interface{} {
+	failed := 0
+	for _, j := range b.Jobs {
+		if j.Status == "failed" {
+			failed++
+		}
+	}
+
+	return map[string]interface{}{
+		"BUILD_SUCCESS": b.Success,
+		"BUILD_FAILED":  failed,
+	}
+}
+
+// The comment APIs shared by pull requests and issues.
+type commentService interface {
+	ListComments(context.Context, string, int, scm.ListOptions) ([]*scm.Comment, *scm.Response, error)
+	CreateComment(context.Context, string, int, *scm.CommentInput) (*scm.Comment, *scm.Response, error)
+	DeleteComment(context.Context, string, int, int) (*scm.Response, error)
+}
+
+func (s *SCM) GetComment() bool {
+	if s.Comment == nil {
+		return false
+	}
+
+	return *s.Comment
+}
+
+// Render the comment for a build with the configured template.
+func (s *SCM) RenderComment(summary BuildSummary) (string, error) {
+	text := DefaultCommentTemplate
+	if s.CommentTemplate != nil {
+		text = *s.CommentTemplate
+	}
+
+	tmpl, err := template.New("comment").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing comment template: %s", err)
+	}
+
+	body := bytes.NewBufferString(commentMarker + "\n")
+	if err := tmpl.Execute(body, summary); err != nil {
+		return "", fmt.Errorf("Error rendering comment template: %s", err)
+	}
+
+	return body.String(), nil
+}
+
+// Update hone's comment on the pull request being built, or post one if there
+// is none yet. Nothing is posted if comments are disabled, the build is not for
+// a pull request or the comment condition is not met.
+func (s *SCM) PostComment(summary BuildSummary) error {
+	if !s.GetComment() || s.Git.CI == nil || s.Git.CI.PullRequest == "" {
+		return nil
+	}
+
+	number, err := strconv.Atoi(s.Git.CI.PullRequest)
+	if err != nil {
+		return fmt.Errorf("Invalid pull request number %s.", s.Git.CI.PullRequest)
+	}
+
+	env := map[string]interface{}{}
+	for key, value := range s.env {
+		env[key] = value
+	}
+	for key, value := range summary.Conditions() {
+		env[key] = value
+	}
+
+	run, err := events.YQLMatch(s.CommentCondition, env)
+	if err != nil || !run {
+		return err
+	}
+
+	body, err := s.RenderComment(summary)
+	if err != nil {
+		return err
+	}
+
+	// Some providers only support comments through the issues API.
+	services := []commentService{s.client.PullRequests, s.client.Issues}
+	for i, service := range services {
+		comment, err := s.findComment(service, number)
+		if err == scm.ErrNotSupported {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if comment != nil {
+			err = s.editComment(i == 0, number, comment.ID, body)
+			if err != scm.ErrNotSupported {
+				return err
+			}
+		}
+
+		_, _, err = service.CreateComment(s.ctx, s.GetRepo(), number, &scm.CommentInput{
+			Body: body,
+		})
+		if err == scm.ErrNotSupported {
+			continue
+		} else if err != nil || comment == nil {
+			return err
+		}
+
+		// The provider can not edit comments, so remove the old one.
+		if _, err := service.DeleteComment(s.ctx, s.GetRepo(), number, comment.ID); err != scm.ErrNotSupported {
+			return err
+		}
+		return nil
+	}
+
+	return scm.ErrNotSupported
+}
+
+// Return hone's latest comment on a pull request, or nil if it has not
+// commented yet.
+func (s *SCM) findComment(service commentService, number int) (*scm.Comment, error) {
+	var found *scm.Comment
+	opts := scm.ListOptions{Page: 1, Size: 100}
+
+	for {
+		comments, res, err := service.ListComments(s.ctx, s.GetRepo(), number, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, comment := range comments {
+			if strings.HasPrefix(comment.Body, commentMarker) {
+				found = comment
+			}
+		}
+
+		if res == nil || res.Page.Next == 0 {
+			return found, nil
+		}
+
+		opts.Page = res.Page.Next
+	}
+}
+
+// Replace the body of a comment, pull is true if the comment was found through
+// the pull request API rather than the issues API. Returns scm.ErrNotSupported
+// for providers that can not edit comments.
+func (s *SCM) editComment(pull bool, number int, id int, body string) error {
+	repo := s.GetRepo()
+	input := map[string]string{"body": body}
+
+	switch s.GetProvider() {
+	case ProviderGithub:
+		return s.doJSON("PATCH", fmt.Sprintf("repos/%s/issues/comments/%d", repo, id), input, nil)
+	case ProviderGitea:
+		return s.doJSON("PATCH", fmt.Sprintf("api/v1/repos/%s/issues/comments/%d", repo, id), input, nil)
+	case ProviderGitlab:
+		kind := "issues"
+		if pull {
+			kind = "merge_requests"
+		}
+
+		project := strings.Replace(repo, "/", "%2F", -1)
+		return s.doJSON("PUT", fmt.Sprintf("api/v4/projects/%s/%s/%d/notes/%d", project, kind, number, id), input, nil)
+	}
+
+	return scm.ErrNotSupported
+}
+
+// Post the build's comment to every SCM.
+func CommentBuild(scms []*SCM, summary BuildSummary) error {
+	for _, s := range scms {
+		if err := s.PostComment(summary); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package scm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/justinbarrick/hone/pkg/ci"
+	"github.com/stretchr/testify/assert"
+)
+
+func commentSCM(t *testing.T, provider Provider, url string, condition *string) *SCM {
+	repo := "justinbarrick/hone"
+	enabled := true
+
+	s := &SCM{
+		Token:            "API_TOKEN",
+		Repo:             &repo,
+		Provider:         &provider,
+		Comment:          &enabled,
+		CommentCondition: condition,
+	}
+
+	if url != "" {
+		s.URL = &url
+	}
+
+	assert.Nil(t, s.Init(context.TODO()))
+	s.Git.CI = &ci.Build{
+		PullRequest: "7",
+	}
+
+	return s
+}
+
+func failedSummary() BuildSummary {
+	return BuildSummary{
+		Success:   false,
+		Commit:    "729ffe88",
+		ReportURL: "https://example.com/report.html",
+		Jobs: []JobSummary{
+			{Name: "build", Status: "cached", Duration: 100 * time.Millisecond},
+			{Name: "lint", Status: "failed", Duration: 2 * time.Second, Error: "exit status 1", Excerpt: []string{"main.go:1: oops"}},
+		},
+	}
+}
+
+func TestRenderComment(t *testing.T) {
+	s := &SCM{}
+
+	body, err := s.RenderComment(failedSummary())
+	assert.Nil(t, err)
+	assert.Contains(t, body, "<!-- hone -->\n**Build failed** for 729ffe88")
+	assert.Contains(t, body, "| build | cached | 100ms |\n| lint | failed | 2s |\n")
+	assert.Contains(t, body, "<details><summary>lint: exit status 1</summary>\n\n```\nmain.go:1: oops\n```")
+	assert.Contains(t, body, "[Report](https://example.com/report.html)")
+
+	tmpl := "{{len .Jobs}} jobs"
+	s.CommentTemplate = &tmpl
+
+	body, err = s.RenderComment(failedSummary())
+	assert.Nil(t, err)
+	assert.Equal(t, "<!-- hone -->\n2 jobs", body)
+}
+
+func TestPostComment(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/repos/justinbarrick/hone/issues/7/comments").
+		Reply(200).
+		Type("application/json").JSON([]map[string]interface{}{
+		{"id": 1, "body": "<!-- hone -->\nold summary"},
+		{"id": 2, "body": "looks good to me"},
+	})
+
+	gock.New("https://api.github.com").
+		Patch("/repos/justinbarrick/hone/issues/comments/1").
+		BodyString(`Build failed.*lint \| failed`).
+		Reply(200).
+		Type("application/json").JSON(map[string]interface{}{"id": 1})
+
+	err := CommentBuild([]*SCM{commentSCM(t, ProviderGithub, "", nil)}, failedSummary())
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestPostCommentCreate(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Get("/repos/justinbarrick/hone/issues/7/comments").
+		Reply(200).
+		Type("application/json").JSON([]map[string]interface{}{
+		{"id": 2, "body": "looks good to me"},
+	})
+
+	gock.New("https://api.github.com").
+		Post("/repos/justinbarrick/hone/issues/7/comments").
+		BodyString(`Build failed`).
+		Reply(201).
+		Type("application/json").JSON(map[string]interface{}{"id": 3})
+
+	err := CommentBuild([]*SCM{commentSCM(t, ProviderGithub, "", nil)}, failedSummary())
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestPostCommentPages(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/justinbarrick(/|%2F)hone/merge_requests/7/notes$").
+		MatchParam("page", "1").
+		Reply(200).
+		SetHeader("Link", `<https://gitlab.com/api/v4/projects/justinbarrick%2Fhone/merge_requests/7/notes?page=2&per_page=100>; rel="next"`).
+		Type("application/json").JSON([]map[string]interface{}{
+		{"id": 1, "body": "looks good to me"},
+	})
+
+	gock.New("https://gitlab.com").
+		Get("/api/v4/projects/justinbarrick(/|%2F)hone/merge_requests/7/notes$").
+		MatchParam("page", "2").
+		Reply(200).
+		Type("application/json").JSON([]map[string]interface{}{
+		{"id": 5, "body": "<!-- hone -->\nold summary"},
+	})
+
+	gock.New("https://gitlab.com").
+		Put("/api/v4/projects/justinbarrick(/|%2F)hone/merge_requests/7/notes/5").
+		BodyString(`Build failed`).
+		Reply(200).
+		Type("application/json").JSON(map[string]interface{}{"id": 5})
+
+	err := CommentBuild([]*SCM{commentSCM(t, ProviderGitlab, "", nil)}, failedSummary())
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestPostCommentIssuesFallback(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://gitea.example.com").
+		Get("/api/v1/repos/justinbarrick/hone/issues/7/comments").
+		Reply(200).
+		Type("application/json").JSON([]map[string]interface{}{})
+
+	gock.New("https://gitea.example.com").
+		Post("/api/v1/repos/justinbarrick/hone/issues/7/comments").
+		BodyString(`Build failed`).
+		Reply(201).
+		Type("application/json").JSON(map[string]interface{}{"id": 3})
+
+	err := CommentBuild([]*SCM{commentSCM(t, ProviderGitea, "https://gitea.example.com/", nil)}, failedSummary())
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestPostCommentCondition(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Post("/repos/justinbarrick/hone/issues/7/comments").
+		Reply(201).
+		Type("application/json").JSON(map[string]interface{}{"id": 3})
+
+	condition := "BUILD_SUCCESS=false"
+	s := commentSCM(t, ProviderGithub, "", &condition)
+
+	summary := failedSummary()
+	summary.Success = true
+	summary.Jobs = summary.Jobs[:1]
+
+	assert.Nil(t, s.PostComment(summary))
+	assert.True(t, gock.IsPending())
+
+	s.Git.CI = nil
+	assert.Nil(t, s.PostComment(failedSummary()))
+	assert.True(t, gock.IsPending())
+}
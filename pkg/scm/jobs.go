@@ -0,0 +1,355 @@
+package scm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drone/go-scm/scm"
+	"github.com/justinbarrick/hone/pkg/graph"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/junit"
+	"github.com/justinbarrick/hone/pkg/logger"
+)
+
+// The maximum number of annotations GitHub accepts in a single request.
+const maxAnnotations = 50
+
+// Output lines that look like compiler or linter errors, eg "main.go:12:3: undefined: x".
+var annotationRe = regexp.MustCompile(`^([\w./-]+\.\w+):(\d+)(?::\d+)?:\s*(.+)$`)
+
+type Annotation struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Level     string `json:"annotation_level"`
+	Message   string `json:"message"`
+}
+
+type checkRunOutput struct {
+	Title       string       `json:"title"`
+	Summary     string       `json:"summary"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+type checkRun struct {
+	ID          int64           `json:"id,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	HeadSHA     string          `json:"head_sha,omitempty"`
+	Status      string          `json:"status,omitempty"`
+	Conclusion  string          `json:"conclusion,omitempty"`
+	DetailsURL  string          `json:"details_url,omitempty"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	Output      *checkRunOutput `json:"output,omitempty"`
+}
+
+// Check run ids by job name, so that a job's check run is updated rather than
+// created again.
+type checkRuns struct {
+	sync.Mutex
+	ids map[string]int64
+}
+
+// Return the status context used for a job, <label>/<job>.
+func (s *SCM) JobContext(name string) string {
+	return fmt.Sprintf("%s/%s", s.GetLabel(), name)
+}
+
+func (s *SCM) GetJobStatuses() bool {
+	if s.JobStatuses == nil {
+		return false
+	}
+
+	return *s.JobStatuses
+}
+
+// Return true if job statuses are posted as check runs, which is only supported
+// on Github.
+func (s *SCM) UseCheckRuns() bool {
+	if s.GetProvider() != ProviderGithub || s.CheckRuns == nil {
+		return false
+	}
+
+	return *s.CheckRuns
+}
+
+// Describe a job's state for its status.
+func jobDescription(j *job.Job, state State) string {
+	switch state {
+	case StatePending:
+		return "Waiting to run."
+	case StateRunning:
+		return "Running."
+	case StateSuccess:
+		if j.Cached {
+			return "Completed successfully from the cache."
+		}
+		return "Completed successfully!"
+	case StateSkipped:
+		return "Skipped, since condition not met."
+	case StateCanceled:
+		if _, ok := j.Error.(*graph.DependencyError); ok {
+			return "Not run, since a dependency failed."
+		}
+		return "Canceled."
+	}
+
+	if failed := junit.Failed(j.Tests); len(failed) > 0 {
+		return fmt.Sprintf("Failed tests: %s", strings.Join(failed, ", "))
+	}
+
+	if j.Error == nil {
+		return "Failed!"
+	}
+
+	if job.IsExitError(j.Error) {
+		return fmt.Sprintf("Failed: %s", j.Error)
+	}
+
+	return fmt.Sprintf("Errored: %s", j.Error)
+}
+
+// Find lines in a job's output that point at a file and line, eg compiler errors.
+func Annotations(lines []string, level string) []Annotation {
+	annotations := []Annotation{}
+
+	for _, line := range lines {
+		match := annotationRe.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		lineNumber, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+
+		annotations = append(annotations, Annotation{
+			Path:      strings.TrimPrefix(match[1], "./"),
+			StartLine: lineNumber,
+			EndLine:   lineNumber,
+			Level:     level,
+			Message:   match[3],
+		})
+
+		if len(annotations) == maxAnnotations {
+			break
+		}
+	}
+
+	return annotations
+}
+
+// Post the state of a single job, as a check run on Github or a commit status with
+// the context <label>/<job> otherwise.
+func (s *SCM) PostJobStatus(j *job.Job, state State, target string) error {
+	if s.UseCheckRuns() {
+		return s.postCheckRun(j, state, target)
+	}
+
+	return s.postStatus(s.JobContext(j.GetName()), j.GetName(), state, s.commit, jobDescription(j, state), target)
+}
+
+func (s *SCM) postCheckRun(j *job.Job, state State, target string) error {
+	now := time.Now().UTC()
+
+	description, err := s.describe(state, j.GetName(), jobDescription(j, state))
+	if err != nil {
+		return err
+	}
+
+	run := checkRun{
+		Name:       s.JobContext(j.GetName()),
+		HeadSHA:    s.commit,
+		DetailsURL: target,
+		Output: &checkRunOutput{
+			Title:   description,
+			Summary: description,
+		},
+	}
+
+	for _, suite := range j.Tests {
+		for _, c := range suite.Cases {
+			if c.Failed() {
+				run.Output.Summary += fmt.Sprintf("\n* `%s`: %s", c.Name, c.Result().Message)
+			}
+		}
+	}
+
+	switch state {
+	case StatePending:
+		run.Status = "queued"
+	case StateRunning:
+		run.Status = "in_progress"
+		run.StartedAt = &now
+	default:
+		run.Status = "completed"
+		run.CompletedAt = &now
+
+		switch state {
+		case StateSuccess:
+			run.Conclusion = "success"
+		case StateSkipped:
+			run.Conclusion = "skipped"
+		case StateCanceled:
+			run.Conclusion = "cancelled"
+		default:
+			run.Conclusion = "failure"
+			run.Output.Annotations = Annotations(logger.Output(j.GetName()), "failure")
+		}
+	}
+
+	s.checks.Lock()
+	id, ok := s.checks.ids[j.GetName()]
+	s.checks.Unlock()
+
+	method := "POST"
+	path := fmt.Sprintf("repos/%s/check-runs", s.GetRepo())
+	if ok {
+		method = "PATCH"
+		path = fmt.Sprintf("%s/%d", path, id)
+	}
+
+	created := checkRun{}
+	if err := s.doJSON(method, path, run, &created); err != nil {
+		return err
+	}
+
+	if !ok && created.ID != 0 {
+		s.checks.Lock()
+		s.checks.ids[j.GetName()] = created.ID
+		s.checks.Unlock()
+	}
+
+	return nil
+}
+
+// Send a JSON request to the provider's API, decoding the response into out.
+func (s *SCM) doJSON(method, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.client.Do(s.ctx, &scm.Request{
+		Method: method,
+		Path:   path,
+		Header: http.Header{
+			"Accept":       {"application/vnd.github.antiope-preview+json"},
+			"Content-Type": {"application/json"},
+		},
+		Body: bytes.NewReader(body),
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.Status > 299 {
+		message, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("%s %s returned %d: %s", method, path, res.Status, bytes.TrimSpace(message))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// Posts the status of each job in a build to the SCMs that have job statuses
+// enabled. Errors posting statuses are logged, but do not fail the job.
+type JobReporter struct {
+	scms     []*SCM
+	target   func(*job.Job) string
+	finished map[string]bool
+	lock     sync.Mutex
+}
+
+// Create a job reporter, target returns the URL each job's status links to.
+func NewJobReporter(scms []*SCM, target func(*job.Job) string) *JobReporter {
+	enabled := []*SCM{}
+	for _, s := range scms {
+		if s.GetJobStatuses() {
+			enabled = append(enabled, s)
+		}
+	}
+
+	return &JobReporter{
+		scms:     enabled,
+		target:   target,
+		finished: map[string]bool{},
+	}
+}
+
+func (r *JobReporter) post(j *job.Job, state State) {
+	for _, s := range r.scms {
+		if err := s.PostJobStatus(j, state, r.target(j)); err != nil && !IsCommitNotFound(err) {
+			logger.Errorf("Error posting status for job %s: %s", j.GetName(), err)
+		}
+	}
+}
+
+// Post a pending status for each job that will run.
+func (r *JobReporter) Pending(jobs []*job.Job) {
+	for _, j := range jobs {
+		if !j.Aggregate {
+			r.post(j, StatePending)
+		}
+	}
+}
+
+// Wrap a job callback to post the job's status when it starts and finishes.
+func (r *JobReporter) ReportJob(callback func(*job.Job) error) func(*job.Job) error {
+	return func(j *job.Job) error {
+		if j.Aggregate {
+			return callback(j)
+		}
+
+		r.post(j, StateRunning)
+
+		err := callback(j)
+
+		state := StateSuccess
+		if err != nil {
+			j.SetError(err)
+			state = StateError
+			if job.IsExitError(err) {
+				state = StateFailure
+			} else if err == graph.ErrCanceled {
+				state = StateCanceled
+			}
+		} else if j.Skipped {
+			state = StateSkipped
+		}
+
+		r.lock.Lock()
+		r.finished[j.GetName()] = true
+		r.lock.Unlock()
+
+		r.post(j, state)
+		return err
+	}
+}
+
+// Post a canceled status for the jobs that did not finish, because a dependency
+// failed or the build was canceled.
+func (r *JobReporter) Finish(jobs []*job.Job) {
+	for _, j := range jobs {
+		r.lock.Lock()
+		finished := r.finished[j.GetName()]
+		r.lock.Unlock()
+
+		if !finished && !j.Aggregate {
+			r.post(j, StateCanceled)
+		}
+	}
+}
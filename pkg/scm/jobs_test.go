@@ -0,0 +1,225 @@
+package scm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/justinbarrick/hone/pkg/graph"
+	"github.com/justinbarrick/hone/pkg/job"
+	"github.com/justinbarrick/hone/pkg/junit"
+	"github.com/justinbarrick/hone/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	logger.InitLogger(0, nil)
+}
+
+func jobSCM(t *testing.T, checkRuns bool) *SCM {
+	repo := "justinbarrick/hone"
+	enabled := true
+
+	s := &SCM{
+		Token:       "API_TOKEN",
+		Repo:        &repo,
+		JobStatuses: &enabled,
+		CheckRuns:   &checkRuns,
+	}
+
+	assert.Nil(t, s.Init(context.TODO()))
+	return s
+}
+
+func noTarget(j *job.Job) string {
+	return ""
+}
+
+func TestJobStatuses(t *testing.T) {
+	defer gock.Off()
+
+	for _, state := range []string{"pending", "pending", "failure"} {
+		gock.New("https://api.github.com").
+			Post("/repos/justinbarrick/hone/statuses/.*").
+			BodyString(`"state":"` + state + `".*"context":"hone/build"`).
+			Reply(201).
+			Type("application/json").JSON(map[string]string{})
+	}
+
+	gock.New("https://api.github.com").
+		Post("/repos/justinbarrick/hone/statuses/.*").
+		BodyString(`"state":"error".*"description":"Not run, since a dependency failed.","context":"hone/deploy"`).
+		Reply(201).
+		Type("application/json").JSON(map[string]string{})
+
+	build := &job.Job{Name: "build"}
+	deploy := &job.Job{Name: "deploy", Error: &graph.DependencyError{Deps: []string{"build"}}}
+
+	reporter := NewJobReporter([]*SCM{jobSCM(t, false)}, noTarget)
+	reporter.Pending([]*job.Job{build})
+
+	err := reporter.ReportJob(func(j *job.Job) error {
+		return &job.ExitError{Code: 1, Message: "exit status 1"}
+	})(build)
+	assert.NotNil(t, err)
+
+	reporter.Finish([]*job.Job{build, deploy})
+	assert.True(t, gock.IsDone())
+}
+
+func TestJobStatusesSkipped(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Post("/repos/justinbarrick/hone/statuses/.*").
+		BodyString(`"state":"pending"`).
+		Reply(201).
+		Type("application/json").JSON(map[string]string{})
+
+	gock.New("https://api.github.com").
+		Post("/repos/justinbarrick/hone/statuses/.*").
+		BodyString(`"state":"success".*"description":"Skipped, since condition not met."`).
+		Reply(201).
+		Type("application/json").JSON(map[string]string{})
+
+	reporter := NewJobReporter([]*SCM{jobSCM(t, false)}, noTarget)
+
+	err := reporter.ReportJob(func(j *job.Job) error {
+		j.Skipped = true
+		return nil
+	})(&job.Job{Name: "release"})
+	assert.Nil(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestJobStatusesDisabled(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Post("/repos/justinbarrick/hone/statuses/.*").
+		Reply(201).
+		Type("application/json").JSON(map[string]string{})
+
+	s := jobSCM(t, false)
+	s.JobStatuses = nil
+
+	reporter := NewJobReporter([]*SCM{s}, noTarget)
+	reporter.Pending([]*job.Job{{Name: "build"}})
+
+	err := reporter.ReportJob(func(j *job.Job) error {
+		return nil
+	})(&job.Job{Name: "build"})
+	assert.Nil(t, err)
+	assert.True(t, gock.IsPending())
+}
+
+func TestCheckRuns(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Post("/repos/justinbarrick/hone/check-runs").
+		MatchHeader("Accept", "antiope-preview").
+		BodyString(`"name":"hone/lint".*"status":"in_progress".*"details_url":"https://example.com/report.html#job-lint"`).
+		Reply(201).
+		Type("application/json").JSON(map[string]interface{}{"id": 42})
+
+	gock.New("https://api.github.com").
+		Patch("/repos/justinbarrick/hone/check-runs/42").
+		BodyString(`"status":"completed","conclusion":"failure".*"annotations":\[{"path":"pkg/main.go","start_line":12,"end_line":12,"annotation_level":"failure","message":"undefined: x"}\]`).
+		Reply(200).
+		Type("application/json").JSON(map[string]interface{}{"id": 42})
+
+	reporter := NewJobReporter([]*SCM{jobSCM(t, true)}, func(j *job.Job) string {
+		return "https://example.com/report.html#job-" + j.GetName()
+	})
+
+	err := reporter.ReportJob(func(j *job.Job) error {
+		logger.LogWriter(j).Write([]byte("# pkg\npkg/main.go:12:3: undefined: x\n"))
+		return errors.New("exit status 2")
+	})(&job.Job{Name: "lint"})
+	assert.NotNil(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func TestAnnotations(t *testing.T) {
+	annotations := Annotations([]string{
+		"ok  	github.com/justinbarrick/hone/pkg/scm",
+		"main.go:4: missing return",
+		"./pkg/job/job.go:10:2: undefined: y",
+		"http://example.com:80: not a file",
+	}, "warning")
+
+	assert.Equal(t, []Annotation{
+		{Path: "main.go", StartLine: 4, EndLine: 4, Level: "warning", Message: "missing return"},
+		{Path: "pkg/job/job.go", StartLine: 10, EndLine: 10, Level: "warning", Message: "undefined: y"},
+	}, annotations)
+}
+
+func TestJobStatusesErrored(t *testing.T) {
+	defer gock.Off()
+
+	gock.New("https://api.github.com").
+		Post("/repos/justinbarrick/hone/statuses/.*").
+		BodyString(`"state":"pending".*"context":"ci/build"`).
+		Reply(201).
+		Type("application/json").JSON(map[string]string{})
+
+	gock.New("https://api.github.com").
+		Post("/repos/justinbarrick/hone/statuses/.*").
+		BodyString(`"state":"error".*"description":"Errored: image not found","context":"ci/build"`).
+		Reply(201).
+		Type("application/json").JSON(map[string]string{})
+
+	gock.New("https://api.github.com").
+		Post("/repos/justinbarrick/hone/statuses/.*").
+		BodyString(`"state":"error".*"description":"Canceled.","context":"ci/deploy"`).
+		Reply(201).
+		Type("application/json").JSON(map[string]string{})
+
+	label := "ci"
+	s := jobSCM(t, false)
+	s.Label = &label
+
+	reporter := NewJobReporter([]*SCM{s}, noTarget)
+
+	build := &job.Job{Name: "build"}
+	err := reporter.ReportJob(func(j *job.Job) error {
+		return errors.New("image not found")
+	})(build)
+	assert.NotNil(t, err)
+
+	reporter.Finish([]*job.Job{build, {Name: "deploy"}})
+	assert.True(t, gock.IsDone())
+}
+
+func TestJobDescriptionFailedTests(t *testing.T) {
+	j := &job.Job{
+		Name:  "test",
+		Error: &job.ExitError{Code: 1, Message: "exit status 1"},
+		Tests: []junit.TestSuite{
+			{
+				Cases: []junit.TestCase{
+					{Name: "TestOk"},
+					{Name: "TestBroken", Failure: &junit.Result{Message: "Failed"}},
+					{Name: "TestPanics", Error: &junit.Result{Message: "panic"}},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, "Failed tests: TestBroken, TestPanics", jobDescription(j, StateFailure))
+
+	j.Tests = nil
+	assert.Equal(t, "Failed: exit status 1", jobDescription(j, StateFailure))
+}
+
+func TestUseCheckRuns(t *testing.T) {
+	repo := "justinbarrick/hone"
+	s := &SCM{Token: "API_TOKEN", Repo: &repo}
+	assert.Nil(t, s.Init(context.TODO()))
+	assert.False(t, s.UseCheckRuns())
+
+	assert.True(t, jobSCM(t, true).UseCheckRuns())
+	assert.False(t, jobSCM(t, false).UseCheckRuns())
+}
@@ -1,12 +1,13 @@
 package scm
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
+	"text/template"
 
 	"github.com/drone/go-scm/scm"
 	"github.com/drone/go-scm/scm/driver/bitbucket"
@@ -18,9 +19,14 @@ import (
 	"github.com/drone/go-scm/scm/transport"
 	"github.com/justinbarrick/hone/pkg/events"
 	"github.com/justinbarrick/hone/pkg/git"
+	"github.com/justinbarrick/hone/pkg/graph"
+	"github.com/justinbarrick/hone/pkg/job"
 	"github.com/justinbarrick/hone/pkg/logger"
 )
 
+// The maximum length of a commit status description on Github.
+const maxDescription = 140
+
 type State int
 
 const (
@@ -31,8 +37,81 @@ const (
 	StateFailure
 	StateCanceled
 	StateError
+	StateSkipped
 )
 
+func (s State) String() string {
+	switch s {
+	case StatePending:
+		return "pending"
+	case StateRunning:
+		return "running"
+	case StateSuccess:
+		return "success"
+	case StateFailure:
+		return "failure"
+	case StateCanceled:
+		return "canceled"
+	case StateError:
+		return "error"
+	case StateSkipped:
+		return "skipped"
+	}
+
+	return "unknown"
+}
+
+// Return the go-scm state to post for a state.
+func (s State) SCMState() scm.State {
+	switch s {
+	case StatePending:
+		return scm.StatePending
+	case StateRunning:
+		return scm.StateRunning
+	case StateSuccess, StateSkipped:
+		// Commit statuses have no skipped state.
+		return scm.StateSuccess
+	case StateFailure:
+		return scm.StateFailure
+	case StateCanceled:
+		return scm.StateCanceled
+	case StateError:
+		return scm.StateError
+	}
+
+	return scm.StateUnknown
+}
+
+// Return the state of a finished build from its errors: jobs that exited
+// unsuccessfully, and the jobs that depended on them, fail the build while
+// anything else, like a configuration, infrastructure or timeout error, is an
+// error.
+func BuildState(errs []error) State {
+	if len(errs) == 0 {
+		return StateSuccess
+	}
+
+	for _, err := range errs {
+		if _, ok := err.(*graph.DependencyError); ok {
+			continue
+		}
+
+		if !job.IsExitError(err) {
+			return StateError
+		}
+	}
+
+	return StateFailure
+}
+
+// The variables available to status description templates.
+type StatusDescription struct {
+	State   string
+	Job     string
+	Commit  string
+	Message string
+}
+
 type Provider string
 
 const (
@@ -45,16 +124,25 @@ const (
 )
 
 type SCM struct {
-	Provider  *Provider `hcl:"provider"`
-	URL       *string   `hcl:"url"`
-	Token     string    `hcl:"token"`
-	Repo      *string   `hcl:"repo"`
-	Remote    *string   `hcl:"remote"`
-	Condition *string   `hcl:"condition"`
-	Git       git.Repository
-	commit    string
-	client    *scm.Client
-	ctx       context.Context
+	Provider         *Provider `hcl:"provider"`
+	URL              *string   `hcl:"url"`
+	Token            string    `hcl:"token"`
+	Repo             *string   `hcl:"repo"`
+	Remote           *string   `hcl:"remote"`
+	Condition        *string   `hcl:"condition"`
+	JobStatuses      *bool     `hcl:"job_statuses"`
+	CheckRuns        *bool     `hcl:"check_runs"`
+	Comment          *bool     `hcl:"comment"`
+	CommentTemplate  *string   `hcl:"comment_template"`
+	CommentCondition *string   `hcl:"comment_condition"`
+	Label            *string   `hcl:"label"`
+	Description      *string   `hcl:"description"`
+	Git              git.Repository
+	commit           string
+	client           *scm.Client
+	ctx              context.Context
+	checks           *checkRuns
+	env              map[string]interface{}
 }
 
 func (s *SCM) GetURL() (string, error) {
@@ -120,19 +208,22 @@ func (s *SCM) GetRepo() string {
 }
 
 func (s *SCM) Init(ctx context.Context) (err error) {
-	if os.Getenv("REPO_OWNER") != "" && os.Getenv("REPO_NAME") != "" {
-		repo := fmt.Sprintf("%s/%s", os.Getenv("REPO_OWNER"), os.Getenv("REPO_NAME"))
-		provider := ProviderGithub
-		s.Repo = &repo
-		s.Provider = &provider
-	}
-
 	repo, err := git.NewRepository()
 	if err != nil {
 		return err
 	}
 	s.Git = repo
 
+	if repo.CI != nil && repo.CI.Repo != "" && s.Repo == nil {
+		slug := repo.CI.Repo
+		s.Repo = &slug
+	}
+
+	if repo.CI != nil && repo.CI.SCM != "" && s.Provider == nil {
+		provider := Provider(repo.CI.SCM)
+		s.Provider = &provider
+	}
+
 	s.commit, err = s.Git.Commit()
 	if err != nil {
 		return err
@@ -171,18 +262,73 @@ func (s *SCM) Init(ctx context.Context) (err error) {
 	}
 
 	s.ctx = ctx
+	s.checks = &checkRuns{
+		ids: map[string]int64{},
+	}
 	return
 }
 
+// Return the context statuses are posted with, defaults to hone.
+func (s SCM) GetLabel() string {
+	if s.Label == nil || *s.Label == "" {
+		return "hone"
+	}
+
+	return *s.Label
+}
+
+// Render a status description with the configured template, message is the
+// default description.
+func (s SCM) describe(state State, jobName string, message string) (string, error) {
+	if s.Description == nil {
+		return truncate(message), nil
+	}
+
+	tmpl, err := template.New("description").Parse(*s.Description)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing description template: %s", err)
+	}
+
+	description := bytes.NewBuffer(nil)
+	err = tmpl.Execute(description, StatusDescription{
+		State:   state.String(),
+		Job:     jobName,
+		Commit:  s.commit,
+		Message: message,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error rendering description template: %s", err)
+	}
+
+	return truncate(description.String()), nil
+}
+
+func truncate(description string) string {
+	if len(description) > maxDescription {
+		return description[:maxDescription-3] + "..."
+	}
+
+	return description
+}
+
 func (s SCM) PostStatus(state State, commit string, message string, reportUrl string) error {
+	return s.postStatus(s.GetLabel(), "", state, commit, message, reportUrl)
+}
+
+func (s SCM) postStatus(label string, jobName string, state State, commit string, message string, reportUrl string) error {
+	description, err := s.describe(state, jobName, message)
+	if err != nil {
+		return err
+	}
+
 	status := &scm.StatusInput{
-		State:  scm.State(state),
-		Label:  "hone",
-		Desc:   message,
+		State:  state.SCMState(),
+		Label:  label,
+		Desc:   description,
 		Target: reportUrl,
 	}
 
-	_, _, err := s.client.Repositories.CreateStatus(s.ctx, s.GetRepo(), commit, status)
+	_, _, err = s.client.Repositories.CreateStatus(s.ctx, s.GetRepo(), commit, status)
 	return err
 }
 
@@ -195,18 +341,18 @@ func (s SCM) BuildCompleted(reportUrl string) error {
 }
 
 func (s SCM) BuildFailed(reportUrl string) error {
-	return s.PostStatus(StateError, s.commit, "Build failed!", reportUrl)
+	return s.PostStatus(StateFailure, s.commit, "Build failed!", reportUrl)
 }
 
 func (s SCM) BuildErrored(reportUrl string) error {
-	return s.PostStatus(StateError, s.commit, "Build errored due to a configuration error!", reportUrl)
+	return s.PostStatus(StateError, s.commit, "Build errored before it could complete!", reportUrl)
 }
 
 func (s SCM) BuildCanceled(reportUrl string) error {
 	return s.PostStatus(StateCanceled, s.commit, "Build cancelled by user!", reportUrl)
 }
 
-func InitSCMs(scms []*SCM, env map[string]string) ([]*SCM, error) {
+func InitSCMs(scms []*SCM, env map[string]interface{}) ([]*SCM, error) {
 	finalScms := []*SCM{}
 
 	// TODO: Status() doesn't ignore gitignore: https://github.com/src-d/go-git/issues/844
@@ -225,13 +371,8 @@ func InitSCMs(scms []*SCM, env map[string]string) ([]*SCM, error) {
 		}
 	*/
 
-	envMap := map[string]interface{}{}
-	for key, val := range env {
-		envMap[key] = val
-	}
-
 	for _, scm := range scms {
-		run, err := events.YQLMatch(scm.Condition, envMap)
+		run, err := events.YQLMatch(scm.Condition, env)
 		if err != nil {
 			return finalScms, err
 		}
@@ -244,6 +385,7 @@ func InitSCMs(scms []*SCM, env map[string]string) ([]*SCM, error) {
 		if err != nil {
 			return finalScms, err
 		}
+		scm.env = env
 
 		logger.Printf("Initialized reporting provider: %s", scm.GetProvider())
 		finalScms = append(finalScms, scm)
@@ -291,9 +433,35 @@ func BuildCompleted(scms []*SCM, reportUrl string) error {
 	return nil
 }
 
-func ReportBuild(scms []*SCM, success bool, reportUrl string) error {
-	if success {
+func BuildFailed(scms []*SCM, reportUrl string) error {
+	for _, scm := range scms {
+		if err := scm.BuildFailed(reportUrl); err != nil && !IsCommitNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func BuildCanceled(scms []*SCM, reportUrl string) error {
+	for _, scm := range scms {
+		if err := scm.BuildCanceled(reportUrl); err != nil && !IsCommitNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Post the final status of a build, see BuildState.
+func ReportBuild(scms []*SCM, state State, reportUrl string) error {
+	switch state {
+	case StateSuccess:
 		return BuildCompleted(scms, reportUrl)
+	case StateFailure:
+		return BuildFailed(scms, reportUrl)
+	case StateCanceled:
+		return BuildCanceled(scms, reportUrl)
 	}
 
 	return BuildErrored(scms, reportUrl)
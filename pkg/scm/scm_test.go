@@ -2,9 +2,14 @@ package scm
 
 import (
 	"context"
+	"errors"
+	"os"
 	"testing"
 
+	"github.com/drone/go-scm/scm"
 	"github.com/h2non/gock"
+	"github.com/justinbarrick/hone/pkg/graph"
+	"github.com/justinbarrick/hone/pkg/job"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,3 +33,101 @@ func TestSCM(t *testing.T) {
 	err = scm.PostStatus(StatePending, "43f77731a7a882a2616cfadcd9d23cd723f871ab", "success", "")
 	assert.Nil(t, err)
 }
+
+func TestSCMRepoFromCI(t *testing.T) {
+	for key, value := range map[string]string{
+		"REPO_OWNER": "justinbarrick",
+		"REPO_NAME":  "hone-fork",
+	} {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+
+	scm := SCM{
+		Token: "API_TOKEN",
+	}
+
+	err := scm.Init(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, "justinbarrick/hone-fork", scm.GetRepo())
+	assert.Equal(t, ProviderGithub, scm.GetProvider())
+
+	repo := "justinbarrick/hone"
+	scm = SCM{
+		Token: "API_TOKEN",
+		Repo:  &repo,
+	}
+
+	err = scm.Init(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, "justinbarrick/hone", scm.GetRepo())
+}
+
+func TestSCMState(t *testing.T) {
+	assert.Equal(t, scm.StatePending, StatePending.SCMState())
+	assert.Equal(t, scm.StateRunning, StateRunning.SCMState())
+	assert.Equal(t, scm.StateSuccess, StateSuccess.SCMState())
+	assert.Equal(t, scm.StateSuccess, StateSkipped.SCMState())
+	assert.Equal(t, scm.StateFailure, StateFailure.SCMState())
+	assert.Equal(t, scm.StateCanceled, StateCanceled.SCMState())
+	assert.Equal(t, scm.StateError, StateError.SCMState())
+	assert.Equal(t, scm.StateUnknown, StateUnknown.SCMState())
+}
+
+func TestBuildState(t *testing.T) {
+	failed := &job.ExitError{Code: 1, Message: "exit status 1"}
+	deps := &graph.DependencyError{Deps: []string{"build"}}
+
+	assert.Equal(t, StateSuccess, BuildState(nil))
+	assert.Equal(t, StateFailure, BuildState([]error{failed, deps}))
+	assert.Equal(t, StateError, BuildState([]error{errors.New("Target build not found.")}))
+	assert.Equal(t, StateError, BuildState([]error{failed, errors.New("Cannot connect to the Docker daemon")}))
+}
+
+func TestReportBuild(t *testing.T) {
+	defer gock.Off()
+
+	repo := "justinbarrick/hone"
+	label := "ci/hone"
+	description := "{{.State}}: {{.Message}}"
+
+	for _, state := range []string{"success", "failure", "error", "error"} {
+		gock.New("https://api.github.com").
+			Post("/repos/justinbarrick/hone/statuses/.*").
+			BodyString(`"state":"` + state + `".*"description":"[a-z]+: Build .*","context":"ci/hone"`).
+			Reply(201).
+			Type("application/json").JSON(map[string]string{})
+	}
+
+	s := &SCM{
+		Token:       "API_TOKEN",
+		Repo:        &repo,
+		Label:       &label,
+		Description: &description,
+	}
+	assert.Nil(t, s.Init(context.TODO()))
+
+	for _, state := range []State{StateSuccess, StateFailure, StateError, StateCanceled} {
+		assert.Nil(t, ReportBuild([]*SCM{s}, state, ""))
+	}
+	assert.True(t, gock.IsDone())
+}
+
+func TestDescription(t *testing.T) {
+	s := SCM{}
+
+	description, err := s.describe(StateFailure, "lint", string(make([]byte, 200)))
+	assert.Nil(t, err)
+	assert.Equal(t, 140, len(description))
+
+	tmpl := "{{.Job}} is {{.State}}"
+	s.Description = &tmpl
+
+	description, err = s.describe(StateSkipped, "lint", "Skipped.")
+	assert.Nil(t, err)
+	assert.Equal(t, "lint is skipped", description)
+
+	tmpl = "{{.Job"
+	_, err = s.describe(StateSkipped, "lint", "Skipped.")
+	assert.NotNil(t, err)
+}
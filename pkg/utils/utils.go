@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"crypto/rand"
+	"fmt"
 	"hash/crc32"
+	"time"
 )
 
 func Crc(identifier string) int64 {
@@ -9,3 +12,9 @@ func Crc(identifier string) int64 {
 	result := int64(crc32.Checksum([]byte(identifier), crcTable))
 	return result
 }
+
+func NewBuildID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%d-%x", time.Now().Unix(), suffix)
+}